@@ -0,0 +1,198 @@
+package algorithm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+/**
+ * 多路召回的融合策略。
+ *
+ * 1. 加权 RRF（rrf）：score = Σ w_i / (k + rank_i)，只看排名，见 rrf.go 的说明。
+ *    w_i 让某一路的排名更有话语权，k 越大各排名之间的差距越小。
+ * 2. 归一化线性加权（minmax / zscore）：先把每一路的原始得分归一化到可比较的尺度，再按权重相加。
+ *    - minmax: (s - min) / (max - min)，落在 [0, 1]
+ *    - zscore: (s - mean) / std，再整体平移使该路最小值为 0，避免负分使“被召回”反而不如“未召回”
+ *    相比 RRF 保留了分数间距：某一路中遥遥领先的文档能获得明显更高的得分。
+ * 3. 基于分布的融合（dbsf，Distribution-Based Score Fusion）：
+ *    以 mean ± 3σ 作为该路得分的上下界做 min-max 归一化并截断到 [0, 1]，
+ *    对个别离群的高分不敏感，比直接 min-max 更稳定。
+ *
+ * 未被某一路召回的文档在该路的贡献为 0。分数类策略要求各路得分“越大越相关”。
+ */
+
+// FusionMethod 融合策略
+type FusionMethod string
+
+const (
+	FusionRRF    FusionMethod = "rrf"
+	FusionMinMax FusionMethod = "minmax"
+	FusionZScore FusionMethod = "zscore"
+	FusionDBSF   FusionMethod = "dbsf"
+)
+
+// ProvenanceMetaKey 融合结果中记录文档来源的 MetaData 键，值为 []Provenance
+const ProvenanceMetaKey = "_provenance"
+
+// DefaultRRFK RRF 的默认平滑常数
+const DefaultRRFK = 60
+
+// FusionSource 一路召回的结果
+type FusionSource struct {
+	Name   string
+	Weight float64
+	Docs   []*schema.Document // 按该路的相关性从高到低排列
+}
+
+// Provenance 文档在某一路召回中的排名（从 1 开始）与原始得分
+type Provenance struct {
+	Source string  `json:"source"`
+	Rank   int     `json:"rank"`
+	Score  float64 `json:"score"`
+}
+
+// Fuse 按指定策略融合多路召回结果，按融合得分从高到低返回，并在 MetaData 中写入来源。
+// k 只对 rrf 生效，小于等于 0 时使用 DefaultRRFK
+func Fuse(method FusionMethod, k float64, sources []FusionSource) ([]*schema.Document, error) {
+	var normalize func(scores []float64) []float64
+	switch method {
+	case FusionRRF, "":
+		if k <= 0 {
+			k = DefaultRRFK
+		}
+	case FusionMinMax:
+		normalize = minMaxNormalize
+	case FusionZScore:
+		normalize = zScoreNormalize
+	case FusionDBSF:
+		normalize = dbsfNormalize
+	default:
+		return nil, fmt.Errorf("不支持的融合策略: %s，可选 rrf / minmax / zscore / dbsf", method)
+	}
+
+	fused := make(map[string]float64)
+	docMap := make(map[string]*schema.Document)
+	provenance := make(map[string][]Provenance)
+
+	for _, src := range sources {
+		var normalized []float64
+		if normalize != nil {
+			raw := make([]float64, len(src.Docs))
+			for i, doc := range src.Docs {
+				raw[i] = doc.Score()
+			}
+			normalized = normalize(raw)
+		}
+
+		seen := make(map[string]bool, len(src.Docs))
+		for i, doc := range src.Docs {
+			// 同一路中重复的文档只按最靠前的排名计分
+			if doc.ID == "" || seen[doc.ID] {
+				continue
+			}
+			seen[doc.ID] = true
+
+			if normalize == nil {
+				fused[doc.ID] += src.Weight / (k + float64(i+1))
+			} else {
+				fused[doc.ID] += src.Weight * normalized[i]
+			}
+			provenance[doc.ID] = append(provenance[doc.ID], Provenance{Source: src.Name, Rank: i + 1, Score: doc.Score()})
+			if _, exists := docMap[doc.ID]; !exists {
+				docMap[doc.ID] = doc
+			}
+		}
+	}
+
+	results := make([]*schema.Document, 0, len(docMap))
+	for id, doc := range docMap {
+		// 复制文档，避免修改各路检索器返回（可能被缓存）的对象
+		meta := make(map[string]any, len(doc.MetaData)+2)
+		for key, v := range doc.MetaData {
+			meta[key] = v
+		}
+		meta[ProvenanceMetaKey] = provenance[id]
+		out := &schema.Document{ID: doc.ID, Content: doc.Content, MetaData: meta}
+		results = append(results, out.WithScore(fused[id]))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score() != results[j].Score() {
+			return results[i].Score() > results[j].Score()
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results, nil
+}
+
+// minMaxNormalize 线性缩放到 [0, 1]，所有得分相同时都记为 1
+func minMaxNormalize(scores []float64) []float64 {
+	if len(scores) == 0 {
+		return scores
+	}
+	lo, hi := scores[0], scores[0]
+	for _, s := range scores {
+		lo, hi = math.Min(lo, s), math.Max(hi, s)
+	}
+	return scale(scores, lo, hi)
+}
+
+// zScoreNormalize 标准化后平移使最小值为 0
+func zScoreNormalize(scores []float64) []float64 {
+	if len(scores) == 0 {
+		return scores
+	}
+	mean, std := meanStd(scores)
+	out := make([]float64, len(scores))
+	if std == 0 {
+		for i := range out {
+			out[i] = 1
+		}
+		return out
+	}
+	lo := math.Inf(1)
+	for i, s := range scores {
+		out[i] = (s - mean) / std
+		lo = math.Min(lo, out[i])
+	}
+	for i := range out {
+		out[i] -= lo
+	}
+	return out
+}
+
+// dbsfNormalize 以 mean ± 3σ 为上下界缩放到 [0, 1]
+func dbsfNormalize(scores []float64) []float64 {
+	if len(scores) == 0 {
+		return scores
+	}
+	mean, std := meanStd(scores)
+	return scale(scores, mean-3*std, mean+3*std)
+}
+
+// scale 将 [lo, hi] 线性映射到 [0, 1] 并截断，区间为空时都记为 1
+func scale(scores []float64, lo, hi float64) []float64 {
+	out := make([]float64, len(scores))
+	for i, s := range scores {
+		if hi <= lo {
+			out[i] = 1
+			continue
+		}
+		out[i] = math.Max(0, math.Min(1, (s-lo)/(hi-lo)))
+	}
+	return out
+}
+
+func meanStd(scores []float64) (mean, std float64) {
+	for _, s := range scores {
+		mean += s
+	}
+	mean /= float64(len(scores))
+	for _, s := range scores {
+		std += (s - mean) * (s - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(scores)))
+}
@@ -0,0 +1,136 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func floatsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize func([]float64) []float64
+		in, want  []float64
+	}{
+		{"minmax", minMaxNormalize, []float64{1, 3, 5}, []float64{0, 0.5, 1}},
+		{"minmax 得分相同", minMaxNormalize, []float64{2, 2}, []float64{1, 1}},
+		{"minmax 空", minMaxNormalize, []float64{}, []float64{}},
+		{"zscore 最小值平移为 0", zScoreNormalize, []float64{1, 2, 3}, []float64{0, math.Sqrt(1.5), 2 * math.Sqrt(1.5)}},
+		{"zscore 得分相同", zScoreNormalize, []float64{4, 4, 4}, []float64{1, 1, 1}},
+		{"dbsf", dbsfNormalize, []float64{1, 3}, []float64{1.0 / 3, 2.0 / 3}},
+		{"dbsf 得分相同", dbsfNormalize, []float64{0.5}, []float64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.normalize(tt.in); !floatsEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleClamp(t *testing.T) {
+	got := scale([]float64{-1, 0.5, 2}, 0, 1)
+	if want := []float64{0, 0.5, 1}; !floatsEqual(got, want) {
+		t.Errorf("scale = %v, want %v", got, want)
+	}
+}
+
+func doc(id string, score float64) *schema.Document {
+	return (&schema.Document{ID: id, Content: id}).WithScore(score)
+}
+
+func TestFuse(t *testing.T) {
+	dense := FusionSource{Name: "dense", Weight: 1, Docs: []*schema.Document{doc("a", 0.9), doc("b", 0.5), doc("c", 0.1)}}
+	sparse := FusionSource{Name: "sparse", Weight: 1, Docs: []*schema.Document{doc("c", 12), doc("a", 3)}}
+
+	tests := []struct {
+		name    string
+		method  FusionMethod
+		sources []FusionSource
+		order   []string
+		scores  []float64
+	}{
+		{
+			name:    "rrf 默认 k",
+			method:  FusionRRF,
+			sources: []FusionSource{dense, sparse},
+			order:   []string{"a", "c", "b"},
+			scores:  []float64{1.0/61 + 1.0/62, 1.0/63 + 1.0/61, 1.0 / 62},
+		},
+		{
+			name:    "minmax 线性加权",
+			method:  FusionMinMax,
+			sources: []FusionSource{dense, sparse},
+			order:   []string{"a", "c", "b"}, // 得分相同时按 ID 排序
+			scores:  []float64{1, 1, 0.5},
+		},
+		{
+			name:    "权重为 0 的一路不计分",
+			method:  FusionMinMax,
+			sources: []FusionSource{dense, {Name: "sparse", Weight: 0, Docs: sparse.Docs}},
+			order:   []string{"a", "b", "c"},
+			scores:  []float64{1, 0.5, 0},
+		},
+		{
+			name:   "同一路重复的文档只计一次",
+			method: FusionRRF,
+			sources: []FusionSource{
+				{Name: "dense", Weight: 1, Docs: []*schema.Document{doc("a", 1), doc("a", 1), doc("b", 1)}},
+			},
+			order:  []string{"a", "b"},
+			scores: []float64{1.0 / 61, 1.0 / 63},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := Fuse(tt.method, 0, tt.sources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != len(tt.order) {
+				t.Fatalf("got %d docs, want %d", len(docs), len(tt.order))
+			}
+			for i, d := range docs {
+				if d.ID != tt.order[i] || math.Abs(d.Score()-tt.scores[i]) > 1e-9 {
+					t.Errorf("docs[%d] = %s(%v), want %s(%v)", i, d.ID, d.Score(), tt.order[i], tt.scores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFuseProvenance(t *testing.T) {
+	src := []*schema.Document{doc("a", 0.8)}
+	docs, err := Fuse(FusionRRF, 0, []FusionSource{{Name: "dense", Weight: 1, Docs: src}, {Name: "sparse", Weight: 1, Docs: src}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov, ok := docs[0].MetaData[ProvenanceMetaKey].([]Provenance)
+	if !ok || len(prov) != 2 || prov[0].Source != "dense" || prov[1].Source != "sparse" || prov[0].Rank != 1 {
+		t.Errorf("provenance = %v", docs[0].MetaData[ProvenanceMetaKey])
+	}
+	// 融合结果是副本，不修改各路返回的文档
+	if _, exists := src[0].MetaData[ProvenanceMetaKey]; exists || src[0].Score() != 0.8 {
+		t.Error("Fuse 修改了输入文档")
+	}
+}
+
+func TestFuseUnknownMethod(t *testing.T) {
+	if _, err := Fuse("sum", 0, nil); err == nil {
+		t.Error("expected error for unknown fusion method")
+	}
+}
@@ -0,0 +1,56 @@
+package algorithm
+
+import (
+	"strings"
+	"unicode"
+)
+
+/**
+ * 中英文混合文本的分词，供 BM25 等基于词项的检索使用。
+ *
+ * 英文与数字：按连续的字母、数字切分并转为小写，如 "GPT-4o" 切分为 "gpt"、"4o"。
+ * 中文：没有空格分隔，这里不依赖词典，按单字加相邻二字（bigram）切分，
+ * 如 "报销流程" 切分为 "报"、"销"、"流"、"程"、"报销"、"销流"、"流程"。
+ * 单字保证召回，bigram 让查询与文档中相同的词组获得更高的得分，
+ * 无意义的跨词 bigram（如 "销流"）在语料中出现较少，对排序影响有限。
+ */
+
+// Tokenize 将文本切分为检索用的词项
+func Tokenize(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	var han []rune
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	flushHan := func() {
+		for i, r := range han {
+			tokens = append(tokens, string(r))
+			if i > 0 {
+				tokens = append(tokens, string(han[i-1:i+1]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
@@ -0,0 +1,27 @@
+package algorithm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"英文转小写并按符号切分", "GPT-4o Model", []string{"gpt", "4o", "model"}},
+		{"中文单字加二字", "报销流程", []string{"报", "销", "报销", "流", "销流", "程", "流程"}},
+		{"中英文混合", "AI报销", []string{"ai", "报", "销", "报销"}},
+		{"标点切断中文", "报销，流程", []string{"报", "销", "报销", "流", "程", "流程"}},
+		{"空文本", " ，。 ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
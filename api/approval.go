@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-agent/flow"
+	"go-agent/tool/approval"
+	"go-agent/tool/audit"
+	"go-agent/tool/storage"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// approvalInbox 审批收件箱，在 Run 中初始化
+var approvalInbox *approval.Inbox
+
+// ApprovalVoteRequest 审批人身份来自 RequireIdentity 认证的结果，不在请求体中
+type ApprovalVoteRequest struct {
+	SQL    string `json:"sql,omitempty"` // 修改后的 SQL，会清空已有的审批票数
+	Reason string `json:"reason,omitempty"`
+}
+
+type approvalItem struct {
+	*approval.Request
+	AgeSeconds int64 `json:"age_seconds"`
+}
+
+// ListApprovals 列出所有待审批的 SQL，按等待时间从长到短排列
+func ListApprovals(c *gin.Context) {
+	list, err := approvalInbox.ListPending(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list approvals: " + err.Error()})
+		return
+	}
+
+	items := make([]approvalItem, 0, len(list))
+	for _, r := range list {
+		items = append(items, approvalItem{Request: r, AgeSeconds: int64(r.Age() / time.Second)})
+	}
+	c.JSON(http.StatusOK, gin.H{"approvals": items, "total": len(items)})
+}
+
+// GetApproval 查询审批详情，代为执行的结果也保存在其中
+func GetApproval(c *gin.Context) {
+	r, err := approvalInbox.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, approvalItem{Request: r, AgeSeconds: int64(r.Age() / time.Second)})
+}
+
+// ApproveApproval 审批人在收件箱中批准，票数达到要求后代表原会话恢复执行并保存结果
+func ApproveApproval(c *gin.Context) {
+	var req ApprovalVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+
+	ar, ready, ok := castVote(c, c.Param("id"), approval.Vote{Approver: principal(c), Reason: req.Reason, SQL: req.SQL})
+	if !ok {
+		return
+	}
+	if !ready {
+		c.JSON(http.StatusOK, waitingResponse(ar))
+		return
+	}
+
+	result, next, err := executeApproved(c.Request.Context(), ar)
+	switch {
+	case errors.Is(err, storage.ErrInvalidTransition), errors.Is(err, errInterruptMismatch):
+		writeSessionError(c, ar.SessionID, err)
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case next != nil:
+		c.JSON(http.StatusOK, gin.H{"status": "need_approval", "approval": next})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "done", "approval": ar, "result": result})
+	}
+}
+
+// RejectApproval 审批人在收件箱中拒绝，原会话进入补充信息状态
+func RejectApproval(c *gin.Context) {
+	var req ApprovalVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+	if rejectApproval(c, c.Param("id"), approval.Vote{Approver: principal(c), Reason: req.Reason}) {
+		c.JSON(http.StatusOK, gin.H{"status": "rejected", "approval_id": c.Param("id")})
+	}
+}
+
+// registerInterrupt 将审批中断登记到收件箱并把会话迁移到待审批状态，err 不是审批中断时 ok 为 false
+func registerInterrupt(ctx context.Context, err error, sessionID, checkPointID, originalQuery, requester string) (*approval.Request, bool, error) {
+	info, ok := compose.ExtractInterruptInfo(err)
+	if !ok || len(info.InterruptContexts) == 0 {
+		return nil, false, nil
+	}
+	ic := info.InterruptContexts[0]
+	ai, ok := ic.Info.(*flow.ApprovalInfo)
+	if !ok {
+		return nil, false, nil
+	}
+
+	if requester == "" {
+		requester = sessionID
+	}
+	ar := &approval.Request{
+		InterruptID:   ic.ID,
+		SessionID:     sessionID,
+		CheckPointID:  checkPointID,
+		Requester:     requester,
+		OriginalQuery: originalQuery,
+		SQL:           ai.SQL,
+		Type:          ai.Type,
+		Risk:          ai.Risk,
+		Reasons:       ai.Reasons,
+		Tables:        ai.Tables,
+		Preview:       ai.Preview,
+	}
+	if err := approvalInbox.Submit(ctx, ar); err != nil {
+		return nil, true, err
+	}
+
+	// 超过 HITL_APPROVAL_TTL 未处理的审批自动过期
+	_, err = sessionStore.Transition(ctx, sessionID, []storage.SessionState{storage.SessionDone}, func(sc *storage.SessionContext) error {
+		*sc = storage.SessionContext{
+			State:         storage.SessionPendingApproval,
+			InterruptID:   ic.ID,
+			ApprovalID:    ar.ID,
+			CheckPointID:  checkPointID,
+			OriginalQuery: originalQuery,
+		}
+		return nil
+	})
+	return ar, true, err
+}
+
+// executeApproved 代表原会话恢复执行已通过的审批并保存结果。
+// SQL 执行失败后重新生成的 SQL 作为新的审批请求进入收件箱，通过 next 返回
+func executeApproved(ctx context.Context, ar *approval.Request) ([]approval.ResultMessage, *approval.Request, error) {
+	sc, err := takePending(ctx, ar.SessionID, ar.ID, func(sc *storage.SessionContext) {
+		*sc = storage.SessionContext{State: storage.SessionDone}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Printf(">>> Inbox Approve: approvalID=%s, sessionID=%s, approvers=%d\n", ar.ID, ar.SessionID, len(ar.Approvals))
+
+	runnable, err := flow.GetFinalGraph()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get graph: %w", err)
+	}
+
+	msgs, err := runnable.Invoke(resumeContext(ctx, ar), flow.FinalGraphRequest{SessionID: ar.SessionID}, compose.WithCheckPointID(sc.CheckPointID))
+	if err != nil {
+		next, isInterrupt, regErr := registerInterrupt(ctx, err, ar.SessionID, sc.CheckPointID, sc.OriginalQuery, ar.Requester)
+		if !isInterrupt {
+			saveResult(ctx, ar.ID, nil, err)
+			return nil, nil, fmt.Errorf("Failed to invoke graph: %w", err)
+		}
+		if regErr != nil {
+			return nil, nil, fmt.Errorf("Failed to save approval: %w", regErr)
+		}
+		saveResult(ctx, ar.ID, nil, fmt.Errorf("SQL 执行失败，已重新生成并提交审批 %s", next.ID))
+		return nil, next, nil
+	}
+
+	result := resultMessages(msgs)
+	saveResult(ctx, ar.ID, result, nil)
+	return result, nil, nil
+}
+
+// resultMessages 将图的输出转换为带事件类型的结果，忽略空消息
+func resultMessages(msgs []*schema.Message) []approval.ResultMessage {
+	result := make([]approval.ResultMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Content == "" {
+			continue
+		}
+		event := flow.EventMessage
+		if e, ok := msg.Extra[flow.EventKey].(string); ok && e != "" {
+			event = e
+		}
+		result = append(result, approval.ResultMessage{Event: event, Content: msg.Content})
+	}
+	return result
+}
+
+// castVote 记录一票审批，vote.Approver 必须是已认证的身份
+func castVote(c *gin.Context, approvalID string, vote approval.Vote) (*approval.Request, bool, bool) {
+	ar, ready, err := approvalInbox.Approve(c.Request.Context(), approvalID, vote)
+	if err != nil {
+		writeApprovalError(c, err)
+		return nil, false, false
+	}
+	return ar, ready, true
+}
+
+// rejectApproval 拒绝审批请求并把原会话迁移到待补充状态，失败时直接写入响应
+func rejectApproval(c *gin.Context, approvalID string, vote approval.Vote) bool {
+	if _, err := rejectPending(c.Request.Context(), approvalID, vote); err != nil {
+		writeApprovalError(c, err)
+		return false
+	}
+	return true
+}
+
+// rejectPending 拒绝审批请求并把原会话迁移到待补充状态，vote.Approver 必须是已认证的身份
+func rejectPending(ctx context.Context, approvalID string, vote approval.Vote) (*approval.Request, error) {
+	ar, err := approvalInbox.Reject(ctx, approvalID, vote)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = takePending(ctx, ar.SessionID, ar.ID, func(sc *storage.SessionContext) {
+		*sc = storage.SessionContext{
+			State:         storage.SessionWaitingRefine,
+			OriginalQuery: sc.OriginalQuery,
+			RejectReason:  vote.Reason,
+		}
+	})
+	if err != nil {
+		// 会话已过期或已开始新的对话时不影响拒绝结果
+		log.Printf("拒绝审批后更新会话失败: approvalID=%s, sessionID=%s, err=%v", ar.ID, ar.SessionID, err)
+	}
+
+	audit.WriteDecision(ctx, "approval", audit.DecisionRejected, audit.Record{
+		SessionID: ar.SessionID,
+		UserID:    ar.Requester,
+		Query:     ar.OriginalQuery,
+		SQL:       ar.SQL,
+		Approver:  vote.Approver,
+	})
+
+	fmt.Printf(">>> Reject: sessionID=%s, approvalID=%s, reason=%s\n", ar.SessionID, ar.ID, vote.Reason)
+	return ar, nil
+}
+
+// resumeContext 以原会话身份恢复执行，多位审批人的决定合并为一个 ApprovalDecision
+func resumeContext(ctx context.Context, ar *approval.Request) context.Context {
+	approvers := make([]string, 0, len(ar.Approvals))
+	reasons := make([]string, 0, len(ar.Approvals))
+	for _, v := range ar.Approvals {
+		approvers = append(approvers, v.Approver)
+		if v.Reason != "" {
+			reasons = append(reasons, v.Reason)
+		}
+	}
+
+	ctx = context.WithValue(ctx, "session_id", ar.SessionID)
+	return compose.ResumeWithData(ctx, ar.InterruptID, &flow.ApprovalDecision{
+		SQL:      ar.SQL,
+		Reason:   strings.Join(reasons, "；"),
+		Approver: strings.Join(approvers, ","),
+	})
+}
+
+func waitingResponse(ar *approval.Request) gin.H {
+	return gin.H{
+		"status":             "waiting_approvals",
+		"answer":             fmt.Sprintf("已记录审批（%d/%d），等待其他审批人批准。", len(ar.Approvals), ar.Required),
+		"session_id":         ar.SessionID,
+		"interrupt_id":       ar.InterruptID,
+		"approval_id":        ar.ID,
+		"approvals":          ar.Approvals,
+		"required_approvals": ar.Required,
+	}
+}
+
+func saveResult(ctx context.Context, approvalID string, result []approval.ResultMessage, execErr error) {
+	if err := approvalInbox.SaveResult(ctx, approvalID, result, execErr); err != nil {
+		log.Printf("保存审批执行结果失败: approvalID=%s, err=%v", approvalID, err)
+	}
+}
+
+func writeApprovalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "审批请求不存在或已过期"})
+	case errors.Is(err, approval.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, approval.ErrBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, approval.ErrNotPending), errors.Is(err, approval.ErrDuplicateVote), errors.Is(err, approval.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval: " + err.Error()})
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"go-agent/tool/audit"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryAudit 查询数据库操作审计记录，供合规审查使用。
+// 支持 session_id、user_id、approver、from、to（RFC3339）、only_errors、limit 参数
+func QueryAudit(c *gin.Context) {
+	f := audit.Filter{
+		SessionID:  c.Query("session_id"),
+		UserID:     c.Query("user_id"),
+		Approver:   c.Query("approver"),
+		OnlyErrors: c.Query("only_errors") == "true",
+	}
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from 格式错误，应为 RFC3339: " + err.Error()})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to 格式错误，应为 RFC3339: " + err.Error()})
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为整数"})
+			return
+		}
+	}
+
+	records, err := audit.Query(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query audit log: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"records": records, "total": len(records)})
+}
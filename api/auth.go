@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto/subtle"
+	"go-agent/config"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey 已认证身份在 gin.Context 中的键
+const principalKey = "principal"
+
+// identityToken 一个审批令牌及其对应的身份
+type identityToken struct {
+	token    []byte
+	identity string
+}
+
+// identityTokens 由 APPROVAL_TOKENS 解析，在 initStores 中初始化
+var identityTokens []identityToken
+
+// parseIdentityTokens 解析 "t0k3n-a:alice,t0k3n-b:bob" 格式的令牌列表
+func parseIdentityTokens(raw string) []identityToken {
+	tokens := make([]identityToken, 0)
+	for _, item := range strings.Split(raw, ",") {
+		token, identity, ok := strings.Cut(strings.TrimSpace(item), ":")
+		token, identity = strings.TrimSpace(token), strings.TrimSpace(identity)
+		if !ok || token == "" || identity == "" {
+			continue
+		}
+		tokens = append(tokens, identityToken{token: []byte(token), identity: identity})
+	}
+	return tokens
+}
+
+// authenticate 识别 HTTP 调用方的身份
+func authenticate(c *gin.Context) string {
+	return identityFromHeader(c.Request.Header)
+}
+
+// identityFromHeader 配置了 APPROVAL_IDENTITY_HEADER 时读取网关注入的请求头，否则按 Authorization: Bearer 令牌查找。
+// 无法识别时返回空字符串，请求体中的用户名从不作为身份
+func identityFromHeader(h http.Header) string {
+	if header := config.Cfg.ApprovalConf.IdentityHeader; header != "" {
+		return strings.TrimSpace(h.Get(header))
+	}
+	token, ok := strings.CutPrefix(h.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ""
+	}
+	identity := ""
+	for _, t := range identityTokens {
+		// 逐个做常量时间比较，避免通过响应时间猜测令牌
+		if subtle.ConstantTimeCompare(t.token, []byte(token)) == 1 {
+			identity = t.identity
+		}
+	}
+	return identity
+}
+
+// RequireIdentity 审批接口的认证中间件，未认证的请求返回 401
+func RequireIdentity(c *gin.Context) {
+	identity := authenticate(c)
+	if identity == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "审批需要认证：请携带 Authorization: Bearer <APPROVAL_TOKENS 中的令牌>"})
+		return
+	}
+	c.Set(principalKey, identity)
+	c.Next()
+}
+
+// principal 返回 RequireIdentity 认证的身份
+func principal(c *gin.Context) string {
+	return c.GetString(principalKey)
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go-agent/flow"
+	"go-agent/tool/approval"
+	"go-agent/tool/storage"
 	"io"
 	"net/http"
 	"strings"
@@ -21,14 +24,23 @@ type FinalGraphResponse struct {
 	SessionID string `json:"session_id,omitempty"`
 }
 
-type sessionContext struct {
-	InterruptID   string
-	CheckPointID  string
-	OriginalQuery string
-	WaitingRefine bool
+// sessionStore 持久化 HITL 会话状态，Redis 不可用时降级为内存模式，在 Run 中初始化
+var sessionStore *storage.SessionStore
+
+var errInterruptMismatch = errors.New("interrupt_id 与待审批的 SQL 不匹配")
+
+type ApproveRequest struct {
+	SessionID   string `json:"session_id,omitempty"`
+	InterruptID string `json:"interrupt_id" binding:"required"`
+	SQL         string `json:"sql,omitempty"` // 审批人修改后的 SQL，为空时执行原 SQL
+	Reason      string `json:"reason,omitempty"`
 }
 
-var sessionContextMap = make(map[string]*sessionContext)
+type RejectRequest struct {
+	SessionID   string `json:"session_id,omitempty"`
+	InterruptID string `json:"interrupt_id" binding:"required"`
+	Reason      string `json:"reason,omitempty"`
+}
 
 // FinalGraphInvoke 处理总控图的调用请求，支持流式输出
 func FinalGraphInvoke(c *gin.Context) {
@@ -42,6 +54,9 @@ func FinalGraphInvoke(c *gin.Context) {
 	if sessionID == "" {
 		sessionID = "default-session"
 	}
+	req.SessionID = sessionID
+	// 申请人只认认证结果，避免通过请求体冒用他人身份绕过“申请人不能自审”；未认证时为空，由 registerInterrupt 使用会话 ID
+	req.UserID = authenticate(c)
 
 	ctx := c.Request.Context()
 
@@ -49,55 +64,25 @@ func FinalGraphInvoke(c *gin.Context) {
 
 	invokeCtx := context.WithValue(ctx, "session_id", sessionID)
 
-	// 第一次判断：被打断进行批准拒绝
-	if sc, ok := sessionContextMap[sessionID]; ok && sc.InterruptID != "" {
-		upper := strings.ToUpper(strings.TrimSpace(req.Query))
-		if upper == "YES" || upper == "执行" || upper == "批准执行" {
-			// 如果是批准 使用保存的CheckPointID恢复
-			fmt.Printf(">>> Approve: sessionID=%s, interruptID=%s\n", sessionID, sc.InterruptID)
-			invokeCtx = compose.ResumeWithData(invokeCtx, sc.InterruptID, req.Query)
-
-			runnable, err := flow.GetFinalGraph()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get graph: " + err.Error()})
-				return
-			}
-
-			reader, err := runnable.Stream(invokeCtx, req, compose.WithCheckPointID(sc.CheckPointID))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream graph: " + err.Error()})
-				return
-			}
-			defer reader.Close()
-
-			delete(sessionContextMap, sessionID)
-			streamResponse(c, reader)
-			return
+	// 原子地开始一轮新的对话：待补充状态会被消费，存在待审批的 SQL 时需要先通过审批接口处理
+	query, err := startTurn(ctx, sessionID, req.Query)
+	if errors.Is(err, storage.ErrInvalidTransition) {
+		resp := gin.H{
+			"error":      "当前会话有待审批的 SQL，请先调用 /api/final/approve 或 /api/final/reject",
+			"session_id": sessionID,
 		}
-
-		// 如果是拒绝 返回补充信息提示进入refine
-		fmt.Printf(">>> Reject: sessionID=%s\n", sessionID)
-		sessionContextMap[sessionID] = &sessionContext{
-			OriginalQuery: sc.OriginalQuery,
-			WaitingRefine: true,
+		if sc, err := sessionStore.GetSession(ctx, sessionID); err == nil {
+			resp["interrupt_id"] = sc.InterruptID
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":     "need_refinement",
-			"answer":     "SQL已拒绝。请补充您的需求说明或表结构约束信息，我将根据您的补充重新生成SQL。",
-			"session_id": sessionID,
-		})
+		c.JSON(http.StatusConflict, resp)
 		return
 	}
-
-	// 处于refine时 合并用户补充信息
-	if sc, ok := sessionContextMap[sessionID]; ok && sc.WaitingRefine {
-		fmt.Printf(">>> Refine: sessionID=%s, original=%s, supplement=%s\n",
-			sessionID, sc.OriginalQuery, req.Query)
-		req.Query = fmt.Sprintf("%s（补充约束：%s）", sc.OriginalQuery, req.Query)
-		delete(sessionContextMap, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session: " + err.Error()})
+		return
 	}
+	req.Query = query
 
-	// 没有打断时
 	runnable, err := flow.GetFinalGraph()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get graph: " + err.Error()})
@@ -110,29 +95,65 @@ func FinalGraphInvoke(c *gin.Context) {
 	reader, err := runnable.Stream(invokeCtx, req, compose.WithCheckPointID(checkPointID))
 	if err != nil {
 		// 处理中断
-		if info, ok := compose.ExtractInterruptInfo(err); ok {
-			interruptID := info.InterruptContexts[0].ID
-			sql := info.InterruptContexts[0].Info.(string)
-
-			// 提取原始提问
-			originalQuery := req.Query
-			if idx := strings.Index(originalQuery, "（补充约束："); idx > 0 {
-				originalQuery = originalQuery[:idx]
-			}
+		if handleInterrupt(c, err, sessionID, checkPointID, originalQuery(req.Query), req.UserID, "检测到 SQL 执行请求，请确认是否执行？") {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream graph: " + err.Error()})
+		return
+	}
+	defer reader.Close()
 
-			// 保存会话上下文
-			sessionContextMap[sessionID] = &sessionContext{
-				InterruptID:   interruptID,
-				CheckPointID:  checkPointID,
-				OriginalQuery: originalQuery,
-			}
+	streamResponse(c, reader)
+}
 
-			c.JSON(http.StatusOK, gin.H{
-				"status":       "need_approval",
-				"answer":       fmt.Sprintf("检测到 SQL 执行请求，请确认是否执行？\n\n\n%s\n```", sql),
-				"session_id":   sessionID,
-				"interrupt_id": interruptID,
-			})
+// FinalGraphApprove 批准待执行的 SQL，可携带修改后的 SQL。票数达到策略要求时使用保存的 CheckPointID 恢复执行，
+// 否则返回 waiting_approvals 等待其他审批人
+func FinalGraphApprove(c *gin.Context) {
+	var req ApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = "default-session"
+	}
+	sc, ok := pendingSession(c, sessionID, req.InterruptID)
+	if !ok {
+		return
+	}
+
+	ar, ready, ok := castVote(c, sc.ApprovalID, approval.Vote{Approver: principal(c), Reason: req.Reason, SQL: req.SQL})
+	if !ok {
+		return
+	}
+	if !ready {
+		c.JSON(http.StatusOK, waitingResponse(ar))
+		return
+	}
+
+	// 审批通过后会话回到空闲状态，同一个中断只能被恢复一次
+	sc, err := takePending(c.Request.Context(), sessionID, ar.ID, func(sc *storage.SessionContext) {
+		*sc = storage.SessionContext{State: storage.SessionDone}
+	})
+	if err != nil {
+		writeSessionError(c, sessionID, err)
+		return
+	}
+
+	fmt.Printf(">>> Approve: sessionID=%s, interruptID=%s, approvers=%d\n", sessionID, sc.InterruptID, len(ar.Approvals))
+
+	runnable, err := flow.GetFinalGraph()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get graph: " + err.Error()})
+		return
+	}
+
+	reader, err := runnable.Stream(resumeContext(c.Request.Context(), ar), flow.FinalGraphRequest{SessionID: sessionID}, compose.WithCheckPointID(sc.CheckPointID))
+	if err != nil {
+		// SQL 执行失败后重新生成的 SQL 需要再次审批
+		if handleInterrupt(c, err, sessionID, sc.CheckPointID, sc.OriginalQuery, ar.Requester, "SQL 执行失败，已根据错误信息重新生成，请确认是否执行修正后的 SQL？") {
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream graph: " + err.Error()})
@@ -140,10 +161,163 @@ func FinalGraphInvoke(c *gin.Context) {
 	}
 	defer reader.Close()
 
-	delete(sessionContextMap, sessionID)
 	streamResponse(c, reader)
 }
 
+// FinalGraphReject 拒绝待执行的 SQL，会话进入补充信息状态，下一次提问将与原始问题合并后重新生成
+func FinalGraphReject(c *gin.Context) {
+	var req RejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = "default-session"
+	}
+	sc, ok := pendingSession(c, sessionID, req.InterruptID)
+	if !ok {
+		return
+	}
+	if rejectApproval(c, sc.ApprovalID, approval.Vote{Approver: principal(c), Reason: req.Reason}) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":     "need_refinement",
+			"answer":     "SQL已拒绝。请补充您的需求说明或表结构约束信息，我将根据您的补充重新生成SQL。",
+			"session_id": sessionID,
+		})
+	}
+}
+
+// startTurn 原子地开始一轮新的对话，会话处于待补充状态时把拒绝原因与补充信息合并进原始问题。
+// 会话存在待审批的 SQL 时返回 storage.ErrInvalidTransition
+func startTurn(ctx context.Context, sessionID, query string) (string, error) {
+	var refine *storage.SessionContext
+	_, err := sessionStore.Transition(ctx, sessionID, []storage.SessionState{storage.SessionDone, storage.SessionWaitingRefine}, func(sc *storage.SessionContext) error {
+		if sc.State == storage.SessionWaitingRefine {
+			prev := *sc
+			refine = &prev
+		}
+		*sc = storage.SessionContext{State: storage.SessionDone}
+		return nil
+	})
+	if err != nil || refine == nil {
+		return query, err
+	}
+
+	fmt.Printf(">>> Refine: sessionID=%s, original=%s, reason=%s, supplement=%s\n",
+		sessionID, refine.OriginalQuery, refine.RejectReason, query)
+	supplement := query
+	if refine.RejectReason != "" {
+		supplement = refine.RejectReason + "；" + supplement
+	}
+	return fmt.Sprintf("%s（补充约束：%s）", refine.OriginalQuery, supplement), nil
+}
+
+// originalQuery 去掉 startTurn 合并的补充约束，得到用户的原始提问
+func originalQuery(query string) string {
+	if idx := strings.Index(query, "（补充约束："); idx > 0 {
+		return query[:idx]
+	}
+	return query
+}
+
+// pendingSession 获取等待审批的会话并校验中断 ID，失败时直接写入响应
+func pendingSession(c *gin.Context, sessionID, interruptID string) (*storage.SessionContext, bool) {
+	sc, err := sessionStore.GetSession(c.Request.Context(), sessionID)
+	switch {
+	case errors.Is(err, storage.ErrSessionNotFound) || (err == nil && sc.State != storage.SessionPendingApproval):
+		err = storage.ErrInvalidTransition
+	case err == nil && sc.InterruptID != interruptID:
+		err = errInterruptMismatch
+	}
+	if err != nil {
+		writeSessionError(c, sessionID, err)
+		return nil, false
+	}
+	return sc, true
+}
+
+// takePending 原子地取出等待该审批的会话并迁移到 next 设置的状态，返回迁移前的会话
+func takePending(ctx context.Context, sessionID, approvalID string, next func(sc *storage.SessionContext)) (*storage.SessionContext, error) {
+	var pending storage.SessionContext
+	_, err := sessionStore.Transition(ctx, sessionID, []storage.SessionState{storage.SessionPendingApproval}, func(sc *storage.SessionContext) error {
+		if sc.ApprovalID != approvalID {
+			return errInterruptMismatch
+		}
+		pending = *sc
+		next(sc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func writeSessionError(c *gin.Context, sessionID string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidTransition):
+		c.JSON(http.StatusNotFound, gin.H{"error": "当前会话没有待审批的 SQL，可能已处理或已过期", "session_id": sessionID})
+	case errors.Is(err, errInterruptMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "session_id": sessionID})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session: " + err.Error()})
+	}
+}
+
+// handleInterrupt 处理审批中断：登记审批请求并返回待审批的 SQL，非中断错误返回 false
+func handleInterrupt(c *gin.Context, err error, sessionID, checkPointID, originalQuery, requester, prompt string) bool {
+	ar, ok, err := registerInterrupt(c.Request.Context(), err, sessionID, checkPointID, originalQuery, requester)
+	if !ok {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save approval: " + err.Error()})
+		return true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":             "need_approval",
+		"answer":             approvalPrompt(prompt, ar),
+		"session_id":         sessionID,
+		"interrupt_id":       ar.InterruptID,
+		"approval_id":        ar.ID,
+		"required_approvals": ar.Required,
+		"sql":                ar.SQL,
+		"risk":               ar.Risk,
+		"reasons":            ar.Reasons,
+		"tables":             ar.Tables,
+		"preview":            ar.Preview,
+	})
+	return true
+}
+
+// approvalPrompt 生成待审批提示，包含风险等级、审批人数、执行计划预估与待执行的 SQL
+func approvalPrompt(prompt string, ar *approval.Request) string {
+	answer := fmt.Sprintf("%s\n风险等级：%s", prompt, ar.Risk)
+	if len(ar.Reasons) > 0 {
+		answer += "（" + strings.Join(ar.Reasons, "；") + "）"
+	}
+	if ar.Required > 1 {
+		answer += fmt.Sprintf("\n需要 %d 位审批人批准", ar.Required)
+	}
+	if p := ar.Preview; p != nil {
+		if p.Error != "" {
+			answer += "\n执行计划获取失败：" + p.Error
+		} else {
+			answer += fmt.Sprintf("\n预计扫描行数：%d", p.EstimatedRows)
+			if len(p.Indexes) > 0 {
+				answer += "，使用索引：" + strings.Join(p.Indexes, "、")
+			}
+			for _, w := range p.Warnings {
+				answer += "\n警告：" + w
+			}
+		}
+	}
+	return fmt.Sprintf("%s\n\n\n%s\n```", answer, ar.SQL)
+}
+
 func streamResponse(c *gin.Context, reader *schema.StreamReader[[]*schema.Message]) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -167,7 +341,11 @@ func streamResponse(c *gin.Context, reader *schema.StreamReader[[]*schema.Messag
 		}
 		for _, msg := range chunk {
 			if msg.Content != "" {
-				c.SSEvent("message", msg.Content)
+				event := flow.EventMessage
+				if e, ok := msg.Extra[flow.EventKey].(string); ok && e != "" {
+					event = e
+				}
+				c.SSEvent(event, msg.Content)
 				if f, ok := w.(http.Flusher); ok {
 					f.Flush()
 				}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"go-agent/tool/intent"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntentFeedbackRequest 意图误判反馈
+type IntentFeedbackRequest struct {
+	SessionID string `json:"session_id,omitempty"`
+	Query     string `json:"query" binding:"required"`
+	Predicted string `json:"predicted,omitempty"`         // 系统识别的意图
+	Expected  string `json:"expected" binding:"required"` // 正确的意图
+	Comment   string `json:"comment,omitempty"`
+}
+
+// IntentFeedback 记录意图误判，与路由日志一起用于离线评估和补充路由示例
+func IntentFeedback(c *gin.Context) {
+	var req IntentFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+	intent.Record(&intent.LogRecord{
+		Type:      intent.RecordMisroute,
+		SessionID: req.SessionID,
+		Query:     req.Query,
+		Intent:    req.Predicted,
+		Expected:  req.Expected,
+		Comment:   req.Comment,
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
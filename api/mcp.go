@@ -0,0 +1,345 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-agent/flow"
+	"go-agent/rag/rag_flow"
+	"go-agent/tool/approval"
+	"go-agent/tool/intent"
+	"go-agent/tool/storage"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/document"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type RetrieveInput struct {
+	Query string `json:"query" jsonschema:"检索问题"`
+	TopK  int    `json:"top_k,omitempty" jsonschema:"最多返回的文档数，默认使用 MILVUS_TOPK"`
+}
+
+type RetrievedDoc struct {
+	ID       string         `json:"id"`
+	Content  string         `json:"content"`
+	Score    float64        `json:"score"`
+	MetaData map[string]any `json:"metadata,omitempty"`
+}
+
+type RetrieveOutput struct {
+	Documents []RetrievedDoc `json:"documents"`
+}
+
+type IngestInput struct {
+	FileName string `json:"file_name" jsonschema:"文件名，按扩展名选择解析器，如 manual.pdf、notes.md"`
+	Content  string `json:"content" jsonschema:"文件内容，二进制文件需使用 base64 编码"`
+	Base64   bool   `json:"base64,omitempty" jsonschema:"content 是否为 base64 编码"`
+}
+
+type IngestOutput struct {
+	DocumentIDs []string `json:"document_ids"`
+	ChunkCount  int      `json:"chunk_count"`
+}
+
+type NL2SQLInput struct {
+	Query     string `json:"query" jsonschema:"自然语言问题"`
+	SessionID string `json:"session_id,omitempty" jsonschema:"会话 ID，SQL 被拒绝后用同一会话补充需求；为空时每次调用使用新会话"`
+	UserID    string `json:"user_id,omitempty" jsonschema:"提问人，仅在 MCP 连接未认证时作为申请人展示；已认证时以认证身份为准"`
+}
+
+type NL2SQLOutput struct {
+	Status    string                   `json:"status" jsonschema:"done：已执行；need_approval：等待审批，可通过 approval_status 查询结果；waiting_approvals：已确认，等待其他审批人；rejected：已拒绝"`
+	Answer    string                   `json:"answer,omitempty"`
+	SessionID string                   `json:"session_id"`
+	Events    []approval.ResultMessage `json:"events,omitempty" jsonschema:"执行结果，event 为 message、analysis、chart 等"`
+	Approval  *approval.Request        `json:"approval,omitempty"`
+}
+
+type ApprovalStatusInput struct {
+	ApprovalID string `json:"approval_id" jsonschema:"nl2sql 返回的审批 ID"`
+}
+
+// approvalSchema 通过 elicitation 向调用方确认 SQL 时使用的表单
+var approvalSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"approve": map[string]any{"type": "boolean", "description": "是否执行该 SQL"},
+		"sql":     map[string]any{"type": "string", "description": "修改后的 SQL，为空时执行原 SQL"},
+		"reason":  map[string]any{"type": "string", "description": "审批意见或拒绝原因"},
+	},
+	"required": []string{"approve"},
+}
+
+// NewAgentMCPServer 将 RAG 检索、文档入库与 NL2SQL 发布为 MCP 工具，HTTP 与 stdio 共用
+func NewAgentMCPServer() *mcp.Server {
+	s := mcp.NewServer(&mcp.Implementation{
+		Name:    "go-agent",
+		Version: "1.0.0",
+	}, nil)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "rag_retrieve",
+		Description: "从知识库中检索与问题相关的文档片段（向量 + 全文混合检索）",
+	}, mcpRetrieve)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "rag_ingest",
+		Description: "将文档解析、切分后写入知识库，返回文档片段 ID",
+	}, mcpIngest)
+	mcp.AddTool(s, &mcp.Tool{
+		Name: "nl2sql",
+		Description: "用自然语言查询业务数据库：生成 SQL、安全检查后执行并返回结果。" +
+			"需要人工审批时，支持 elicitation 的客户端会被当场询问，否则返回 need_approval 与审批 ID",
+	}, mcpNL2SQL)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "approval_status",
+		Description: "查询 nl2sql 提交的审批请求的状态，审批通过后包含执行结果",
+	}, mcpApprovalStatus)
+	return s
+}
+
+// RunMCPStdio 以 stdio 方式运行 go-agent 的 MCP 服务器，替代 HTTP 服务。
+// stdout 为协议输出，调用方需事先把 os.Stdout 改写到 stderr，避免日志混入协议
+func RunMCPStdio(ctx context.Context, stdout *os.File) error {
+	if err := initStores(); err != nil {
+		return err
+	}
+	return NewAgentMCPServer().Run(ctx, &mcp.IOTransport{Reader: os.Stdin, Writer: stdout})
+}
+
+func mcpRetrieve(ctx context.Context, req *mcp.CallToolRequest, in RetrieveInput) (*mcp.CallToolResult, RetrieveOutput, error) {
+	if strings.TrimSpace(in.Query) == "" {
+		return nil, RetrieveOutput{}, fmt.Errorf("query 不能为空")
+	}
+	runner, err := rag_flow.GetRetrieverGraph()
+	if err != nil {
+		return nil, RetrieveOutput{}, err
+	}
+	docs, err := runner.Invoke(ctx, []*schema.Message{schema.UserMessage(in.Query)})
+	if err != nil {
+		return nil, RetrieveOutput{}, err
+	}
+	if in.TopK > 0 && len(docs) > in.TopK {
+		docs = docs[:in.TopK]
+	}
+
+	out := RetrieveOutput{Documents: make([]RetrievedDoc, 0, len(docs))}
+	for _, doc := range docs {
+		out.Documents = append(out.Documents, RetrievedDoc{
+			ID:       doc.ID,
+			Content:  doc.Content,
+			Score:    doc.Score(),
+			MetaData: doc.MetaData,
+		})
+	}
+	return nil, out, nil
+}
+
+func mcpIngest(ctx context.Context, req *mcp.CallToolRequest, in IngestInput) (*mcp.CallToolResult, IngestOutput, error) {
+	// 只保留文件名，防止写到临时目录之外
+	name := filepath.Base(in.FileName)
+	if name == "." || name == string(filepath.Separator) {
+		return nil, IngestOutput{}, fmt.Errorf("非法的文件名: %s", in.FileName)
+	}
+	data := []byte(in.Content)
+	if in.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(in.Content)
+		if err != nil {
+			return nil, IngestOutput{}, fmt.Errorf("content 不是合法的 base64: %w", err)
+		}
+		data = decoded
+	}
+	if len(data) == 0 {
+		return nil, IngestOutput{}, fmt.Errorf("content 不能为空")
+	}
+	const maxFileSize = 50 << 20 // 与上传接口一致
+	if len(data) > maxFileSize {
+		return nil, IngestOutput{}, fmt.Errorf("文件大小超过限制 (最大 50MB)")
+	}
+
+	tempDir := filepath.Join(os.TempDir(), "go-agent-uploads")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, IngestOutput{}, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	tempFilePath := filepath.Join(tempDir, fmt.Sprintf("%d_%s", time.Now().UnixNano(), name))
+	if err := os.WriteFile(tempFilePath, data, 0644); err != nil {
+		return nil, IngestOutput{}, fmt.Errorf("保存文件失败: %w", err)
+	}
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			log.Printf("删除临时文件失败: %v, 文件路径: %s", err, tempFilePath)
+		}
+	}()
+
+	indexingRunner, err := rag_flow.GetIndexingGraph()
+	if err != nil {
+		return nil, IngestOutput{}, err
+	}
+	ids, err := indexingRunner.Invoke(ctx, document.Source{URI: tempFilePath})
+	if err != nil {
+		return nil, IngestOutput{}, fmt.Errorf("索引文档失败: %w", err)
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return nil, IngestOutput{DocumentIDs: ids, ChunkCount: len(ids)}, nil
+}
+
+func mcpNL2SQL(ctx context.Context, req *mcp.CallToolRequest, in NL2SQLInput) (*mcp.CallToolResult, NL2SQLOutput, error) {
+	sessionID := in.SessionID
+	if sessionID == "" {
+		sessionID = "mcp-" + uuid.NewString()
+	}
+	out := NL2SQLOutput{SessionID: sessionID}
+	identity := mcpIdentity(req)
+	requester := mcpRequester(identity, in.UserID)
+
+	query, err := startTurn(ctx, sessionID, in.Query)
+	if errors.Is(err, storage.ErrInvalidTransition) {
+		return nil, out, fmt.Errorf("会话 %s 有待审批的 SQL，请等待审批完成或使用新的 session_id", sessionID)
+	}
+	if err != nil {
+		return nil, out, err
+	}
+
+	runnable, err := flow.GetFinalGraph()
+	if err != nil {
+		return nil, out, err
+	}
+
+	log.Printf("MCP nl2sql: sessionID=%s, query=%s", sessionID, query)
+	checkPointID := fmt.Sprintf("%s-%d", sessionID, time.Now().UnixNano())
+	msgs, err := runnable.Invoke(context.WithValue(ctx, "session_id", sessionID), flow.FinalGraphRequest{
+		Query:     query,
+		SessionID: sessionID,
+		UserID:    requester,
+		// 调用方已明确要走 NL2SQL，跳过意图识别
+		Route: string(intent.TargetAnalysis),
+	}, compose.WithCheckPointID(checkPointID))
+	if err == nil {
+		out.Status = "done"
+		out.Events = resultMessages(msgs)
+		out.Answer = answerOf(out.Events)
+		return nil, out, nil
+	}
+
+	ar, isInterrupt, regErr := registerInterrupt(ctx, err, sessionID, checkPointID, originalQuery(query), requester)
+	if !isInterrupt {
+		return nil, out, err
+	}
+	if regErr != nil {
+		return nil, out, fmt.Errorf("保存审批请求失败: %w", regErr)
+	}
+	return awaitApproval(ctx, req.Session, identity, ar, out)
+}
+
+// mcpRequester 连接已认证时以认证身份作为申请人，避免通过 user_id 冒用他人身份绕过“申请人不能自审”；
+// 未认证时 user_id 只作为申请人展示
+func mcpRequester(identity, userID string) string {
+	if identity != "" {
+		return identity
+	}
+	return userID
+}
+
+// mcpIdentity 从 Streamable HTTP 请求头识别调用方身份，规则与审批接口相同；stdio 等没有认证信息的传输返回空字符串
+func mcpIdentity(req *mcp.CallToolRequest) string {
+	if req == nil || req.Extra == nil || req.Extra.Header == nil {
+		return ""
+	}
+	return identityFromHeader(req.Extra.Header)
+}
+
+func mcpApprovalStatus(ctx context.Context, req *mcp.CallToolRequest, in ApprovalStatusInput) (*mcp.CallToolResult, *approval.Request, error) {
+	r, err := approvalInbox.Get(ctx, in.ApprovalID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, r, nil
+}
+
+// awaitApproval 客户端支持 elicitation、连接身份已认证、不是申请人且有权审批时当场确认，执行失败后修正的 SQL 继续确认；
+// 否则返回 need_approval，由审批人在收件箱中处理，调用方通过 approval_status 查询结果
+func awaitApproval(ctx context.Context, session *mcp.ServerSession, approver string, ar *approval.Request, out NL2SQLOutput) (*mcp.CallToolResult, NL2SQLOutput, error) {
+	prompt := "检测到 SQL 执行请求，请确认是否执行？"
+	for {
+		out.Status, out.Approval = "need_approval", ar
+		out.Answer = approvalPrompt(prompt, ar)
+		// CanApprove 同时拒绝空身份与申请人本人
+		if approver == "" || !canElicit(session) || !approvalInbox.CanApprove(ar, approver) {
+			return nil, out, nil
+		}
+
+		res, err := session.Elicit(ctx, &mcp.ElicitParams{Message: out.Answer, RequestedSchema: approvalSchema})
+		if err != nil {
+			// 确认失败时保留审批请求，仍可在收件箱中处理
+			log.Printf("MCP elicitation 失败: approvalID=%s, err=%v", ar.ID, err)
+			return nil, out, nil
+		}
+		if res.Action == "cancel" {
+			return nil, out, nil
+		}
+		approve, _ := res.Content["approve"].(bool)
+		reason, _ := res.Content["reason"].(string)
+		if res.Action != "accept" || !approve {
+			rejected, err := rejectPending(ctx, ar.ID, approval.Vote{Approver: approver, Reason: reason})
+			if err != nil {
+				return nil, out, err
+			}
+			out.Status, out.Approval = "rejected", rejected
+			out.Answer = "SQL 已拒绝。可使用相同的 session_id 补充需求说明，将与原问题合并后重新生成。"
+			return nil, out, nil
+		}
+
+		sql, _ := res.Content["sql"].(string)
+		voted, ready, err := approvalInbox.Approve(ctx, ar.ID, approval.Vote{Approver: approver, Reason: reason, SQL: sql})
+		if err != nil {
+			return nil, out, err
+		}
+		out.Approval = voted
+		if !ready {
+			out.Status = "waiting_approvals"
+			out.Answer = fmt.Sprintf("已记录审批（%d/%d），等待其他审批人批准。", len(voted.Approvals), voted.Required)
+			return nil, out, nil
+		}
+
+		result, next, err := executeApproved(ctx, voted)
+		if err != nil {
+			return nil, out, err
+		}
+		if next == nil {
+			out.Status, out.Events = "done", result
+			out.Answer = answerOf(result)
+			return nil, out, nil
+		}
+		ar = next
+		prompt = "SQL 执行失败，已根据错误信息重新生成，请确认是否执行修正后的 SQL？"
+	}
+}
+
+func canElicit(session *mcp.ServerSession) bool {
+	if session == nil {
+		return false
+	}
+	params := session.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
+
+// answerOf 拼接普通消息作为文本回答，图表等结构化结果保留在 events 中
+func answerOf(result []approval.ResultMessage) string {
+	var parts []string
+	for _, m := range result {
+		if m.Event == flow.EventMessage {
+			parts = append(parts, m.Content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
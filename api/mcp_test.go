@@ -0,0 +1,49 @@
+package api
+
+import (
+	"go-agent/config"
+	"net/http"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestMCPRequester(t *testing.T) {
+	prev, prevTokens := config.Cfg, identityTokens
+	t.Cleanup(func() { config.Cfg, identityTokens = prev, prevTokens })
+	config.Cfg = &config.Config{}
+	identityTokens = parseIdentityTokens("t0k3n-a:alice")
+
+	withToken := func(token string) *mcp.CallToolRequest {
+		h := http.Header{}
+		if token != "" {
+			h.Set("Authorization", "Bearer "+token)
+		}
+		return &mcp.CallToolRequest{Extra: &mcp.RequestExtra{Header: h}}
+	}
+
+	tests := []struct {
+		name      string
+		req       *mcp.CallToolRequest
+		userID    string
+		identity  string
+		requester string
+	}{
+		{"认证身份优先于 user_id", withToken("t0k3n-a"), "bob", "alice", "alice"},
+		{"认证且未填 user_id", withToken("t0k3n-a"), "", "alice", "alice"},
+		{"未认证时 user_id 只作展示", withToken(""), "bob", "", "bob"},
+		{"令牌无效", withToken("wrong"), "bob", "", "bob"},
+		{"stdio 没有请求头", &mcp.CallToolRequest{}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := mcpIdentity(tt.req)
+			if identity != tt.identity {
+				t.Errorf("identity = %q, want %q", identity, tt.identity)
+			}
+			if got := mcpRequester(identity, tt.userID); got != tt.requester {
+				t.Errorf("requester = %q, want %q", got, tt.requester)
+			}
+		})
+	}
+}
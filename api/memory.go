@@ -0,0 +1,37 @@
+package api
+
+import (
+	"go-agent/tool/memory"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMemorySessions 按更新时间倒序列出未过期的会话记忆
+func ListMemorySessions(c *gin.Context) {
+	infos, err := memory.Default.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list memory sessions: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": infos, "total": len(infos)})
+}
+
+// GetMemorySession 查询会话的历史消息与摘要
+func GetMemorySession(c *gin.Context) {
+	sess, err := memory.Default.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get memory session: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sess)
+}
+
+// DeleteMemorySession 清空会话记忆，之后的对话不再带有历史与摘要
+func DeleteMemorySession(c *gin.Context) {
+	if err := memory.Default.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete memory session: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
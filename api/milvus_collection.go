@@ -1,6 +1,7 @@
 package api
 
 import (
+	"go-agent/rag/rag_flow"
 	"go-agent/rag/rag_tools/db"
 	"net/http"
 
@@ -75,6 +76,7 @@ func DeleteMilvusCollection(c *gin.Context) {
 		})
 		return
 	}
+	rag_flow.InvalidateCollectionCache(c.Request.Context(), collectionName)
 
 	c.JSON(http.StatusOK, MilvusDropCollectionResponse{
 		Success: true,
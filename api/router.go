@@ -1,22 +1,47 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/approval"
+	"go-agent/tool/storage"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// initStores 创建会话与审批存储，需在 Redis 初始化之后调用，Redis 不可用时使用内存模式
+func initStores() error {
+	var err error
+	sessionStore = storage.NewSessionStore()
+	sessionStore.StartJanitor(context.Background())
+	approvalInbox, err = approval.NewInbox()
+	if err != nil {
+		return fmt.Errorf("审批策略配置错误: %w", err)
+	}
+	approvalInbox.StartJanitor(context.Background())
+	identityTokens = parseIdentityTokens(config.Cfg.ApprovalConf.Tokens)
+	return nil
+}
+
 func Run() {
+	if err := initStores(); err != nil {
+		log.Fatalf("init stores fail: %v", err)
+	}
+
 	r := gin.Default()
 	r.MaxMultipartMemory = 50 << 20
 
 	// 添加 CORS 中间件
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -166,6 +191,39 @@ func Run() {
 	r.POST("/api/rag/chat/stream", RAGChatStream) // 新增流式接口
 	// 总控图（意图识别 + SQL/Chat）
 	r.POST("/api/final/invoke", FinalGraphInvoke)
+	// SQL 审批
+	r.POST("/api/final/approve", RequireIdentity, FinalGraphApprove)
+	r.POST("/api/final/reject", RequireIdentity, FinalGraphReject)
+	// 审批收件箱
+	r.GET("/api/approvals", ListApprovals)
+	r.GET("/api/approvals/:id", GetApproval)
+	r.POST("/api/approvals/:id/approve", RequireIdentity, ApproveApproval)
+	r.POST("/api/approvals/:id/reject", RequireIdentity, RejectApproval)
+	// 审计日志查询
+	r.GET("/api/audit", QueryAudit)
+	// 表结构知识库同步
+	r.POST("/api/schema/sync", SyncSchema)
+	// 业务术语与 NL2SQL 示例
+	r.GET("/api/sql/knowledge", ListSQLKnowledge)
+	r.POST("/api/sql/knowledge", CreateSQLKnowledge)
+	r.GET("/api/sql/knowledge/:id", GetSQLKnowledge)
+	r.PUT("/api/sql/knowledge/:id", UpdateSQLKnowledge)
+	r.DELETE("/api/sql/knowledge/:id", DeleteSQLKnowledge)
+	r.POST("/api/sql/knowledge/:id/verify", VerifySQLKnowledge)
+	// 意图误判反馈
+	r.POST("/api/intent/feedback", IntentFeedback)
+	// 会话记忆
+	r.GET("/api/memory/sessions", ListMemorySessions)
+	r.GET("/api/memory/sessions/:id", GetMemorySession)
+	r.DELETE("/api/memory/sessions/:id", DeleteMemorySession)
+
+	// go-agent 自身的 MCP 端点（Streamable HTTP）
+	if path := config.Cfg.MCPConf.AgentPath; path != "" {
+		agentServer := NewAgentMCPServer()
+		handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return agentServer }, nil)
+		r.Any(path, gin.WrapH(handler))
+		log.Printf("MCP 端点已注册: http://localhost:8080%s", path)
+	}
 
 	err = r.Run(":8080")
 	if err != nil {
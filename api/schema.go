@@ -0,0 +1,18 @@
+package api
+
+import (
+	"go-agent/rag/rag_flow"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncSchema 重新读取数据库表结构并增量同步到表结构知识库
+func SyncSchema(c *gin.Context) {
+	report, err := rag_flow.SyncSchema(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync schema: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "report": report})
+}
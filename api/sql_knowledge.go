@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"go-agent/rag/rag_flow"
+	"go-agent/rag/rag_tools/sqlknowledge"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSQLKnowledge 列出业务术语与 NL2SQL 示例，支持 kind（glossary/example）与 status（verified/proposed）参数
+func ListSQLKnowledge(c *gin.Context) {
+	entries, err := rag_flow.ListSQLKnowledge(c.Request.Context(), rag_flow.KnowledgeFilter{
+		Kind:   sqlknowledge.Kind(c.Query("kind")),
+		Status: sqlknowledge.Status(c.Query("status")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sql knowledge: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": len(entries)})
+}
+
+// GetSQLKnowledge 查询单个术语或示例
+func GetSQLKnowledge(c *gin.Context) {
+	e, err := rag_flow.GetSQLKnowledge(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeKnowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+// CreateSQLKnowledge 新增术语或示例，未指定 status 时直接参与 SQL 生成
+func CreateSQLKnowledge(c *gin.Context) {
+	var req sqlknowledge.Entry
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+	e, err := rag_flow.CreateSQLKnowledge(c.Request.Context(), &req)
+	if err != nil {
+		writeKnowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "entry": e})
+}
+
+// UpdateSQLKnowledge 整体替换条目内容并重新索引，kind 不可修改
+func UpdateSQLKnowledge(c *gin.Context) {
+	var req sqlknowledge.Entry
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+	e, err := rag_flow.UpdateSQLKnowledge(c.Request.Context(), c.Param("id"), func(e *sqlknowledge.Entry) error {
+		*e = req
+		return nil
+	})
+	if err != nil {
+		writeKnowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "entry": e})
+}
+
+// VerifySQLKnowledge 确认自动提议的示例，确认后参与 SQL 生成
+func VerifySQLKnowledge(c *gin.Context) {
+	e, err := rag_flow.UpdateSQLKnowledge(c.Request.Context(), c.Param("id"), func(e *sqlknowledge.Entry) error {
+		e.Status = sqlknowledge.StatusVerified
+		return nil
+	})
+	if err != nil {
+		writeKnowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "entry": e})
+}
+
+// DeleteSQLKnowledge 删除术语或示例，拒绝自动提议的示例也使用该接口
+func DeleteSQLKnowledge(c *gin.Context) {
+	if err := rag_flow.DeleteSQLKnowledge(c.Request.Context(), c.Param("id")); err != nil {
+		writeKnowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": c.Param("id")})
+}
+
+func writeKnowledgeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, rag_flow.ErrKnowledgeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, sqlknowledge.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sql knowledge: " + err.Error()})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"go-agent/config"
+	"go-agent/mcp_server"
+	"log"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// 以 stdio 方式运行的 MCP 服务器，供 MCP_SERVER_MODE=stdio 或其他 MCP 客户端使用。
+// stdout 用于协议通信，日志输出到 stderr
+func main() {
+	var err error
+	ctx := context.Background()
+	log.SetOutput(os.Stderr)
+
+	config.Cfg, err = config.LoadConfig()
+	if err != nil {
+		log.Fatal("警告: 未找到 .env 文件")
+	}
+
+	opts, err := mcp_server.OptionsFromConfig(ctx)
+	if err != nil {
+		log.Fatalf("MCP server init fail: %v", err)
+	}
+	defer opts.DB.Close()
+
+	if err := mcp_server.NewServer(opts).Run(ctx, &mcp.StdioTransport{}); err != nil {
+		log.Fatalf("MCP server exit: %v", err)
+	}
+}
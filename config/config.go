@@ -13,6 +13,7 @@ type Config struct {
 	IntentModelType    string
 	EmbeddingModelType string
 	VectorDBType       string
+	LexicalDBType      string // 混合检索中全文检索一路的后端：es 或 bm25（进程内索引）
 
 	ArkConf      ArkConfig
 	OpenAIConf   OpenAIConfig
@@ -22,11 +23,27 @@ type Config struct {
 
 	MilvusConf MilvusConfig
 	ESConf     ESConfig
+	BM25Conf   BM25Config
+	FusionConf FusionConfig
+	RerankConf RerankConfig
+	CacheConf  CacheConfig
+	SchemaConf SchemaConfig
+
+	SQLKnowledgeConf SQLKnowledgeConfig
 
 	LangSmithConf LangSmithConfig
 
 	MySQLConf MySQLConfig
 	RedisConf RedisConfig
+
+	AnalystConf  AnalystConfig
+	SQLConf      SQLConfig
+	HITLConf     HITLConfig
+	ApprovalConf ApprovalConfig
+	AuditConf    AuditConfig
+	MCPConf      MCPConfig
+	IntentConf   IntentConfig
+	MemoryConf   MemoryConfig
 }
 
 type ArkConfig struct {
@@ -78,6 +95,59 @@ type ESConfig struct {
 	CloudID   string
 	APIKey    string
 	Index     string
+
+	SearchMode string // bm25：全文检索；hybrid：ES 内部 kNN + BM25；dense：仅向量检索
+	Analyzer   string // content 字段的分词器：auto、ik、smartcn 或 standard，只在创建索引时生效
+	KNNBoost   string // hybrid 模式下 kNN 得分的权重，BM25 得分权重为 1
+}
+
+// BM25Config 进程内 BM25 全文索引配置
+type BM25Config struct {
+	IndexPath string // 索引持久化文件，为空时只保存在内存中，重启后需重新入库
+}
+
+// FusionConfig 混合检索多路召回的融合配置
+type FusionConfig struct {
+	Method  string // rrf、minmax、zscore 或 dbsf
+	K       string // RRF 平滑常数
+	Weights string // 各路权重，如 milvus:1,es:0.5，未列出的来源权重为 1
+}
+
+// RerankConfig 融合之后的第二阶段重排配置
+type RerankConfig struct {
+	Type       string // none、api（交叉编码器重排接口）或 llm（聊天模型列表式重排）
+	Candidates string // 交给重排的候选池大小，重排后再截取 TopK
+	APIFormat  string // api 模式的请求格式：dashscope 或 jina
+	APIURL     string // 为空时使用对应格式的官方地址
+	APIKey     string // dashscope 格式为空时使用 QWEN_KEY
+	Model      string
+	Timeout    string
+	ModelType  string // llm 模式使用的聊天模型类型
+}
+
+// CacheConfig 文档检索的召回缓存与查询向量缓存配置
+type CacheConfig struct {
+	Mode              string // none：不缓存；exact：相同查询复用召回结果；semantic：相似查询也复用
+	TTL               string // 召回结果的缓存时间
+	SemanticThreshold string // semantic 模式下查询向量余弦相似度的命中阈值
+	SemanticSize      string // 进程内语义索引保存的查询数
+	EmbeddingCache    string // 检索时缓存查询向量，相同文本不再重复调用嵌入模型
+}
+
+// SchemaConfig 数据库表结构知识库配置
+type SchemaConfig struct {
+	Collection        string // 表结构使用的独立 Milvus 集合
+	TopK              string // SQL 生成时召回的表数量
+	SampleRows        string // 每个低基数字段保留的示例值数量，为 0 时不采样，示例值会发送给嵌入模型并出现在 SQL 提示词中
+	SampleDenyColumns string // 不采样的字段，字段名包含其中的词或 table.column 精确匹配
+	SyncOnStart       string // 启动时是否同步表结构
+}
+
+// SQLKnowledgeConfig 业务术语与 NL2SQL 示例知识库配置
+type SQLKnowledgeConfig struct {
+	Collection  string // 术语与示例使用的独立 Milvus 集合
+	TopK        string // SQL 生成时召回的条目数量
+	AutoPropose string // 审批通过并执行成功的查询是否自动提议为示例
 }
 
 type LangSmithConfig struct {
@@ -99,6 +169,68 @@ type RedisConfig struct {
 	DB       string
 }
 
+type AnalystConfig struct {
+	Enabled   string
+	ModelType string
+}
+
+type SQLConfig struct {
+	MaxRepairAttempts string
+	AllowTables       string // 逗号分隔的表白名单，为空时不限制
+	DenyTables        string // 逗号分隔的表黑名单
+	AllowWrite        string
+	AllowDDL          string
+	ExplainEnabled    string // 审批前是否附带 EXPLAIN 执行计划
+}
+
+type HITLConfig struct {
+	ApprovalTTL string // 待审批 SQL 的过期时间，如 30m
+}
+
+type ApprovalConfig struct {
+	Approvers      string // 审批人及角色，如 alice:dba,bob:analyst，为空时任何已认证的身份都可以审批
+	Tokens         string // 审批令牌及对应身份，如 t0k3n:alice，审批接口通过 Authorization: Bearer 令牌识别审批人
+	IdentityHeader string // 网关注入的已认证身份请求头，如 X-Forwarded-User，配置后优先于令牌
+	ReadRoles      string // 逗号分隔的可审批查询语句的角色，为空时不限制
+	WriteRoles     string
+	DDLRoles       string
+	ReadRequired   string // 查询语句需要的审批人数
+	WriteRequired  string
+	DDLRequired    string
+	WebhookURL     string // 审批事件推送地址，为空时不推送
+}
+
+type AuditConfig struct {
+	Type     string // jsonl、mysql 或 none
+	FilePath string // jsonl 模式的文件路径
+	Table    string // mysql 模式的表名
+	MySQL    MySQLConfig
+}
+
+type MCPConfig struct {
+	Mode          string // inprocess：进程内 Go MCP 服务器；stdio：启动 ServerPath 子进程；npx：mcp-server-mysql
+	ServerPath    string // stdio 模式的服务器可执行文件
+	WhitelistPath string // SQL 白名单文件，为空时只允许查询
+	MaxRows       string // 单次查询返回的最大行数
+	ServersPath   string // 额外 MCP 服务器配置文件，为空时只连接内置数据库服务器
+	AgentPath     string // go-agent 自身 MCP 端点的 HTTP 路径，为空时不开启
+}
+
+// IntentConfig 意图路由配置
+type IntentConfig struct {
+	RoutesPath string // 意图路由配置文件，为空时使用内置的 sql / analysis / rag / chat 路由
+	Threshold  string // 置信度低于该值时向用户追问
+	LogFile    string // 意图日志文件，用于评估误判，为空时不记录
+}
+
+// MemoryConfig 对话会话记忆配置
+type MemoryConfig struct {
+	Type  string // memory、redis 或 mysql
+	TTL   string // 会话无新消息后的过期时间，0 表示不过期
+	Table string // mysql 模式的表名
+	MySQL MySQLConfig
+}
+
 var Cfg *Config
 
 func LoadConfig() (*Config, error) {
@@ -117,6 +249,7 @@ func LoadConfig() (*Config, error) {
 		IntentModelType:    getEnv("INTENT_MODEL_TYPE", "ark"),
 		EmbeddingModelType: getEnv("EMBEDDING_MODEL_TYPE", "ark"),
 		VectorDBType:       getEnv("VECTOR_DB_TYPE", "milvus"),
+		LexicalDBType:      getEnv("LEXICAL_DB_TYPE", "es"),
 
 		ArkConf: ArkConfig{
 			ArkKey:            getEnv("ARK_KEY", ""),
@@ -154,11 +287,52 @@ func LoadConfig() (*Config, error) {
 			CollectionName:      getEnv("MILVUS_COLLECTION_NAME", "GoAgent"),
 			TopK:                getEnv("TOPK", "10"),
 		},
+		SchemaConf: SchemaConfig{
+			Collection:        getEnv("SCHEMA_COLLECTION_NAME", "GoAgentSchema"),
+			TopK:              getEnv("SCHEMA_TOPK", "5"),
+			SampleRows:        getEnv("SCHEMA_SAMPLE_ROWS", "0"),
+			SampleDenyColumns: getEnv("SCHEMA_SAMPLE_DENY_COLUMNS", "password,passwd,secret,token,api_key,email,phone,mobile,address,id_card,idcard,ssn"),
+			SyncOnStart:       getEnv("SCHEMA_SYNC_ON_START", "true"),
+		},
+		SQLKnowledgeConf: SQLKnowledgeConfig{
+			Collection:  getEnv("SQL_KNOWLEDGE_COLLECTION_NAME", "GoAgentSQLKnowledge"),
+			TopK:        getEnv("SQL_KNOWLEDGE_TOPK", "5"),
+			AutoPropose: getEnv("SQL_EXAMPLE_AUTO_PROPOSE", "true"),
+		},
 		ESConf: ESConfig{
 			Addresses: esAddresses,
 			Username:  getEnv("ES_USERNAME", ""),
 			Password:  getEnv("ES_PASSWORD", ""),
 			Index:     getEnv("ES_INDEX", "go_agent_docs"),
+
+			SearchMode: getEnv("ES_SEARCH_MODE", "bm25"),
+			Analyzer:   getEnv("ES_ANALYZER", "auto"),
+			KNNBoost:   getEnv("ES_KNN_BOOST", "1"),
+		},
+		BM25Conf: BM25Config{
+			IndexPath: getEnv("BM25_INDEX_PATH", "data/bm25_index.json"),
+		},
+		FusionConf: FusionConfig{
+			Method:  getEnv("RETRIEVAL_FUSION", "rrf"),
+			K:       getEnv("RETRIEVAL_FUSION_K", "60"),
+			Weights: getEnv("RETRIEVAL_FUSION_WEIGHTS", ""),
+		},
+		RerankConf: RerankConfig{
+			Type:       getEnv("RERANK_TYPE", "none"),
+			Candidates: getEnv("RERANK_CANDIDATES", "30"),
+			APIFormat:  getEnv("RERANK_API_FORMAT", "dashscope"),
+			APIURL:     getEnv("RERANK_API_URL", ""),
+			APIKey:     getEnv("RERANK_API_KEY", ""),
+			Model:      getEnv("RERANK_MODEL", "gte-rerank-v2"),
+			Timeout:    getEnv("RERANK_TIMEOUT", "10s"),
+			ModelType:  getEnv("RERANK_MODEL_TYPE", getEnv("CHAT_MODEL_TYPE", "ark")),
+		},
+		CacheConf: CacheConfig{
+			Mode:              getEnv("RETRIEVAL_CACHE", "exact"),
+			TTL:               getEnv("RETRIEVAL_CACHE_TTL", "1h"),
+			SemanticThreshold: getEnv("SEMANTIC_CACHE_THRESHOLD", "0.95"),
+			SemanticSize:      getEnv("SEMANTIC_CACHE_SIZE", "1000"),
+			EmbeddingCache:    getEnv("EMBEDDING_CACHE_ENABLED", "true"),
 		},
 		LangSmithConf: LangSmithConfig{
 			APIKey: getEnv("LANG_SMITH_KEY", ""),
@@ -176,6 +350,72 @@ func LoadConfig() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnv("REDIS_DB", "0"),
 		},
+		AnalystConf: AnalystConfig{
+			Enabled:   getEnv("ANALYST_ENABLED", "true"),
+			ModelType: getEnv("ANALYST_MODEL_TYPE", getEnv("CHAT_MODEL_TYPE", "ark")),
+		},
+		SQLConf: SQLConfig{
+			MaxRepairAttempts: getEnv("SQL_MAX_REPAIR_ATTEMPTS", "3"),
+			AllowTables:       getEnv("SQL_ALLOW_TABLES", ""),
+			DenyTables:        getEnv("SQL_DENY_TABLES", ""),
+			AllowWrite:        getEnv("SQL_ALLOW_WRITE", "true"),
+			AllowDDL:          getEnv("SQL_ALLOW_DDL", "false"),
+			ExplainEnabled:    getEnv("SQL_EXPLAIN_ENABLED", "true"),
+		},
+		HITLConf: HITLConfig{
+			ApprovalTTL: getEnv("HITL_APPROVAL_TTL", "30m"),
+		},
+		ApprovalConf: ApprovalConfig{
+			Approvers:      getEnv("APPROVAL_APPROVERS", ""),
+			Tokens:         getEnv("APPROVAL_TOKENS", ""),
+			IdentityHeader: getEnv("APPROVAL_IDENTITY_HEADER", ""),
+			ReadRoles:      getEnv("APPROVAL_READ_ROLES", ""),
+			WriteRoles:     getEnv("APPROVAL_WRITE_ROLES", ""),
+			DDLRoles:       getEnv("APPROVAL_DDL_ROLES", ""),
+			ReadRequired:   getEnv("APPROVAL_READ_REQUIRED", "1"),
+			WriteRequired:  getEnv("APPROVAL_WRITE_REQUIRED", "1"),
+			DDLRequired:    getEnv("APPROVAL_DDL_REQUIRED", "1"),
+			WebhookURL:     getEnv("APPROVAL_WEBHOOK_URL", ""),
+		},
+		AuditConf: AuditConfig{
+			Type:     getEnv("AUDIT_TYPE", "jsonl"),
+			FilePath: getEnv("AUDIT_FILE", "logs/audit.jsonl"),
+			Table:    getEnv("AUDIT_TABLE", "sql_audit_log"),
+			// 审计库未单独配置时与业务库相同
+			MySQL: MySQLConfig{
+				Host:     getEnv("AUDIT_MYSQL_HOST", getEnv("MYSQL_HOST", "localhost")),
+				Port:     getEnv("AUDIT_MYSQL_PORT", getEnv("MYSQL_PORT", "3306")),
+				Username: getEnv("AUDIT_MYSQL_USER", getEnv("MYSQL_USERNAME", "")),
+				Password: getEnv("AUDIT_MYSQL_PASSWORD", getEnv("MYSQL_PASSWORD", "")),
+				Database: getEnv("AUDIT_MYSQL_DATABASE", getEnv("MYSQL_DATABASE", "")),
+			},
+		},
+		MCPConf: MCPConfig{
+			Mode:          getEnv("MCP_SERVER_MODE", "inprocess"),
+			ServerPath:    getEnv("MCP_SERVER_PATH", "./bin/go-agent-mcp-server"),
+			WhitelistPath: getEnv("MCP_WHITELIST_PATH", ""),
+			MaxRows:       getEnv("MCP_MAX_ROWS", "1000"),
+			ServersPath:   getEnv("MCP_SERVERS_PATH", ""),
+			AgentPath:     getEnv("AGENT_MCP_PATH", "/mcp"),
+		},
+		IntentConf: IntentConfig{
+			RoutesPath: getEnv("INTENT_ROUTES_PATH", ""),
+			Threshold:  getEnv("INTENT_CONFIDENCE_THRESHOLD", "0.6"),
+			LogFile:    getEnv("INTENT_LOG_FILE", "logs/intent.jsonl"),
+		},
+		MemoryConf: MemoryConfig{
+			Type:  getEnv("MEMORY_STORE_TYPE", "redis"),
+			TTL:   getEnv("MEMORY_TTL", "168h"),
+			Table: getEnv("MEMORY_TABLE", "chat_memory"),
+			// 会话记忆库未单独配置时与业务库相同
+			MySQL: MySQLConfig{
+				Host:     getEnv("MEMORY_MYSQL_HOST", getEnv("MYSQL_HOST", "localhost")),
+				Port:     getEnv("MEMORY_MYSQL_PORT", getEnv("MYSQL_PORT", "3306")),
+				Username: getEnv("MEMORY_MYSQL_USER", getEnv("MYSQL_USERNAME", "")),
+				Password: getEnv("MEMORY_MYSQL_PASSWORD", getEnv("MYSQL_PASSWORD", "")),
+				Database: getEnv("MEMORY_MYSQL_DATABASE", getEnv("MYSQL_DATABASE", "")),
+			},
+		},
 	}
 
 	return config, nil
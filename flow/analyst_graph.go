@@ -3,6 +3,7 @@ package flow
 import (
 	"context"
 	"fmt"
+	"go-agent/config"
 	"go-agent/model/chat_model"
 	"go-agent/tool/analyst_tools"
 
@@ -20,11 +21,13 @@ const (
 
 // AnalystState Graph状态
 type AnalystState struct {
-	SQLResult    string                    `json:"sql_result"`
-	ParsedData   *analyst_tools.ParsedData `json:"parsed_data"`
-	Statistics   *analyst_tools.Statistics `json:"statistics"`
-	TextAnalysis string                    `json:"text_analysis"`
-	ChartConfig  interface{}               `json:"chart_config"`
+	SQLResult    string                             `json:"sql_result"`
+	ParsedData   *analyst_tools.ParsedData          `json:"parsed_data"`
+	Statistics   *analyst_tools.Statistics          `json:"statistics"`
+	Findings     *analyst_tools.Findings            `json:"findings"`
+	TextAnalysis string                             `json:"text_analysis"`
+	ChartConfig  interface{}                        `json:"chart_config"`
+	Chart        *analyst_tools.ChartRecommendation `json:"chart_recommendation"`
 }
 
 func init() {
@@ -39,7 +42,7 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 		}),
 	)
 
-	cm, err := chat_model.GetChatModel(ctx, "analyst")
+	cm, err := chat_model.GetChatModel(ctx, config.Cfg.AnalystConf.ModelType)
 	if err != nil {
 		return nil, err
 	}
@@ -82,16 +85,18 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 		return data, nil
 	}))
 
-	// 分析数据节点（计算统计）
+	// 分析数据节点（计算统计、检测趋势与异常）
 	_ = g.AddLambdaNode(AnalyzeDataNode, compose.InvokableLambda(func(ctx context.Context, input *analyst_tools.ParsedData) (*analyst_tools.Statistics, error) {
 		stats, err := analyst_tools.ComputeStatistics(input)
 		if err != nil {
 			return nil, err
 		}
+		findings := analyst_tools.DetectFindings(input)
 
 		// 保存到状态
 		_ = compose.ProcessState[*AnalystState](ctx, func(ctx context.Context, state *AnalystState) error {
 			state.Statistics = stats
+			state.Findings = findings
 			return nil
 		})
 
@@ -101,8 +106,10 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 	// 生成文字报告节点
 	_ = g.AddLambdaNode(GenerateReportNode, compose.InvokableLambda(func(ctx context.Context, input *analyst_tools.Statistics) (string, error) {
 		var data *analyst_tools.ParsedData
+		var findings *analyst_tools.Findings
 		_ = compose.ProcessState[*AnalystState](ctx, func(ctx context.Context, state *AnalystState) error {
 			data = state.ParsedData
+			findings = state.Findings
 			return nil
 		})
 
@@ -110,7 +117,7 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 			return "", fmt.Errorf("未找到解析数据")
 		}
 
-		report, err := analyst_tools.GenerateTextAnalysis(ctx, data, input, cm)
+		report, err := analyst_tools.GenerateTextAnalysis(ctx, data, input, findings, cm)
 		if err != nil {
 			return "", err
 		}
@@ -122,7 +129,7 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 		})
 
 		return report, nil
-	}))
+	}), compose.WithOutputKey("text_analysis"))
 
 	// 生成图表配置节点
 	_ = g.AddLambdaNode(GenerateChartNode, compose.InvokableLambda(func(ctx context.Context, input *analyst_tools.Statistics) (interface{}, error) {
@@ -136,7 +143,14 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 			return nil, fmt.Errorf("未找到解析数据")
 		}
 
-		chart, err := analyst_tools.GenerateChartConfig(data)
+		// 作为 FinalGraph 子图运行时，读取调用方的图表偏好
+		var hint *analyst_tools.ChartHint
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			hint = state.ChartHint
+			return nil
+		})
+
+		chart, rec, err := analyst_tools.GenerateChartConfig(data, hint)
 		if err != nil {
 			return nil, err
 		}
@@ -144,14 +158,15 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 		// 保存到状态
 		_ = compose.ProcessState[*AnalystState](ctx, func(ctx context.Context, state *AnalystState) error {
 			state.ChartConfig = chart
+			state.Chart = rec
 			return nil
 		})
 
 		return chart, nil
-	}))
+	}), compose.WithOutputKey("chart_config"))
 
-	// 合并结果节点
-	_ = g.AddLambdaNode(MergeResultNode, compose.InvokableLambda(func(ctx context.Context, input interface{}) (*analyst_tools.AnalysisResult, error) {
+	// 合并结果节点：报告与图表两路输出按 OutputKey 汇聚为 map
+	_ = g.AddLambdaNode(MergeResultNode, compose.InvokableLambda(func(ctx context.Context, input map[string]any) (*analyst_tools.AnalysisResult, error) {
 		var state *AnalystState
 		_ = compose.ProcessState[*AnalystState](ctx, func(ctx context.Context, s *AnalystState) error {
 			state = s
@@ -161,7 +176,9 @@ func BuildAnalystGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, *
 		return &analyst_tools.AnalysisResult{
 			TextAnalysis: state.TextAnalysis,
 			ChartConfig:  state.ChartConfig,
+			Chart:        state.Chart,
 			Statistics:   state.Statistics,
+			Findings:     state.Findings,
 		}, nil
 	}))
 
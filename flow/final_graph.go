@@ -2,24 +2,56 @@ package flow
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"go-agent/config"
 	"go-agent/model/chat_model"
+	"go-agent/rag/rag_flow"
 	"go-agent/tool"
+	"go-agent/tool/analyst_tools"
+	"go-agent/tool/audit"
+	"go-agent/tool/intent"
+	"go-agent/tool/mcp_client"
+	"go-agent/tool/memory"
+	"go-agent/tool/sql_guard"
 	"go-agent/tool/sql_tools"
+	"log"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
+// FinalGraphRequest 总控图的输入，同时作为图的本地状态。
+// 只有 Query、SessionID、UserID、ChartHint、Route 来自调用方，其余字段由图在执行中写入，不从请求体绑定
 type FinalGraphRequest struct {
-	Query     string `json:"query" binding:"required"`
-	SessionID string `json:"session_id,omitempty"`
-	SQL       string `json:"sql,omitempty"`  // 用于存储生成的 SQL
-	Docs      string `json:"docs,omitempty"` // 用于存储检索到的表结构
+	Query     string                   `json:"query" binding:"required"`
+	SessionID string                   `json:"session_id,omitempty"`
+	UserID    string                   `json:"user_id,omitempty"`    // 提问人，作为审批申请人
+	ChartHint *analyst_tools.ChartHint `json:"chart_hint,omitempty"` // 可选的图表偏好
+	Route     string                   `json:"route,omitempty"`      // 指定意图名或路由目标（sql/analysis/rag/chat）时跳过意图识别
+
+	SQL      string            `json:"-"` // 用于存储生成的 SQL
+	Docs     string            `json:"-"` // 用于存储检索到的表结构
+	Result   string            `json:"-"` // 用于存储 MCP 执行结果
+	Attempts []SQLAttempt      `json:"-"` // SQL 执行尝试记录，用于展示修正历史
+	Guard    *sql_guard.Report `json:"-"` // 最近一次 SQL 的安全检查结果
+	Approval *ApprovalDecision `json:"-"` // 最近一次审批决定
+	Intent   *intent.Decision  `json:"-"` // 意图识别结果
+
+	Standalone   string `json:"-"` // 结合会话历史改写后的独立查询需求
+	Conversation string `json:"-"` // SQL 提示词中附带的对话上下文
+}
+
+// SQLAttempt 一次 SQL 执行尝试
+type SQLAttempt struct {
+	Attempt int    `json:"attempt"`         // 第几次尝试，从 1 开始
+	SQL     string `json:"sql"`             // 执行的 SQL
+	Error   string `json:"error,omitempty"` // 执行错误，成功时为空
 }
 
 const (
@@ -30,6 +62,27 @@ const (
 	ChatToEnd    = "ChatToEnd"
 	ToToolCall   = "ToToolCall"
 	MCP          = "MCP"
+	Analyst      = "Analyst"
+	AnalystToEnd = "AnalystToEnd"
+	ResultToEnd  = "ResultToEnd"
+	SQLFailed    = "SQLFailed"
+	SQLBlocked   = "SQLBlocked"
+
+	ToRAGInput   = "ToRAGInput"
+	RAGChat      = "RAGChat"
+	RAGChatToEnd = "RAGChatToEnd"
+	Clarify      = "Clarify"
+)
+
+// SSE 事件类型，通过 Message.Extra[EventKey] 透传给接入层
+const (
+	EventKey        = "event"
+	EventMessage    = "message"
+	EventAnalysis   = "analysis"
+	EventChart      = "chart"
+	EventStatistics = "statistics"
+	EventFindings   = "findings"
+	EventAttempts   = "attempts"
 )
 
 func init() {
@@ -42,10 +95,10 @@ var (
 	finalGraphInitErr error
 )
 
-// InitFinalGraph 在应用启动时编译并缓存全局图
-func InitFinalGraph(ctx context.Context, store compose.CheckPointStore) error {
+// InitFinalGraph 在应用启动时编译并缓存全局图，memStore 为与 RAG 对话图共用的会话记忆
+func InitFinalGraph(ctx context.Context, store compose.CheckPointStore, memStore memory.Store, taskModel model.BaseChatModel) error {
 	finalGraphOnce.Do(func() {
-		cachedFinalGraph, finalGraphInitErr = buildFinalGraph(ctx, store)
+		cachedFinalGraph, finalGraphInitErr = buildFinalGraph(ctx, store, memStore, taskModel)
 	})
 	return finalGraphInitErr
 }
@@ -58,35 +111,54 @@ func GetFinalGraph() (compose.Runnable[FinalGraphRequest, []*schema.Message], er
 	return cachedFinalGraph, nil
 }
 
-func buildFinalGraph(ctx context.Context, store compose.CheckPointStore) (compose.Runnable[FinalGraphRequest, []*schema.Message], error) {
+func buildFinalGraph(ctx context.Context, store compose.CheckPointStore, memStore memory.Store, taskModel model.BaseChatModel) (compose.Runnable[FinalGraphRequest, []*schema.Message], error) {
 	g := compose.NewGraph[FinalGraphRequest, []*schema.Message](
 		compose.WithGenLocalState(func(ctx context.Context) *FinalGraphRequest {
 			return &FinalGraphRequest{}
 		}),
 	)
 
-	// 意图识别模型
-	_ = g.AddLambdaNode(Intent_Model, compose.InvokableLambda(func(ctx context.Context, input FinalGraphRequest) (*schema.Message, error) {
-		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
-			*state = input
-			return nil
-		})
+	sm := &memory.Summarizer{Model: taskModel, MaxHistoryLen: 3}
 
-		intentTemp := prompt.FromMessages(schema.FString,
-			schema.SystemMessage("你是一个意图识别专家。请分析用户输入，如果是关于数据库查询、数据统计、报表需求，回答 'SQL'；否则回答 'Chat'。"),
-			schema.UserMessage("{query}"),
-		)
-		cm, _ := chat_model.GetChatModel(context.Background(), config.Cfg.IntentModelType)
-		output, err := intentTemp.Format(ctx, map[string]any{
-			"query": input.Query,
-		})
+	// 意图识别：结构化输出意图、置信度与槽位，置信度不足时追问
+	router, err := newIntentRouter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = g.AddLambdaNode(Intent_Model, compose.InvokableLambda(func(ctx context.Context, input FinalGraphRequest) ([]*schema.Message, error) {
+		var decision *intent.Decision
+		var err error
+		if input.Route != "" {
+			decision, err = router.Force(input.Route)
+		} else {
+			// 追问往往省略了主语，带上最近的对话一起识别
+			var history []*schema.Message
+			if sess, err := memStore.Get(ctx, input.SessionID); err == nil {
+				history = sess.History
+			}
+			decision, err = router.Classify(ctx, input.Query, history)
+		}
 		if err != nil {
 			return nil, err
 		}
-		return cm.Generate(ctx, output)
+		intent.Record(intent.DecisionRecord(input.SessionID, input.Query, decision))
+
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			// 只取调用方可以指定的字段，执行中产生的字段从空状态开始
+			*state = FinalGraphRequest{
+				Query:     input.Query,
+				SessionID: input.SessionID,
+				UserID:    input.UserID,
+				ChartHint: input.ChartHint,
+				Route:     input.Route,
+				Intent:    decision,
+			}
+			return nil
+		})
+		return []*schema.Message{schema.UserMessage(input.Query)}, nil
 	}))
 	//  React 子图
-	react, err := BuildReactGraph(ctx)
+	react, err := BuildReactGraph(ctx, memStore, taskModel)
 	if err != nil {
 		return nil, fmt.Errorf("构建 React 子图失败: %w", err)
 	}
@@ -94,30 +166,77 @@ func buildFinalGraph(ctx context.Context, store compose.CheckPointStore) (compos
 		return []*schema.Message{schema.UserMessage(state.Query)}, nil
 	}))
 
-	// 聊天路径
+	// 聊天路径：结合会话摘要与历史回答，与知识库问答、SQL 共用同一个会话记忆
 	chat, err := chat_model.GetChatModel(ctx, config.Cfg.ChatModelType)
 	if err != nil {
 		return nil, err
 	}
-	_ = g.AddChatModelNode(Chat, chat, compose.WithStatePreHandler(func(ctx context.Context, in []*schema.Message, state *FinalGraphRequest) ([]*schema.Message, error) {
-		return []*schema.Message{schema.UserMessage(state.Query)}, nil
-	}))
+	_ = g.AddChatModelNode(Chat, chat,
+		compose.WithStatePreHandler(func(ctx context.Context, in []*schema.Message, state *FinalGraphRequest) ([]*schema.Message, error) {
+			sess, err := memStore.Get(ctx, state.SessionID)
+			if err != nil {
+				log.Printf("读取会话记忆失败: sessionID=%s, err=%v", state.SessionID, err)
+				return []*schema.Message{schema.UserMessage(state.Query)}, nil
+			}
+			return withHistory(sess, schema.UserMessage(state.Query)), nil
+		}),
+		compose.WithStatePostHandler(func(ctx context.Context, out *schema.Message, state *FinalGraphRequest) (*schema.Message, error) {
+			rememberTurn(ctx, memStore, sm, state.SessionID, schema.UserMessage(state.Query), out)
+			return out, nil
+		}),
+	)
 
 	_ = g.AddLambdaNode(ChatToEnd, compose.InvokableLambda(tool.MsgToMsgs))
 
-	// 转换节点
-	_ = g.AddLambdaNode(Trans_List, compose.InvokableLambda(tool.MsgToMsgs))
+	// 知识库问答路径：嵌入 RAG 对话子图，改写问题、检索并结合历史回答
+	ragChat, err := BuildRAGChatGraph(ctx, memStore, taskModel)
+	if err != nil {
+		return nil, fmt.Errorf("构建 RAG 对话子图失败: %w", err)
+	}
+	_ = g.AddLambdaNode(ToRAGInput, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) (RAGChatInput, error) {
+		var in RAGChatInput
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			in = RAGChatInput{SessionID: state.SessionID, Query: state.Query}
+			return nil
+		})
+		return in, nil
+	}))
+	_ = g.AddGraphNode(RAGChat, ragChat)
+	_ = g.AddLambdaNode(RAGChatToEnd, compose.InvokableLambda(tool.MsgToMsgs))
+
+	// 意图不明确时返回追问
+	_ = g.AddLambdaNode(Clarify, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		var clarification string
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			clarification = state.Intent.Clarification
+			return nil
+		})
+		return []*schema.Message{newEventMsg(EventMessage, clarification)}, nil
+	}))
 
 	// 意图分支
-	_ = g.AddBranch(Trans_List, compose.NewGraphBranch(func(ctx context.Context, input []*schema.Message) (endNode string, err error) {
-		content := strings.ToUpper(input[len(input)-1].Content)
-		if strings.Contains(content, "SQL") {
+	_ = g.AddBranch(Intent_Model, compose.NewGraphBranch(func(ctx context.Context, input []*schema.Message) (endNode string, err error) {
+		var decision *intent.Decision
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			decision = state.Intent
+			return nil
+		})
+		if decision.NeedsClarification() {
+			return Clarify, nil
+		}
+		switch decision.Target {
+		case intent.TargetSQL, intent.TargetAnalysis:
 			return React, nil
+		case intent.TargetRAG:
+			return ToRAGInput, nil
+		default:
+			return Chat, nil
 		}
-		return Chat, nil
 	}, map[string]bool{
-		React: true,
-		Chat:  true,
+		React:      true,
+		Chat:       true,
+		ToRAGInput: true,
+		Clarify:    true,
 	}))
 
 	// 类型转换：[]*Message -> *Message
@@ -126,33 +245,370 @@ func buildFinalGraph(ctx context.Context, store compose.CheckPointStore) (compos
 		if err != nil {
 			return nil, err
 		}
-		msg.Content = msg.Content[5:]
 		return tool.MsgToSQLToolCall(ctx, msg)
 	}))
 
 	// MCP 执行节点
-	tools, err := sql_tools.GetMCPTool(ctx)
+	tools, err := mcp_client.GetTools(ctx, mcp_client.SQLServer)
 	if err != nil {
 		return nil, fmt.Errorf("获取 MCP 工具失败: %w", err)
 	}
 	mcpTool, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{
 		Tools: tools,
+		// 先记录审计日志，执行错误不中断图，交给修正循环处理
+		ToolCallMiddlewares: []compose.ToolMiddleware{audit.Middleware(auditRecord), sql_tools.ErrorAsResultMiddleware()},
 	})
 	if err != nil {
 		return nil, err
 	}
-	_ = g.AddToolsNode(MCP, mcpTool)
+	_ = g.AddToolsNode(MCP, mcpTool, compose.WithStatePostHandler(func(ctx context.Context, out []*schema.Message, state *FinalGraphRequest) ([]*schema.Message, error) {
+		attempt := SQLAttempt{Attempt: len(state.Attempts) + 1, SQL: state.SQL}
+		if len(out) > 0 {
+			if execErr, failed := sql_tools.ParseExecError(out[0].Content); failed {
+				attempt.Error = execErr
+				state.Result = ""
+			} else {
+				out[0].Content = sql_tools.ExtractToolText(out[0].Content)
+				state.Result = out[0].Content
+				proposeExample(state)
+			}
+		}
+		state.Attempts = append(state.Attempts, attempt)
+		return out, nil
+	}))
+
+	// SQL 路径的每一轮（含执行失败与被拦截）写入会话记忆，后续追问、知识库问答与闲聊都能看到
+	rememberSQL := compose.WithStatePostHandler(func(ctx context.Context, out []*schema.Message, state *FinalGraphRequest) ([]*schema.Message, error) {
+		rememberTurn(ctx, memStore, sm, state.SessionID, schema.UserMessage(state.Query), sqlTurnMessage(state))
+		return out, nil
+	})
+
+	// 执行结果直接返回
+	_ = g.AddLambdaNode(ResultToEnd, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		return resultToMsgs(ctx, nil)
+	}), rememberSQL)
+
+	// 修正次数用尽后返回最后一次错误
+	_ = g.AddLambdaNode(SQLFailed, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		var attempts []SQLAttempt
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			attempts = state.Attempts
+			return nil
+		})
+		last := attempts[len(attempts)-1]
+		msgs := []*schema.Message{newEventMsg(EventMessage, fmt.Sprintf("SQL 经过 %d 次尝试仍执行失败：%s", len(attempts), last.Error))}
+		return appendAttemptsMsg(msgs, attempts)
+	}), rememberSQL)
+
+	// SQL 被安全策略拦截时返回拦截原因
+	_ = g.AddLambdaNode(SQLBlocked, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		var report *sql_guard.Report
+		var attempts []SQLAttempt
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			report = state.Guard
+			attempts = state.Attempts
+			return nil
+		})
+		reasons := strings.Join(report.Reasons, "；")
+		rec := auditRecord(ctx)
+		rec.SQL, rec.Approver, rec.Error = report.SQL, "", "安全策略拦截："+reasons
+		audit.WriteDecision(ctx, "sql_guard", audit.DecisionBlocked, rec)
+
+		content := fmt.Sprintf("该操作已被安全策略拦截：%s\n\n%s", reasons, report.SQL)
+		return appendAttemptsMsg([]*schema.Message{newEventMsg(EventMessage, content)}, attempts)
+	}), rememberSQL)
+
+	// Analyst 子图：对 SQL 结果进行统计分析并生成图表
+	analystEnabled := config.Cfg.AnalystConf.Enabled == "true"
+	if analystEnabled {
+		analystGraph, err := BuildAnalystGraph(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("构建 Analyst 子图失败: %w", err)
+		}
+		analyst, err := analystGraph.Compile(ctx, compose.WithGraphName("Analyst"))
+		if err != nil {
+			return nil, fmt.Errorf("编译 Analyst 子图失败: %w", err)
+		}
+		// SQL 已经执行，分析失败时只返回查询结果，不让整个请求失败，本轮对话照常写入会话记忆
+		_ = g.AddLambdaNode(Analyst, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) (*analyst_tools.AnalysisResult, error) {
+			result, err := analyst.Invoke(ctx, input)
+			if err != nil {
+				log.Printf("数据分析失败，仅返回查询结果: %v", err)
+				return nil, nil
+			}
+			return result, nil
+		}))
+		_ = g.AddLambdaNode(AnalystToEnd, compose.InvokableLambda(func(ctx context.Context, input *analyst_tools.AnalysisResult) ([]*schema.Message, error) {
+			msgs, err := resultToMsgs(ctx, input)
+			if err != nil && input != nil {
+				log.Printf("生成分析结果失败，仅返回查询结果: %v", err)
+				return resultToMsgs(ctx, nil)
+			}
+			return msgs, err
+		}), rememberSQL)
+	}
 
 	// 连线
 	_ = g.AddEdge(compose.START, Intent_Model)
-	_ = g.AddEdge(Intent_Model, Trans_List)
 
-	_ = g.AddEdge(React, ToToolCall)
+	_ = g.AddBranch(React, compose.NewGraphBranch(func(ctx context.Context, input []*schema.Message) (endNode string, err error) {
+		blocked := false
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			blocked = state.Guard != nil && state.Guard.Blocked()
+			return nil
+		})
+		if blocked {
+			return SQLBlocked, nil
+		}
+		return ToToolCall, nil
+	}, map[string]bool{
+		ToToolCall: true,
+		SQLBlocked: true,
+	}))
+	_ = g.AddEdge(SQLBlocked, compose.END)
 	_ = g.AddEdge(ToToolCall, MCP)
-	_ = g.AddEdge(MCP, compose.END)
+
+	// 执行失败且未超过修正次数时回到 React 重新生成 SQL，重新生成的 SQL 仍需经过审批
+	maxRepair, err := strconv.Atoi(config.Cfg.SQLConf.MaxRepairAttempts)
+	if err != nil || maxRepair < 0 {
+		maxRepair = 0
+	}
+	mcpNext := map[string]bool{
+		React:       true,
+		SQLFailed:   true,
+		ResultToEnd: true,
+	}
+	if analystEnabled {
+		mcpNext[Analyst] = true
+	}
+	_ = g.AddBranch(MCP, compose.NewGraphBranch(func(ctx context.Context, input []*schema.Message) (endNode string, err error) {
+		var attempts []SQLAttempt
+		wantsAnalysis := true
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			attempts = state.Attempts
+			// 只查询数据的意图不做分析，除非调用方指定了图表偏好
+			if state.Intent != nil && state.Intent.Target == intent.TargetSQL && state.ChartHint == nil {
+				wantsAnalysis = false
+			}
+			return nil
+		})
+		if n := len(attempts); n > 0 && attempts[n-1].Error != "" {
+			if n-1 < maxRepair {
+				return React, nil
+			}
+			return SQLFailed, nil
+		}
+		// 执行结果为空或不需要分析时直接返回
+		if !analystEnabled || !wantsAnalysis || len(input) == 0 || strings.TrimSpace(input[0].Content) == "" {
+			return ResultToEnd, nil
+		}
+		return Analyst, nil
+	}, mcpNext))
+	if analystEnabled {
+		_ = g.AddEdge(Analyst, AnalystToEnd)
+		_ = g.AddEdge(AnalystToEnd, compose.END)
+	}
+	_ = g.AddEdge(ResultToEnd, compose.END)
+	_ = g.AddEdge(SQLFailed, compose.END)
 
 	_ = g.AddEdge(Chat, ChatToEnd)
 	_ = g.AddEdge(ChatToEnd, compose.END)
 
+	_ = g.AddEdge(ToRAGInput, RAGChat)
+	_ = g.AddEdge(RAGChat, RAGChatToEnd)
+	_ = g.AddEdge(RAGChatToEnd, compose.END)
+	_ = g.AddEdge(Clarify, compose.END)
+
 	return g.Compile(ctx, compose.WithCheckPointStore(store))
 }
+
+// withHistory 在消息前拼接会话摘要与历史
+func withHistory(sess *memory.Session, msg *schema.Message) []*schema.Message {
+	var msgs []*schema.Message
+	if sess.Summary != "" {
+		msgs = append(msgs, schema.SystemMessage("背景摘要: "+sess.Summary))
+	}
+	msgs = append(msgs, sess.History...)
+	return append(msgs, msg)
+}
+
+// rememberTurn 把一轮对话原子地追加到会话记忆，历史过长时在后台压缩为摘要。
+// 追加同步完成，保证紧随其后的追问能读到本轮；压缩以乐观锁写回，不会覆盖并发写入的新消息
+func rememberTurn(ctx context.Context, store memory.Store, sm *memory.Summarizer, sessionID string, turn ...*schema.Message) {
+	ctx = context.WithoutCancel(ctx)
+	if _, err := store.Append(ctx, sessionID, turn...); err != nil {
+		log.Printf("保存会话记忆失败: sessionID=%s, err=%v", sessionID, err)
+		return
+	}
+	go func() {
+		if err := sm.Compact(ctx, store, sessionID); err != nil {
+			log.Printf("压缩会话记忆失败: sessionID=%s, err=%v", sessionID, err)
+		}
+	}()
+}
+
+// sqlTurnMessage 将本轮生成的 SQL 与执行结果整理为写入会话记忆的助手消息
+func sqlTurnMessage(state *FinalGraphRequest) *schema.Message {
+	var sb strings.Builder
+	if state.SQL != "" {
+		sb.WriteString("执行的SQL：" + state.SQL + "\n")
+	}
+	n := len(state.Attempts)
+	switch {
+	case state.Guard != nil && state.Guard.Blocked():
+		sb.WriteString("结果：被安全策略拦截，" + strings.Join(state.Guard.Reasons, "；"))
+	case n > 0 && state.Attempts[n-1].Error != "":
+		sb.WriteString("结果：执行失败，" + truncateRunes(state.Attempts[n-1].Error, 200))
+	default:
+		sb.WriteString("结果摘要：" + summarizeResult(state.Result))
+	}
+	return schema.AssistantMessage(sb.String(), nil)
+}
+
+// summarizeResult 不调用模型，用行数、列名与前几行概括执行结果
+func summarizeResult(result string) string {
+	if strings.TrimSpace(result) == "" {
+		return "无返回数据"
+	}
+	data, err := analyst_tools.ParseSQLResult(result)
+	// 写操作等非表格结果原样截断
+	if err != nil || data.RowCount == 0 {
+		return truncateRunes(result, 300)
+	}
+	return fmt.Sprintf("共 %d 行，列：%s\n%s", data.RowCount, strings.Join(data.Columns, ", "), truncateRunes(strings.TrimSpace(data.SampleRows), 300))
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
+
+// newIntentRouter 读取意图路由配置并创建意图识别路由
+func newIntentRouter(ctx context.Context) (*intent.Router, error) {
+	routes, err := intent.LoadRoutes(config.Cfg.IntentConf.RoutesPath)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := strconv.ParseFloat(config.Cfg.IntentConf.Threshold, 64)
+	if err != nil {
+		threshold = 0.6
+	}
+	cm, err := chat_model.GetChatModel(ctx, config.Cfg.IntentModelType)
+	if err != nil {
+		return nil, fmt.Errorf("获取意图识别模型失败: %w", err)
+	}
+	return intent.NewRouter(cm, routes, threshold), nil
+}
+
+// AnalysisToMsgs 将 SQL 执行结果与分析结果转换为带事件类型的消息列表
+func AnalysisToMsgs(result string, analysis *analyst_tools.AnalysisResult) ([]*schema.Message, error) {
+	msgs := []*schema.Message{newEventMsg(EventMessage, result)}
+	if analysis == nil {
+		return msgs, nil
+	}
+
+	if analysis.TextAnalysis != "" {
+		msgs = append(msgs, newEventMsg(EventAnalysis, analysis.TextAnalysis))
+	}
+	if analysis.ChartConfig != nil {
+		chart, err := json.Marshal(analysis.ChartConfig)
+		if err != nil {
+			return nil, fmt.Errorf("序列化图表配置失败: %w", err)
+		}
+		msgs = append(msgs, newEventMsg(EventChart, string(chart)))
+	}
+	if analysis.Statistics != nil {
+		stats, err := json.Marshal(analysis.Statistics)
+		if err != nil {
+			return nil, fmt.Errorf("序列化统计数据失败: %w", err)
+		}
+		msgs = append(msgs, newEventMsg(EventStatistics, string(stats)))
+	}
+	if analysis.Findings != nil {
+		findings, err := json.Marshal(analysis.Findings)
+		if err != nil {
+			return nil, fmt.Errorf("序列化检测结果失败: %w", err)
+		}
+		msgs = append(msgs, newEventMsg(EventFindings, string(findings)))
+	}
+
+	return msgs, nil
+}
+
+// auditRecord 从图状态中取出审计所需的会话、提问与审批信息
+func auditRecord(ctx context.Context) audit.Record {
+	var rec audit.Record
+	_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+		rec.SessionID = state.SessionID
+		rec.UserID = state.UserID
+		rec.Query = state.Query
+		if state.Approval != nil {
+			rec.Approver = state.Approval.Approver
+			rec.Decision = audit.DecisionApproved
+		}
+		return nil
+	})
+	return rec
+}
+
+// proposeExample 审批通过并执行成功的查询提议为待确认的 NL2SQL 示例，后台写入不影响本次返回
+func proposeExample(state *FinalGraphRequest) {
+	if config.Cfg.SQLKnowledgeConf.AutoPropose != "true" || state.Approval == nil ||
+		state.Guard == nil || state.Guard.Type != sql_guard.StatementRead {
+		return
+	}
+	question, sql, tables, sessionID := state.Query, state.SQL, state.Guard.Tables, state.SessionID
+	// 追问改写后的需求是独立完整的，更适合作为示例
+	if state.Standalone != "" {
+		question = state.Standalone
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		e, err := rag_flow.ProposeSQLExample(ctx, question, sql, tables, sessionID)
+		if err != nil {
+			log.Printf("提议 NL2SQL 示例失败: sessionID=%s, err=%v", sessionID, err)
+			return
+		}
+		if e != nil {
+			log.Printf("已提议 NL2SQL 示例等待确认: id=%s", e.ID)
+		}
+	}()
+}
+
+// resultToMsgs 读取执行结果和尝试记录，生成最终的事件消息
+func resultToMsgs(ctx context.Context, analysis *analyst_tools.AnalysisResult) ([]*schema.Message, error) {
+	var result string
+	var attempts []SQLAttempt
+	_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+		result = state.Result
+		attempts = state.Attempts
+		return nil
+	})
+
+	msgs, err := AnalysisToMsgs(result, analysis)
+	if err != nil {
+		return nil, err
+	}
+	return appendAttemptsMsg(msgs, attempts)
+}
+
+// appendAttemptsMsg 发生过修正时追加尝试记录事件
+func appendAttemptsMsg(msgs []*schema.Message, attempts []SQLAttempt) ([]*schema.Message, error) {
+	if len(attempts) <= 1 {
+		return msgs, nil
+	}
+	data, err := json.Marshal(attempts)
+	if err != nil {
+		return nil, fmt.Errorf("序列化尝试记录失败: %w", err)
+	}
+	return append(msgs, newEventMsg(EventAttempts, string(data))), nil
+}
+
+func newEventMsg(event, content string) *schema.Message {
+	msg := schema.AssistantMessage(content, nil)
+	msg.Extra = map[string]any{EventKey: event}
+	return msg
+}
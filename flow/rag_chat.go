@@ -6,6 +6,7 @@ import (
 	"go-agent/model/chat_model"
 	"go-agent/rag/rag_tools"
 	"go-agent/tool/memory"
+	"log"
 	"sync"
 
 	"go-agent/rag/rag_flow"
@@ -56,6 +57,16 @@ func GetRAGChatFlow() (compose.Runnable[RAGChatInput, *schema.Message], error) {
 }
 
 func buildRAGChatFlow(ctx context.Context, store memory.Store, taskModel model.BaseChatModel) (compose.Runnable[RAGChatInput, *schema.Message], error) {
+	g, err := BuildRAGChatGraph(ctx, store, taskModel)
+	if err != nil {
+		return nil, err
+	}
+	return g.Compile(ctx, compose.WithGraphName("RAGGraphOptimized"))
+}
+
+// BuildRAGChatGraph 构建带记忆的 RAG 对话图：改写问题、检索、结合历史回答并写回会话记忆。
+// 总控图以子图方式嵌入，与 /api/rag/ask 共用同一个 memory.Store
+func BuildRAGChatGraph(ctx context.Context, store memory.Store, taskModel model.BaseChatModel) (*compose.Graph[RAGChatInput, *schema.Message], error) {
 	const (
 		PreProcess = "preProcess"
 		Rewrite    = "rewrite"
@@ -63,7 +74,11 @@ func buildRAGChatFlow(ctx context.Context, store memory.Store, taskModel model.B
 		Chat       = "chat"
 	)
 
-	cm, _ := chat_model.GetChatModel(ctx, "rewrite")
+	// 未注册专用的改写模型时使用任务模型
+	cm, err := chat_model.GetChatModel(ctx, "rewrite")
+	if err != nil {
+		cm = taskModel
+	}
 	sm := &memory.Summarizer{Model: taskModel, MaxHistoryLen: 3}
 
 	retrieverSubGraph, err := rag_flow.BuildRetrieverGraph(ctx)
@@ -80,7 +95,12 @@ func buildRAGChatFlow(ctx context.Context, store memory.Store, taskModel model.B
 	_ = g.AddLambdaNode(PreProcess, compose.InvokableLambda(func(ctx context.Context, in RAGChatInput) (RAGChatInput, error) {
 		_ = compose.ProcessState[*GraphState](ctx, func(ctx context.Context, state *GraphState) error {
 			state.Input = in
-			sess, _ := store.Get(ctx, in.SessionID)
+			sess, err := store.Get(ctx, in.SessionID)
+			if err != nil {
+				// 读取失败时按新会话处理，不影响本次问答
+				log.Printf("读取会话记忆失败: sessionID=%s, err=%v", in.SessionID, err)
+				sess = &memory.Session{ID: in.SessionID}
+			}
 			state.Session = sess
 			return nil
 		})
@@ -147,15 +167,7 @@ func buildRAGChatFlow(ctx context.Context, store memory.Store, taskModel model.B
 		return in, nil
 	}),
 		compose.WithStatePostHandler(func(ctx context.Context, out *schema.Message, state *GraphState) (*schema.Message, error) {
-			state.Session.History = append(state.Session.History, schema.UserMessage(state.Input.Query))
-			state.Session.History = append(state.Session.History, out)
-
-			go func(s *memory.Session) {
-				bgCtx := context.Background()
-				_ = sm.Compress(bgCtx, s)
-				_ = store.Save(bgCtx, s.ID, s)
-			}(state.Session)
-
+			rememberTurn(ctx, store, sm, state.Input.SessionID, schema.UserMessage(state.Input.Query), out)
 			return out, nil
 		}),
 	)
@@ -168,5 +180,5 @@ func buildRAGChatFlow(ctx context.Context, store memory.Store, taskModel model.B
 	_ = g.AddEdge("ConstructMessages", Chat)
 	_ = g.AddEdge(Chat, compose.END)
 
-	return g.Compile(ctx, compose.WithGraphName("RAGGraphOptimized"))
+	return g, nil
 }
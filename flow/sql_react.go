@@ -6,69 +6,187 @@ import (
 	"go-agent/config"
 	"go-agent/model/chat_model"
 	"go-agent/rag/rag_flow"
+	"go-agent/rag/rag_tools"
+	"go-agent/rag/rag_tools/sqlknowledge"
 	"go-agent/tool"
+	"go-agent/tool/memory"
+	"go-agent/tool/sql_guard"
+	"go-agent/tool/sql_tools"
+	"log"
+	"sort"
 	"strings"
 
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
+// SQLRewritePrompt 结合会话历史把追问改写为独立的数据查询需求
+const SQLRewritePrompt = `参考以下背景摘要和最近对话，将用户最后一次提问改写为一个独立、完整的数据查询需求，补全省略的指标、维度、时间范围和筛选条件。
+如果提问本身已经完整，或与之前的对话无关，原样输出。
+背景摘要: %s
+最近对话: %s
+用户提问: %s
+改写后的数据查询需求（直接输出需求）: `
+
+// conversationTurns SQL 提示词中附带的最近消息数
+const conversationTurns = 4
+
 type SQLFlowState struct {
 	History []*schema.Message `json:"history"`
 }
 
 const (
-	SQL_Retrieve = "SQL_Retrieve"
-	ToTplVar     = "ToTplVar"
-	SQL_Tpl      = "SQL_Tpl"
-	SQL_Model    = "SQL_Model"
-	Approve      = "Approve"
+	SQL_Rewrite   = "SQL_Rewrite"
+	SQL_Retrieve  = "SQL_Retrieve"
+	SQL_Knowledge = "SQL_Knowledge"
+	ToTplVar      = "ToTplVar"
+	SQL_Tpl       = "SQL_Tpl"
+	SQL_Model     = "SQL_Model"
+	SQL_Guard     = "SQL_Guard"
+	Approve       = "Approve"
 )
 
+// ApprovalInfo 审批中断携带的信息
+type ApprovalInfo struct {
+	SessionID string                  `json:"session_id"`
+	SQL       string                  `json:"sql"`
+	Type      sql_guard.StatementType `json:"type"`
+	Risk      sql_guard.RiskLevel     `json:"risk"`
+	Tables    []string                `json:"tables,omitempty"`
+	Reasons   []string                `json:"reasons,omitempty"`
+
+	Preview *sql_tools.ExplainPreview `json:"preview,omitempty"` // EXPLAIN 执行计划预览
+}
+
+// ApprovalDecision 审批通过时的恢复数据，拒绝不会恢复图的执行
+type ApprovalDecision struct {
+	SQL      string `json:"sql,omitempty"` // 审批人修改后的 SQL，为空时执行原 SQL
+	Reason   string `json:"reason,omitempty"`
+	Approver string `json:"approver,omitempty"`
+}
+
 func init() {
 	schema.Register[*SQLFlowState]()
+	schema.Register[*ApprovalInfo]()
+	schema.Register[*ApprovalDecision]()
 }
 
-func BuildReactGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*schema.Message], error) {
+// BuildReactGraph 构建 SQL 生成子图，memStore 为会话记忆，rewriteModel 用于结合历史改写追问
+func BuildReactGraph(ctx context.Context, memStore memory.Store, rewriteModel model.BaseChatModel) (*compose.Graph[[]*schema.Message, []*schema.Message], error) {
 	g := compose.NewGraph[[]*schema.Message, []*schema.Message]()
 
-	// RAG 检索：召回行业黑话、表结构信息等
-	retriever, err := rag_flow.BuildRetrieverGraph(ctx)
+	// 追问改写：结合会话历史把“按地区拆开看看”之类的追问改写为独立的查询需求，检索与生成都使用改写后的需求
+	_ = g.AddLambdaNode(SQL_Rewrite, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		var sessionID, query, standalone string
+		repairing := false
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			sessionID, query, standalone = state.SessionID, state.Query, state.Standalone
+			repairing = len(state.Attempts) > 0
+			return nil
+		})
+		// 修正循环中沿用本轮第一次改写的结果
+		if repairing && standalone != "" {
+			return []*schema.Message{schema.UserMessage(standalone)}, nil
+		}
+
+		standalone, conversation := query, ""
+		sess, err := memStore.Get(ctx, sessionID)
+		if err != nil {
+			log.Printf("读取会话记忆失败: sessionID=%s, err=%v", sessionID, err)
+		} else if len(sess.History) > 0 || sess.Summary != "" {
+			rewritten, err := rag_tools.Rewrite(ctx, sess.Summary, SQLRewritePrompt, sess.History, query, rewriteModel)
+			if err != nil {
+				log.Printf("改写追问失败，使用原始问题: sessionID=%s, err=%v", sessionID, err)
+			} else if strings.TrimSpace(rewritten) != "" {
+				standalone = strings.TrimSpace(rewritten)
+			}
+			conversation = formatConversation(sess)
+		}
+
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			state.Standalone = standalone
+			state.Conversation = conversation
+			return nil
+		})
+		return []*schema.Message{schema.UserMessage(standalone)}, nil
+	}))
+
+	// 表结构检索：只召回表结构集合中的文档，不与上传的文档混在一起
+	retriever, err := rag_flow.BuildSchemaRetrieverGraph(ctx)
 	if err != nil {
 		return nil, err
 	}
-	_ = g.AddGraphNode(SQL_Retrieve, retriever)
+	_ = g.AddGraphNode(SQL_Retrieve, retriever, compose.WithOutputKey("schema_docs"))
 
-	// 转换：[]*Document -> map[string]any (将检索结果包装为模板变量)
-	_ = g.AddLambdaNode(ToTplVar, compose.InvokableLambda(func(ctx context.Context, input []*schema.Document) (map[string]any, error) {
-		var query string
-		// 从全局 State 获取原始 Query
+	// 业务术语与示例检索：与表结构并行召回，只包含已确认的条目
+	knowledge, err := rag_flow.BuildSQLKnowledgeRetrieverGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = g.AddGraphNode(SQL_Knowledge, knowledge, compose.WithOutputKey("knowledge_docs"))
+
+	// 转换：表结构与术语示例 -> map[string]any (将检索结果包装为模板变量)
+	_ = g.AddLambdaNode(ToTplVar, compose.InvokableLambda(func(ctx context.Context, input map[string]any) (map[string]any, error) {
+		var query, prevDocs, conversation string
+		var attempts []SQLAttempt
+		// 从全局 State 获取改写后的需求、意图识别抽取的槽位、对话上下文与历史尝试
 		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
-			query = state.Query
+			query = state.Standalone
+			if query == "" {
+				query = state.Query
+			}
+			conversation = state.Conversation
+			if state.Intent != nil {
+				query += formatSlots(state.Intent.Slots)
+			}
+			prevDocs = state.Docs
+			attempts = state.Attempts
 			return nil
 		})
 
 		docsStr := ""
-		for _, d := range input {
+		schemaDocs, _ := input["schema_docs"].([]*schema.Document)
+		for _, d := range schemaDocs {
 			docsStr += d.Content + "\n"
 		}
 
+		// 修正时沿用生成失败 SQL 时的表结构
+		repair := formatRepairContext(attempts)
+		if repair != "" && prevDocs != "" {
+			docsStr = prevDocs
+		}
+
 		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
 			state.Docs = docsStr
 			return nil
 		})
 
+		knowledgeDocs, _ := input["knowledge_docs"].([]*schema.Document)
+		entries := make([]*sqlknowledge.Entry, 0, len(knowledgeDocs))
+		for _, d := range knowledgeDocs {
+			if e, err := sqlknowledge.FromMetadata(d.MetaData); err == nil {
+				entries = append(entries, e)
+			}
+		}
+
 		return map[string]any{
-			"query": query,
-			"docs":  docsStr,
+			"query":    query,
+			"docs":     docsStr,
+			"glossary": sqlknowledge.FormatGlossary(entries),
+			"context":  conversation,
+			"examples": sqlknowledge.FewShotMessages(entries),
+			"repair":   repair,
 		}, nil
 	}))
 
 	// SQL 模板节点
 	sqlTemp := prompt.FromMessages(schema.FString,
 		schema.SystemMessage("你是一个SQL专家。请根据提供的表结构信息生成SQL。\n只输出SQL，不要有其他解释。\n你只能使用自然语言不能使用markdown格式"),
-		schema.UserMessage("相关表结构：\n{docs}\n\n用户需求：{query}"),
+		// 已确认的 NL2SQL 示例作为 few-shot 对话
+		schema.MessagesPlaceholder("examples", true),
+		schema.UserMessage("相关表结构：\n{docs}{glossary}{context}\n\n用户需求：{query}{repair}"),
 	)
 	_ = g.AddChatTemplateNode(SQL_Tpl, sqlTemp)
 
@@ -79,6 +197,22 @@ func BuildReactGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*
 	}
 	_ = g.AddChatModelNode(SQL_Model, chat)
 
+	// SQL 安全检查：解析语句、判定风险等级并写入全局 State
+	policy := sql_guard.PolicyFromConfig()
+	_ = g.AddLambdaNode(SQL_Guard, compose.InvokableLambda(func(ctx context.Context, input *schema.Message) (*schema.Message, error) {
+		if input == nil {
+			return nil, fmt.Errorf("input is nil")
+		}
+		sql := sql_guard.Normalize(input.Content)
+		report := sql_guard.Analyze(sql, policy)
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			state.SQL = sql
+			state.Guard = report
+			return nil
+		})
+		return schema.AssistantMessage(sql, nil), nil
+	}))
+
 	// 转换节点
 	_ = g.AddLambdaNode(Trans_List, compose.InvokableLambda(tool.MsgToMsgs))
 
@@ -90,35 +224,128 @@ func BuildReactGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*
 			return nil
 		})
 
-		if isResume, hasData, data := compose.GetResumeContext[string](ctx); isResume && hasData {
-			if strings.Contains(strings.ToUpper(data), "YES") {
-				// 如果批准了，返回SQL
-				return schema.AssistantMessage("YES: "+stateSQL, nil), nil
-			}
-			return schema.AssistantMessage(data, nil), nil
+		if isResume, hasData, decision := compose.GetResumeContext[*ApprovalDecision](ctx); isResume && hasData {
+			sql := stateSQL
+			edited := sql_guard.Normalize(decision.SQL)
+			_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+				state.Approval = decision
+				// 审批人修改过的 SQL 需要重新经过安全检查，被拦截时由总控图返回拦截原因
+				if edited != "" && edited != stateSQL {
+					sql = edited
+					state.SQL = edited
+					state.Guard = sql_guard.Analyze(edited, policy)
+				}
+				return nil
+			})
+			return schema.AssistantMessage(sql, nil), nil
 		}
 
 		if input == nil {
 			return nil, fmt.Errorf("input is nil")
 		}
 
-		// 保存SQL到状态中
+		info := &ApprovalInfo{SQL: input.Content}
 		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
-			state.SQL = input.Content
+			info.SessionID = state.SessionID
+			if state.Guard != nil {
+				info.Type = state.Guard.Type
+				info.Risk = state.Guard.Risk
+				info.Tables = state.Guard.Tables
+				info.Reasons = state.Guard.Reasons
+			}
 			return nil
 		})
 
-		return nil, compose.Interrupt(ctx, input.Content)
+		// DDL 无法 EXPLAIN，其余语句附带执行计划供审批人参考
+		if config.Cfg.SQLConf.ExplainEnabled == "true" && info.Type != sql_guard.StatementDDL {
+			info.Preview = sql_tools.Explain(ctx, info.SQL)
+		}
+
+		return nil, compose.Interrupt(ctx, info)
 	}))
 
 	// 连线
-	_ = g.AddEdge(compose.START, SQL_Retrieve)
+	_ = g.AddEdge(compose.START, SQL_Rewrite)
+	_ = g.AddEdge(SQL_Rewrite, SQL_Retrieve)
+	_ = g.AddEdge(SQL_Rewrite, SQL_Knowledge)
 	_ = g.AddEdge(SQL_Retrieve, ToTplVar)
+	_ = g.AddEdge(SQL_Knowledge, ToTplVar)
 	_ = g.AddEdge(ToTplVar, SQL_Tpl)
 	_ = g.AddEdge(SQL_Tpl, SQL_Model)
-	_ = g.AddEdge(SQL_Model, Approve)
+	_ = g.AddEdge(SQL_Model, SQL_Guard)
+	// 被拦截的 SQL 不进入审批，由总控图返回拦截原因
+	_ = g.AddBranch(SQL_Guard, compose.NewGraphBranch(func(ctx context.Context, input *schema.Message) (endNode string, err error) {
+		blocked := false
+		_ = compose.ProcessState[*FinalGraphRequest](ctx, func(ctx context.Context, state *FinalGraphRequest) error {
+			blocked = state.Guard != nil && state.Guard.Blocked()
+			return nil
+		})
+		if blocked {
+			return Trans_List, nil
+		}
+		return Approve, nil
+	}, map[string]bool{
+		Approve:    true,
+		Trans_List: true,
+	}))
 	_ = g.AddEdge(Approve, Trans_List)
 	_ = g.AddEdge(Trans_List, compose.END)
 
 	return g, nil
 }
+
+// formatConversation 将会话摘要与最近几条消息整理为提示词片段，上一轮的 SQL 与结果摘要包含在其中
+func formatConversation(sess *memory.Session) string {
+	history := sess.History
+	if len(history) > conversationTurns {
+		history = history[len(history)-conversationTurns:]
+	}
+	if len(history) == 0 && sess.Summary == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n对话上下文（用户需求已结合上下文改写，之前的 SQL 与结果可供参考）：")
+	if sess.Summary != "" {
+		sb.WriteString("\n背景摘要: " + sess.Summary)
+	}
+	for _, m := range history {
+		sb.WriteString(fmt.Sprintf("\n[%s]: %s", m.Role, truncateRunes(m.Content, 500)))
+	}
+	return sb.String()
+}
+
+// formatSlots 将意图识别抽取的槽位附加到用户需求之后，没有槽位时返回空串
+func formatSlots(slots map[string]string) string {
+	if len(slots) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(slots))
+	for k := range slots {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+slots[k])
+	}
+	return "\n识别到的条件：" + strings.Join(parts, "，")
+}
+
+// formatRepairContext 将执行失败的尝试整理为修正提示，没有失败记录时返回空串
+func formatRepairContext(attempts []SQLAttempt) string {
+	if len(attempts) == 0 || attempts[len(attempts)-1].Error == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n之前生成的SQL执行失败，请根据错误信息和表结构修正，不要重复同样的错误：")
+	for _, a := range attempts {
+		if a.Error == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n第%d次SQL：%s\n错误信息：%s", a.Attempt, a.SQL, a.Error))
+	}
+	return sb.String()
+}
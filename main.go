@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"go-agent/api"
 	"go-agent/config"
 	"go-agent/flow"
@@ -9,18 +10,31 @@ import (
 	"go-agent/rag/rag_flow"
 	"go-agent/rag/rag_tools/db"
 	"go-agent/rag/rag_tools/indexer"
+	"go-agent/rag/rag_tools/rerank"
 	"go-agent/rag/rag_tools/retriever"
+	"go-agent/tool/audit"
 	"go-agent/tool/document"
+	"go-agent/tool/intent"
+	"go-agent/tool/mcp_client"
 	"go-agent/tool/memory"
-	"go-agent/tool/sql_tools"
 	"go-agent/tool/storage"
 	"go-agent/tool/trace"
 	"log"
+	"os"
 
 	"github.com/cloudwego/eino-ext/devops"
 )
 
 func main() {
+	mcpStdio := flag.Bool("mcp-stdio", false, "以 stdio 方式运行 go-agent 的 MCP 服务器，替代 HTTP 服务")
+	flag.Parse()
+
+	// stdio 模式下 stdout 用于协议通信，初始化过程中的输出改写到 stderr
+	stdout := os.Stdout
+	if *mcpStdio {
+		os.Stdout = os.Stderr
+	}
+
 	var err error
 	ctx := context.Background()
 
@@ -56,6 +70,9 @@ func main() {
 	// 初始化召回器
 	retriever.NewRetriever()
 
+	// 初始化重排器
+	rerank.NewReranker()
+
 	// 初始化解析器
 	document.Parser, err = document.NewParser(ctx)
 	if err != nil {
@@ -87,12 +104,33 @@ func main() {
 	}
 	defer closeCoze()
 
-	// 初始化MCP
-	err = sql_tools.InitMCPTools(ctx)
+	// 初始化审计日志
+	err = audit.InitAuditLogger(ctx)
+	if err != nil {
+		log.Fatalf("audit logger init fail: %v", err)
+	}
+	defer audit.Close()
+
+	// 初始化意图日志，记录路由结果与误判反馈
+	err = intent.InitLog(config.Cfg.IntentConf.LogFile)
+	if err != nil {
+		log.Fatalf("intent log init fail: %v", err)
+	}
+	defer intent.CloseLog()
+
+	// 初始化会话记忆存储，RAG 对话与 SQL 追问共用
+	err = memory.InitStore(ctx)
+	if err != nil {
+		log.Fatalf("memory store init fail: %v", err)
+	}
+	defer memory.Close()
+
+	// 初始化MCP客户端，各服务器在首次获取工具时连接
+	err = mcp_client.InitMCPClients(ctx)
 	if err != nil {
-		log.Fatalf("MCP tools init fail: %v", err)
+		log.Fatalf("MCP clients init fail: %v", err)
 	}
-	log.Println("MCP 工具连接已建立")
+	defer mcp_client.Close()
 
 	// 预编译索引图
 	err = rag_flow.InitIndexingGraph(ctx)
@@ -101,8 +139,26 @@ func main() {
 	}
 	log.Println("IndexingGraph 已编译缓存")
 
-	// 预编译RAG对话图
-	memStore := memory.NewMemoryStore()
+	// 初始化表结构知识库，SQL 生成只检索该集合
+	err = rag_flow.InitSchemaIndex(ctx)
+	if err != nil {
+		log.Fatalf("schema index init fail: %v", err)
+	}
+
+	// 初始化业务术语与 NL2SQL 示例知识库，需在构建 SQL 子图之前创建集合
+	err = rag_flow.InitSQLKnowledge(ctx)
+	if err != nil {
+		log.Fatalf("sql knowledge init fail: %v", err)
+	}
+
+	// 预编译检索图，供 MCP 工具直接调用
+	err = rag_flow.InitRetrieverGraph(ctx)
+	if err != nil {
+		log.Fatalf("RetrieverGraph init fail: %v", err)
+	}
+
+	// 预编译RAG对话图，会话记忆与总控图共用
+	memStore := memory.Default
 	taskModel, err := chat_model.GetChatModel(ctx, config.Cfg.ChatModelType)
 	if err != nil {
 		log.Fatalf("task model init fail: %v", err)
@@ -115,11 +171,17 @@ func main() {
 
 	// 预编译全局图（使用Redis缓存）
 	checkPointStore := storage.NewRedisCheckPointStore()
-	err = flow.InitFinalGraph(ctx, checkPointStore)
+	err = flow.InitFinalGraph(ctx, checkPointStore, memStore, taskModel)
 	if err != nil {
 		log.Fatalf("FinalGraph init fail: %v", err)
 	}
 	log.Println("FinalGraph 已编译缓存")
 
+	if *mcpStdio {
+		if err := api.RunMCPStdio(ctx, stdout); err != nil {
+			log.Fatalf("MCP stdio server exit: %v", err)
+		}
+		return
+	}
 	api.Run()
 }
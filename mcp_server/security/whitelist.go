@@ -0,0 +1,134 @@
+package security
+
+import (
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/sql_guard"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Whitelist MCP 服务器的 SQL 白名单。
+// 多语句、DROP/TRUNCATE、缺少 WHERE 的 UPDATE/DELETE 等由 sql_guard 的语法树检查统一拦截，不受白名单影响
+type Whitelist struct {
+	AllowedOperations []string          `yaml:"allowed_operations"` // 允许的操作，如 SELECT、INSERT、CREATE TABLE
+	ForbiddenPatterns []string          `yaml:"forbidden_patterns"` // 忽略大小写的正则，命中即拒绝
+	AuditLevel        map[string]string `yaml:"audit_level"`        // 操作 -> 日志级别
+
+	patterns []*regexp.Regexp
+}
+
+// DefaultWhitelist 未提供白名单文件时使用：只允许查询
+func DefaultWhitelist() *Whitelist {
+	w := &Whitelist{
+		AllowedOperations: []string{"SELECT"},
+		ForbiddenPatterns: []string{`LOAD_FILE`, `INTO\s+OUTFILE`, `INTO\s+DUMPFILE`},
+		AuditLevel:        map[string]string{"SELECT": "info"},
+	}
+	_ = w.compile()
+	return w
+}
+
+// LoadWhitelist 从 YAML 文件加载白名单，path 为空时返回默认白名单
+func LoadWhitelist(path string) (*Whitelist, error) {
+	if path == "" {
+		return DefaultWhitelist(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取白名单文件失败: %w", err)
+	}
+
+	w := &Whitelist{}
+	if err := yaml.Unmarshal(data, w); err != nil {
+		return nil, fmt.Errorf("解析白名单文件失败: %w", err)
+	}
+	if err := w.compile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Whitelist) compile() error {
+	w.patterns = make([]*regexp.Regexp, 0, len(w.ForbiddenPatterns))
+	for _, p := range w.ForbiddenPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("非法的禁止模式 %q: %w", p, err)
+		}
+		w.patterns = append(w.patterns, re)
+	}
+	return nil
+}
+
+// Check 检查 SQL 是否允许执行，返回语法树检查结果
+func (w *Whitelist) Check(sql string) (*sql_guard.Report, error) {
+	sql = sql_guard.Normalize(sql)
+	for i, re := range w.patterns {
+		if re.MatchString(sql) {
+			return nil, fmt.Errorf("SQL 命中禁止模式 %s", w.ForbiddenPatterns[i])
+		}
+	}
+
+	report := sql_guard.Analyze(sql, w.policy())
+	if report.Blocked() {
+		return report, fmt.Errorf("SQL 被安全策略拦截: %s", strings.Join(report.Reasons, "；"))
+	}
+	if op := readOperation(report); !w.allowed(op) {
+		return report, fmt.Errorf("操作 %s 不在白名单中", report.Operation)
+	}
+	return report, nil
+}
+
+// CheckTable 检查表是否可以访问（受 SQL_ALLOW_TABLES / SQL_DENY_TABLES 约束）
+func (w *Whitelist) CheckTable(table string) error {
+	_, err := w.Check(fmt.Sprintf("SELECT * FROM `%s`", table))
+	return err
+}
+
+// Level 返回操作的审计日志级别，未配置时为 info
+func (w *Whitelist) Level(operation string) string {
+	if level, ok := w.AuditLevel[strings.ToUpper(operation)]; ok {
+		return level
+	}
+	return "info"
+}
+
+// policy 写操作与 DDL 是否放行由白名单决定，表黑白名单沿用 SQL 安全策略配置
+func (w *Whitelist) policy() *sql_guard.Policy {
+	policy := &sql_guard.Policy{}
+	if config.Cfg != nil {
+		policy = sql_guard.PolicyFromConfig()
+	}
+	policy.AllowWrite = w.allowed("INSERT") || w.allowed("REPLACE") || w.allowed("UPDATE") || w.allowed("DELETE")
+	policy.AllowDDL = false
+	for _, op := range w.AllowedOperations {
+		switch strings.ToUpper(strings.TrimSpace(op)) {
+		case "SELECT", "INSERT", "REPLACE", "UPDATE", "DELETE":
+		default:
+			policy.AllowDDL = true
+		}
+	}
+	return policy
+}
+
+func (w *Whitelist) allowed(operation string) bool {
+	for _, op := range w.AllowedOperations {
+		if strings.EqualFold(strings.TrimSpace(op), operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// readOperation 只读的 SHOW 与 EXPLAIN 按 SELECT 处理。
+// EXPLAIN ANALYZE 会实际执行被解释的语句，解释写操作时按被解释语句的操作（如 DELETE）检查
+func readOperation(report *sql_guard.Report) string {
+	if report.Type == sql_guard.StatementRead && (report.Operation == "SHOW" || report.Operation == "EXPLAIN") {
+		return "SELECT"
+	}
+	return report.Operation
+}
@@ -0,0 +1,71 @@
+package mcp_server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-agent/config"
+	"go-agent/mcp_server/security"
+	"go-agent/mcp_server/tools"
+	"go-agent/tool/storage"
+	"strconv"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	serverName    = "go-agent-mcp-server"
+	serverVersion = "1.0.0"
+)
+
+// Options MCP 服务器配置
+type Options struct {
+	DB        *sql.DB
+	Dialect   string // mysql 或 sqlite，默认 mysql
+	Whitelist *security.Whitelist
+	MaxRows   int
+}
+
+// NewServer 创建暴露 mysql_query、list_tables、describe_table 及写工具的 MCP 服务器
+func NewServer(opts *Options) *mcp.Server {
+	s := mcp.NewServer(&mcp.Implementation{
+		Name:    serverName,
+		Version: serverVersion,
+	}, nil)
+	tools.Register(s, &tools.Deps{
+		DB:        opts.DB,
+		Dialect:   opts.Dialect,
+		Whitelist: opts.Whitelist,
+		MaxRows:   opts.MaxRows,
+	})
+	return s
+}
+
+// OptionsFromConfig 按全局配置连接 MySQL 并加载白名单
+func OptionsFromConfig(ctx context.Context) (*Options, error) {
+	whitelist, err := security.LoadWhitelist(config.Cfg.MCPConf.WhitelistPath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := storage.NewMySQL(ctx, config.Cfg.MySQLConf)
+	if err != nil {
+		return nil, err
+	}
+	maxRows, _ := strconv.Atoi(config.Cfg.MCPConf.MaxRows)
+
+	return &Options{
+		DB:        db,
+		Dialect:   tools.DialectMySQL,
+		Whitelist: whitelist,
+		MaxRows:   maxRows,
+	}, nil
+}
+
+// ConnectInMemory 在进程内启动服务器，并返回通过内存传输连接的客户端会话
+func ConnectInMemory(ctx context.Context, s *mcp.Server, cli *mcp.Client) (*mcp.ClientSession, error) {
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	if _, err := s.Connect(ctx, serverTransport, nil); err != nil {
+		return nil, fmt.Errorf("MCP 服务器启动失败: %w", err)
+	}
+	return cli.Connect(ctx, clientTransport, nil)
+}
@@ -0,0 +1,166 @@
+package mcp_server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-agent/mcp_server/security"
+	"go-agent/mcp_server/tools"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	_ "modernc.org/sqlite"
+)
+
+// connect 在内存 SQLite 上启动服务器并通过内存传输连接
+func connect(t *testing.T, whitelist *security.Whitelist) *mcp.ClientSession {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // 每个连接都是独立的内存库
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO users (name, age) VALUES ('alice', 30), ('bob', 25)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s := NewServer(&Options{DB: db, Dialect: tools.DialectSQLite, Whitelist: whitelist})
+	cs, err := ConnectInMemory(ctx, s, mcp.NewClient(&mcp.Implementation{Name: "test", Version: "1.0.0"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func writeWhitelist(t *testing.T) *security.Whitelist {
+	t.Helper()
+	w, err := security.LoadWhitelist("whitelist.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// call 调用工具，返回文本结果与是否为错误结果
+func call(t *testing.T, cs *mcp.ClientSession, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	var text strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			text.WriteString(tc.Text)
+		}
+	}
+	return text.String(), res.IsError
+}
+
+func rows(t *testing.T, text string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	if err := json.Unmarshal([]byte(text), &records); err != nil {
+		t.Fatalf("解析结果失败: %v, text=%s", err, text)
+	}
+	return records
+}
+
+func TestServerQuery(t *testing.T) {
+	cs := connect(t, security.DefaultWhitelist())
+
+	text, isErr := call(t, cs, "list_tables", map[string]any{})
+	if isErr || len(rows(t, text)) != 1 || rows(t, text)[0]["table_name"] != "users" {
+		t.Errorf("list_tables = %s", text)
+	}
+
+	text, isErr = call(t, cs, "describe_table", map[string]any{"table": "users"})
+	if isErr || len(rows(t, text)) != 3 {
+		t.Errorf("describe_table = %s", text)
+	}
+	if _, isErr = call(t, cs, "describe_table", map[string]any{"table": "missing"}); !isErr {
+		t.Error("不存在的表应返回错误")
+	}
+
+	text, isErr = call(t, cs, "mysql_query", map[string]any{"sql": "SELECT name FROM users WHERE age > 26"})
+	if isErr {
+		t.Fatalf("mysql_query: %s", text)
+	}
+	if got := rows(t, text); len(got) != 1 || got[0]["name"] != "alice" {
+		t.Errorf("mysql_query = %s", text)
+	}
+}
+
+func TestServerWrite(t *testing.T) {
+	cs := connect(t, writeWhitelist(t))
+
+	text, isErr := call(t, cs, "insert_rows", map[string]any{
+		"table": "users",
+		"rows":  []map[string]any{{"name": "carol", "age": 41}},
+	})
+	if isErr || !strings.Contains(text, `"affectedRows":1`) {
+		t.Errorf("insert_rows = %s", text)
+	}
+
+	text, isErr = call(t, cs, "update_rows", map[string]any{
+		"table": "users",
+		"set":   map[string]any{"age": 26},
+		"where": "name = ?",
+		"args":  []any{"bob"},
+	})
+	if isErr || !strings.Contains(text, `"affectedRows":1`) {
+		t.Errorf("update_rows = %s", text)
+	}
+
+	text, isErr = call(t, cs, "delete_rows", map[string]any{"table": "users", "where": "age > ?", "args": []any{40}})
+	if isErr || !strings.Contains(text, `"affectedRows":1`) {
+		t.Errorf("delete_rows = %s", text)
+	}
+
+	text, _ = call(t, cs, "mysql_query", map[string]any{"sql": "SELECT name, age FROM users ORDER BY id"})
+	got := rows(t, text)
+	if len(got) != 2 || got[1]["name"] != "bob" || got[1]["age"] != float64(26) {
+		t.Errorf("写入后的数据 = %s", text)
+	}
+}
+
+func TestServerBlocked(t *testing.T) {
+	readOnly := connect(t, security.DefaultWhitelist())
+	writable := connect(t, writeWhitelist(t))
+
+	tests := []struct {
+		name string
+		cs   *mcp.ClientSession
+		tool string
+		args map[string]any
+	}{
+		{"DROP", writable, "mysql_query", map[string]any{"sql": "DROP TABLE users"}},
+		{"导出文件", writable, "mysql_query", map[string]any{"sql": "SELECT * FROM users INTO OUTFILE '/tmp/users'"}},
+		{"多语句", writable, "mysql_query", map[string]any{"sql": "SELECT 1; DELETE FROM users WHERE id = 1"}},
+		{"UPDATE 缺少 WHERE", writable, "mysql_query", map[string]any{"sql": "UPDATE users SET age = 0"}},
+		{"只读白名单下写入", readOnly, "insert_rows", map[string]any{"table": "users", "rows": []map[string]any{{"name": "eve"}}}},
+		{"只读白名单下 EXPLAIN ANALYZE 删除", readOnly, "mysql_query", map[string]any{"sql": "EXPLAIN ANALYZE DELETE FROM users WHERE id = 1"}},
+		{"WHERE 片段注入", writable, "delete_rows", map[string]any{"table": "users", "where": "1 = 1; DROP TABLE users"}},
+		{"非法表名", writable, "delete_rows", map[string]any{"table": "users`; --", "where": "id = 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text, isErr := call(t, tt.cs, tt.tool, tt.args); !isErr {
+				t.Errorf("应被拦截，实际返回 %s", text)
+			}
+		})
+	}
+
+	// 被拦截的语句没有执行
+	text, _ := call(t, writable, "mysql_query", map[string]any{"sql": "SELECT COUNT(*) AS n FROM users"})
+	if got := rows(t, text); len(got) != 1 || got[0]["n"] != float64(2) {
+		t.Errorf("拦截后的数据 = %s", text)
+	}
+}
@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-agent/mcp_server/security"
+	"go-agent/tool/sql_guard"
+	"log"
+	"regexp"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+
+	defaultMaxRows = 1000
+)
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Deps 工具依赖
+type Deps struct {
+	DB        *sql.DB
+	Dialect   string // mysql 或 sqlite，决定元数据查询方式
+	Whitelist *security.Whitelist
+	MaxRows   int // 单次查询返回的最大行数
+}
+
+type QueryInput struct {
+	SQL string `json:"sql" jsonschema:"要执行的 SQL 语句"`
+}
+
+type TableInput struct {
+	Table string `json:"table" jsonschema:"表名"`
+}
+
+// Register 注册所有数据库工具
+func Register(s *mcp.Server, d *Deps) {
+	if d.Whitelist == nil {
+		d.Whitelist = security.DefaultWhitelist()
+	}
+	if d.MaxRows <= 0 {
+		d.MaxRows = defaultMaxRows
+	}
+	if d.Dialect == "" {
+		d.Dialect = DialectMySQL
+	}
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "mysql_query",
+		Description: "执行一条 SQL 语句。查询返回结果行的 JSON 数组，写操作返回影响行数。语句需通过服务器白名单检查",
+	}, d.query)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "list_tables",
+		Description: "列出当前数据库中的所有表",
+	}, d.listTables)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "describe_table",
+		Description: "查看表的字段、类型、主键与注释",
+	}, d.describeTable)
+	registerWrite(s, d)
+}
+
+func (d *Deps) query(ctx context.Context, req *mcp.CallToolRequest, in QueryInput) (*mcp.CallToolResult, any, error) {
+	report, err := d.Whitelist.Check(in.SQL)
+	if err != nil {
+		d.audit("DENIED", in.SQL, err)
+		return nil, nil, err
+	}
+	return d.run(ctx, report, nil)
+}
+
+// run 执行已通过检查的语句：查询返回结果行，其他语句返回影响行数
+func (d *Deps) run(ctx context.Context, report *sql_guard.Report, args []any) (*mcp.CallToolResult, any, error) {
+	if report.Type == sql_guard.StatementRead {
+		rows, err := d.DB.QueryContext(ctx, report.SQL, args...)
+		if err != nil {
+			d.audit(report.Operation, report.SQL, err)
+			return nil, nil, err
+		}
+		defer rows.Close()
+
+		records, err := scanRows(rows, d.MaxRows)
+		d.audit(report.Operation, report.SQL, err)
+		if err != nil {
+			return nil, nil, err
+		}
+		return textResult(records)
+	}
+
+	res, err := d.DB.ExecContext(ctx, report.SQL, args...)
+	d.audit(report.Operation, report.SQL, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	affected, _ := res.RowsAffected()
+	lastID, _ := res.LastInsertId()
+	return textResult(map[string]int64{"affectedRows": affected, "lastInsertId": lastID})
+}
+
+func (d *Deps) listTables(ctx context.Context, req *mcp.CallToolRequest, in struct{}) (*mcp.CallToolResult, any, error) {
+	query := "SELECT table_name AS table_name, table_comment AS table_comment FROM information_schema.tables " +
+		"WHERE table_schema = DATABASE() ORDER BY table_name"
+	if d.Dialect == DialectSQLite {
+		query = "SELECT name AS table_name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	}
+
+	rows, err := d.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	records, err := scanRows(rows, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 隐藏策略禁止访问的表
+	visible := make([]map[string]any, 0, len(records))
+	for _, r := range records {
+		if name, _ := r["table_name"].(string); d.Whitelist.CheckTable(name) == nil {
+			visible = append(visible, r)
+		}
+	}
+	return textResult(visible)
+}
+
+func (d *Deps) describeTable(ctx context.Context, req *mcp.CallToolRequest, in TableInput) (*mcp.CallToolResult, any, error) {
+	if !identPattern.MatchString(in.Table) {
+		return nil, nil, fmt.Errorf("非法的表名: %s", in.Table)
+	}
+	if err := d.Whitelist.CheckTable(in.Table); err != nil {
+		return nil, nil, err
+	}
+
+	query := "SELECT column_name AS column_name, column_type AS column_type, is_nullable AS is_nullable, " +
+		"column_key AS column_key, column_default AS column_default, column_comment AS column_comment " +
+		"FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position"
+	args := []any{in.Table}
+	if d.Dialect == DialectSQLite {
+		query = fmt.Sprintf("PRAGMA table_info(`%s`)", in.Table)
+		args = nil
+	}
+
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	records, err := scanRows(rows, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("表 %s 不存在", in.Table)
+	}
+	return textResult(records)
+}
+
+// audit 按白名单配置的级别记录执行日志。stdio 模式下 stdout 用于协议通信，日志只能写到 stderr
+func (d *Deps) audit(operation, sql string, err error) {
+	if err != nil {
+		log.Printf("[MCP][%s] %s 执行失败: %s, err=%v", d.Whitelist.Level(operation), operation, sql, err)
+		return
+	}
+	log.Printf("[MCP][%s] %s: %s", d.Whitelist.Level(operation), operation, sql)
+}
+
+// scanRows 将结果集转换为 JSON 友好的行列表，max 大于 0 时截断
+func scanRows(rows *sql.Rows, max int) ([]map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]any, 0)
+	for rows.Next() {
+		if max > 0 && len(records) >= max {
+			break
+		}
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]any, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				record[col] = string(b)
+			} else {
+				record[col] = values[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func textResult(v any) (*mcp.CallToolResult, any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(data)}},
+	}, nil, nil
+}
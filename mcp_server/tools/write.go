@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type InsertInput struct {
+	Table string           `json:"table" jsonschema:"表名"`
+	Rows  []map[string]any `json:"rows" jsonschema:"要插入的行，每行为 列名->值，所有行的列必须相同"`
+}
+
+type UpdateInput struct {
+	Table string         `json:"table" jsonschema:"表名"`
+	Set   map[string]any `json:"set" jsonschema:"要修改的 列名->新值"`
+	Where string         `json:"where" jsonschema:"WHERE 条件（不含 WHERE 关键字），必须引用列，可使用 ? 占位"`
+	Args  []any          `json:"args,omitempty" jsonschema:"WHERE 条件中 ? 占位符对应的参数"`
+}
+
+type DeleteInput struct {
+	Table string `json:"table" jsonschema:"表名"`
+	Where string `json:"where" jsonschema:"WHERE 条件（不含 WHERE 关键字），必须引用列，可使用 ? 占位"`
+	Args  []any  `json:"args,omitempty" jsonschema:"WHERE 条件中 ? 占位符对应的参数"`
+}
+
+// registerWrite 注册结构化写工具，值一律通过占位符传递，生成的语句同样经过白名单检查
+func registerWrite(s *mcp.Server, d *Deps) {
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "insert_rows",
+		Description: "向表中插入一行或多行数据",
+	}, d.insertRows)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "update_rows",
+		Description: "按 WHERE 条件修改表中的数据，WHERE 条件必填",
+	}, d.updateRows)
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "delete_rows",
+		Description: "按 WHERE 条件删除表中的数据，WHERE 条件必填",
+	}, d.deleteRows)
+}
+
+func (d *Deps) insertRows(ctx context.Context, req *mcp.CallToolRequest, in InsertInput) (*mcp.CallToolResult, any, error) {
+	if len(in.Rows) == 0 {
+		return nil, nil, fmt.Errorf("rows 不能为空")
+	}
+	columns := make([]string, 0, len(in.Rows[0]))
+	for col := range in.Rows[0] {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	if err := checkIdents(in.Table, columns); err != nil {
+		return nil, nil, err
+	}
+
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := make([]string, 0, len(in.Rows))
+	args := make([]any, 0, len(in.Rows)*len(columns))
+	for i, row := range in.Rows {
+		if len(row) != len(columns) {
+			return nil, nil, fmt.Errorf("第 %d 行的列与第 1 行不一致", i+1)
+		}
+		for _, col := range columns {
+			v, ok := row[col]
+			if !ok {
+				return nil, nil, fmt.Errorf("第 %d 行缺少列 %s", i+1, col)
+			}
+			args = append(args, v)
+		}
+		values = append(values, placeholders)
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quote(in.Table), quoteList(columns), strings.Join(values, ", "))
+	return d.exec(ctx, stmt, args)
+}
+
+func (d *Deps) updateRows(ctx context.Context, req *mcp.CallToolRequest, in UpdateInput) (*mcp.CallToolResult, any, error) {
+	if len(in.Set) == 0 {
+		return nil, nil, fmt.Errorf("set 不能为空")
+	}
+	if strings.TrimSpace(in.Where) == "" {
+		return nil, nil, fmt.Errorf("UPDATE 必须提供 WHERE 条件")
+	}
+	columns := make([]string, 0, len(in.Set))
+	for col := range in.Set {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	if err := checkIdents(in.Table, columns); err != nil {
+		return nil, nil, err
+	}
+
+	sets := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns)+len(in.Args))
+	for _, col := range columns {
+		sets = append(sets, quote(col)+" = ?")
+		args = append(args, in.Set[col])
+	}
+	args = append(args, in.Args...)
+
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quote(in.Table), strings.Join(sets, ", "), in.Where)
+	return d.exec(ctx, stmt, args)
+}
+
+func (d *Deps) deleteRows(ctx context.Context, req *mcp.CallToolRequest, in DeleteInput) (*mcp.CallToolResult, any, error) {
+	if strings.TrimSpace(in.Where) == "" {
+		return nil, nil, fmt.Errorf("DELETE 必须提供 WHERE 条件")
+	}
+	if err := checkIdents(in.Table, nil); err != nil {
+		return nil, nil, err
+	}
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE %s", quote(in.Table), in.Where)
+	return d.exec(ctx, stmt, in.Args)
+}
+
+// exec 对生成的语句做与 mysql_query 相同的白名单检查后执行，WHERE 片段中的多语句、函数调用等也会被拦截
+func (d *Deps) exec(ctx context.Context, stmt string, args []any) (*mcp.CallToolResult, any, error) {
+	report, err := d.Whitelist.Check(stmt)
+	if err != nil {
+		d.audit("DENIED", stmt, err)
+		return nil, nil, err
+	}
+	return d.run(ctx, report, args)
+}
+
+func checkIdents(table string, columns []string) error {
+	if !identPattern.MatchString(table) {
+		return fmt.Errorf("非法的表名: %s", table)
+	}
+	for _, col := range columns {
+		if !identPattern.MatchString(col) {
+			return fmt.Errorf("非法的列名: %s", col)
+		}
+	}
+	return nil
+}
+
+func quote(ident string) string {
+	return "`" + ident + "`"
+}
+
+func quoteList(idents []string) string {
+	quoted := make([]string, 0, len(idents))
+	for _, ident := range idents {
+		quoted = append(quoted, quote(ident))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,69 @@
+package embedding_model
+
+import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/storage"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// GetQueryEmbedder 检索时使用的嵌入模型，EMBEDDING_CACHE_ENABLED 开启时带查询向量缓存。
+// 入库仍使用 GetEmbeddingModel，文档分块很少重复，缓存只会占用空间
+func GetQueryEmbedder(ctx context.Context, name string) (embedding.Embedder, error) {
+	emb, err := GetEmbeddingModel(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if config.Cfg.CacheConf.EmbeddingCache != "true" {
+		return emb, nil
+	}
+	return NewCachedEmbedder(emb, storage.DefaultRetrievalCache(), name), nil
+}
+
+// CachedEmbedder 为嵌入模型加一层向量缓存，只对未命中的文本调用模型
+type CachedEmbedder struct {
+	embedding.Embedder
+	cache *storage.RetrievalCache
+	model string // 缓存键的前缀，更换嵌入模型后不会读到其他模型的向量
+}
+
+func NewCachedEmbedder(emb embedding.Embedder, cache *storage.RetrievalCache, model string) *CachedEmbedder {
+	return &CachedEmbedder{Embedder: emb, cache: cache, model: model}
+}
+
+func (c *CachedEmbedder) key(text string) string {
+	return c.model + ":" + text
+}
+
+func (c *CachedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	var missIdx []int
+	var missTexts []string
+	for i, text := range texts {
+		if vec, ok := c.cache.GetEmbedding(ctx, c.key(text)); ok {
+			vectors[i] = vec
+			continue
+		}
+		missIdx = append(missIdx, i)
+		missTexts = append(missTexts, text)
+	}
+	if len(missTexts) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := c.Embedder.EmbedStrings(ctx, missTexts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(missTexts) {
+		return nil, fmt.Errorf("嵌入模型返回 %d 个向量，期望 %d 个", len(embedded), len(missTexts))
+	}
+	for j, i := range missIdx {
+		vectors[i] = embedded[j]
+		// 写缓存失败不影响本次检索
+		_ = c.cache.SetEmbedding(ctx, c.key(missTexts[j]), embedded[j])
+	}
+	return vectors, nil
+}
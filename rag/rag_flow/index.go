@@ -3,6 +3,7 @@ package rag_flow
 import (
 	"context"
 	"fmt"
+	"go-agent/config"
 	"go-agent/rag/rag_tools/indexer"
 	document2 "go-agent/tool/document"
 	"sync"
@@ -50,7 +51,8 @@ func buildIndexingGraph(ctx context.Context) (compose.Runnable[document.Source,
 	if err != nil {
 		return nil, err
 	}
-	es, err := indexer.GetIndexer(ctx, "es")
+	// 全文索引一路：Elasticsearch 或进程内 BM25 索引
+	es, err := indexer.GetIndexer(ctx, config.Cfg.LexicalDBType)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +69,8 @@ func buildIndexingGraph(ctx context.Context) (compose.Runnable[document.Source,
 			i, _ := ids.([]string)
 			allIDs = append(allIDs, i...)
 		}
+		// 两路索引都写入后，之前缓存的召回结果不再完整
+		InvalidateCollectionCache(ctx, docsCollection())
 		return allIDs, nil
 	}))
 
@@ -0,0 +1,121 @@
+package rag_flow
+
+import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"go-agent/model/embedding_model"
+	"go-agent/tool/storage"
+	"log"
+	"strconv"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+// RETRIEVAL_CACHE 的取值
+const (
+	CacheNone     = "none"
+	CacheExact    = "exact"
+	CacheSemantic = "semantic"
+)
+
+// docsCollection 文档检索图的缓存作用域，Milvus 集合与全文索引总是一起写入，共用集合名
+func docsCollection() string {
+	return config.Cfg.MilvusConf.CollectionName
+}
+
+// InvalidateCollectionCache 集合写入或删除后调用，使其召回缓存失效
+func InvalidateCollectionCache(ctx context.Context, collection string) {
+	if err := storage.DefaultRetrievalCache().InvalidateCollection(ctx, collection); err != nil {
+		log.Printf("召回缓存失效失败: collection=%s, err=%v", collection, err)
+	}
+}
+
+// retrievalCacheStage 检索图的缓存阶段：检索前查找，重排后写入
+type retrievalCacheStage struct {
+	mode       string
+	threshold  float64
+	collection string
+	cache      *storage.RetrievalCache
+	embedder   embedding.Embedder // semantic 模式下计算查询向量
+}
+
+// cacheLookup 一次检索的缓存查找结果，写缓存时沿用其中的作用域与查询向量
+type cacheLookup struct {
+	Scope  storage.CacheScope
+	Hit    bool
+	Docs   []*schema.Document
+	Vector []float64
+}
+
+// cacheFromConfig 读取 RETRIEVAL_CACHE* 配置
+func cacheFromConfig(ctx context.Context) (*retrievalCacheStage, error) {
+	conf := config.Cfg.CacheConf
+	c := &retrievalCacheStage{mode: conf.Mode, collection: docsCollection()}
+	switch c.mode {
+	case CacheNone:
+		return c, nil
+	case CacheExact, CacheSemantic:
+	default:
+		return nil, fmt.Errorf("不支持的检索缓存模式: %s，可选 none / exact / semantic", conf.Mode)
+	}
+	c.cache = storage.DefaultRetrievalCache()
+	if c.mode != CacheSemantic {
+		return c, nil
+	}
+
+	threshold, err := strconv.ParseFloat(conf.SemanticThreshold, 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("SEMANTIC_CACHE_THRESHOLD 必须在 (0, 1] 之间: %s", conf.SemanticThreshold)
+	}
+	c.threshold = threshold
+	// 与召回使用同一个带缓存的嵌入模型，Milvus 召回时可以直接复用这里算出的查询向量
+	c.embedder, err = embedding_model.GetQueryEmbedder(ctx, config.Cfg.EmbeddingModelType)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// lookup 先按查询原文查找，semantic 模式下再按查询向量查找相似的已缓存查询
+func (c *retrievalCacheStage) lookup(ctx context.Context, query string) cacheLookup {
+	if c.mode == CacheNone {
+		return cacheLookup{}
+	}
+	l := cacheLookup{Scope: c.cache.Scope(ctx, c.collection)}
+	if docs, ok := c.cache.GetRetrieval(ctx, l.Scope, query); ok {
+		l.Hit, l.Docs = true, docs
+		return l
+	}
+	if c.mode != CacheSemantic {
+		return l
+	}
+
+	vectors, err := c.embedder.EmbedStrings(ctx, []string{query})
+	if err != nil || len(vectors) == 0 {
+		// 向量化失败时按未命中处理，召回阶段会再次报告错误
+		log.Printf("语义缓存查询向量化失败: %v", err)
+		return l
+	}
+	l.Vector = vectors[0]
+	if docs, matched, ok := c.cache.GetSemantic(ctx, l.Scope, l.Vector, c.threshold); ok {
+		log.Printf("语义缓存命中: %q -> %q", query, matched)
+		l.Hit, l.Docs = true, docs
+	}
+	return l
+}
+
+// store 写入本次检索的最终结果，semantic 模式下同时记录查询向量
+func (c *retrievalCacheStage) store(ctx context.Context, l cacheLookup, query string, docs []*schema.Document) {
+	if c.mode == CacheNone {
+		return
+	}
+	if err := c.cache.SetRetrieval(ctx, l.Scope, query, docs); err != nil {
+		log.Printf("召回结果写入缓存失败: %v", err)
+		return
+	}
+	if l.Vector != nil {
+		c.cache.AddSemantic(l.Scope, query, l.Vector)
+	}
+}
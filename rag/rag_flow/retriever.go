@@ -2,138 +2,274 @@ package rag_flow
 
 import (
 	"context"
+	"fmt"
+	"go-agent/algorithm"
 	"go-agent/config"
+	"go-agent/rag/rag_tools/rerank"
 	"go-agent/rag/rag_tools/retriever"
 	"go-agent/tool"
-	"go-agent/tool/storage"
+	"log"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
+	einoretriever "github.com/cloudwego/eino/components/retriever"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
-var retrievalCache *storage.RetrievalCache
-
-func init() {
-	retrievalCache = storage.NewRetrievalCache()
-}
+var (
+	cachedRetrieverGraph  compose.Runnable[[]*schema.Message, []*schema.Document]
+	retrieverGraphOnce    sync.Once
+	retrieverGraphInitErr error
+)
 
 const (
 	MilvusRetriever = "MilvusRetriever"
 	ESRetriever     = "ESRetriever"
 	Reranker        = "Reranker"
+	Rerank          = "Rerank"
 	Trans_String    = "Trans_String"
+	CacheHit        = "CacheHit"
 )
 
+// InitRetrieverGraph 编译并缓存可独立调用的检索图，供 MCP 等外部调用方使用
+func InitRetrieverGraph(ctx context.Context) error {
+	retrieverGraphOnce.Do(func() {
+		g, err := BuildRetrieverGraph(ctx)
+		if err != nil {
+			retrieverGraphInitErr = err
+			return
+		}
+		cachedRetrieverGraph, retrieverGraphInitErr = g.Compile(ctx, compose.WithGraphName("RAGRetriever"))
+	})
+	return retrieverGraphInitErr
+}
+
+func GetRetrieverGraph() (compose.Runnable[[]*schema.Message, []*schema.Document], error) {
+	if cachedRetrieverGraph == nil {
+		return nil, fmt.Errorf("RetrieverGraph 未初始化，请先调用 InitRetrieverGraph")
+	}
+	return cachedRetrieverGraph, nil
+}
+
 // BuildRetrieverGraph 仅负责检索，输入 query，输出文档列表
 func BuildRetrieverGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*schema.Document], error) {
-	g := compose.NewGraph[[]*schema.Message, []*schema.Document]()
+	g := compose.NewGraph[[]*schema.Message, []*schema.Document](
+		compose.WithGenLocalState(func(ctx context.Context) *retrieveState {
+			return &retrieveState{}
+		}),
+	)
 
 	// 构建召回节点
 	milvus, err := retriever.GetRetriever(ctx, "milvus")
 	if err != nil {
 		return nil, err
 	}
-	es, err := retriever.GetRetriever(ctx, "es")
+	// 全文检索一路：Elasticsearch 或进程内 BM25 索引
+	es, err := retriever.GetRetriever(ctx, config.Cfg.LexicalDBType)
+	if err != nil {
+		return nil, err
+	}
+
+	fusion, err := fusionFromConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 第二阶段重排：融合后保留候选池大小的文档交给重排，重排后再截取 TopK
+	reranker, err := rerank.GetReranker(ctx, config.Cfg.RerankConf.Type)
 	if err != nil {
 		return nil, err
 	}
+	topk := retrieveTopK()
+	pool := topk
+	if config.Cfg.RerankConf.Type != "none" {
+		if n, err := strconv.Atoi(config.Cfg.RerankConf.Candidates); err == nil && n > topk {
+			pool = n
+		}
+	}
+	if pool > topk {
+		// 各路召回数量提高到候选池大小，否则重排只能在 TopK 个文档中挑选
+		milvus = &topKRetriever{Retriever: milvus, topK: pool}
+		es = &topKRetriever{Retriever: es, topK: pool}
+	}
 
-	_ = g.AddRetrieverNode(MilvusRetriever, milvus, compose.WithOutputKey("milvus_retriever"))
-	_ = g.AddRetrieverNode(ESRetriever, es, compose.WithOutputKey("es_retriever"))
+	// 输出键即融合时的来源名，用于配置各路权重与记录文档来源
+	_ = g.AddRetrieverNode(MilvusRetriever, milvus, compose.WithOutputKey("milvus"))
+	_ = g.AddRetrieverNode(ESRetriever, es, compose.WithOutputKey(config.Cfg.LexicalDBType))
 
-	// 转换节点带缓存检查
+	cache, err := cacheFromConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换节点带缓存检查，命中时跳过两路召回、融合与重排
 	_ = g.AddLambdaNode(Trans_String, compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) (string, error) {
 		query, err := tool.MsgsToQuery(ctx, input)
 		if err != nil {
 			return "", err
 		}
-
-		// 检查缓存
-		if docs, found := retrievalCache.GetRetrieval(ctx, query); found {
-			// 将缓存结果放入上下文，让后续节点直接使用
-			ctx = context.WithValue(ctx, "cached_docs", docs)
-		}
-
+		lookup := cache.lookup(ctx, query)
+		_ = compose.ProcessState[*retrieveState](ctx, func(ctx context.Context, state *retrieveState) error {
+			state.Query = query
+			state.Cache = lookup
+			return nil
+		})
 		return query, nil
 	}))
 
+	_ = g.AddLambdaNode(CacheHit, compose.InvokableLambda(func(ctx context.Context, query string) ([]*schema.Document, error) {
+		var docs []*schema.Document
+		_ = compose.ProcessState[*retrieveState](ctx, func(ctx context.Context, state *retrieveState) error {
+			docs = state.Cache.Docs
+			return nil
+		})
+		return docs, nil
+	}))
+
 	_ = g.AddLambdaNode(Reranker, compose.InvokableLambda(func(ctx context.Context, input map[string]any) ([]*schema.Document, error) {
-		// 先检查是否有缓存结果
-		if cached, ok := ctx.Value("cached_docs").([]*schema.Document); ok {
-			return cached, nil
+		// 按来源名排序，保证融合结果与来源顺序稳定
+		names := make([]string, 0, len(input))
+		for name := range input {
+			names = append(names, name)
 		}
-		// RRF 混合检索重排算法
-		// 具体讲解见algorithm/rrf.go
-		const k = 60
-		docScores := make(map[string]float64)
-		docMap := make(map[string]*schema.Document)
-
-		for _, val := range input {
-			docs, ok := val.([]*schema.Document)
+		sort.Strings(names)
+		sources := make([]algorithm.FusionSource, 0, len(names))
+		for _, name := range names {
+			docs, ok := input[name].([]*schema.Document)
 			if !ok {
 				continue
 			}
+			sources = append(sources, algorithm.FusionSource{Name: name, Weight: fusion.weight(name), Docs: docs})
+		}
 
-			for rank, doc := range docs {
-				id := doc.ID
-				if id == "" {
-					continue
-				}
-
-				// 标准 RRF 公式: 1 / (k + rank)
-				score := 1.0 / float64(k+rank+1)
-				docScores[id] += score
-
-				// 如果文档在多路中重复出现，保留分数较高的原始对象
-				if oldDoc, exists := docMap[id]; !exists || doc.Score() > oldDoc.Score() {
-					docMap[id] = doc
-				}
-			}
+		// 融合策略见 algorithm/fusion.go，来源与各路排名写入 MetaData["_provenance"]
+		results, err := algorithm.Fuse(fusion.Method, fusion.K, sources)
+		if err != nil {
+			return nil, err
 		}
 
-		// 将结果汇总并排序
-		results := make([]*schema.Document, 0, len(docMap))
-		for id, score := range docScores {
-			doc := docMap[id]
-			doc.WithScore(score)
-			results = append(results, doc)
+		if len(results) > pool {
+			results = results[:pool]
 		}
+		return results, nil
+	}))
 
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Score() > results[j].Score()
+	_ = g.AddLambdaNode(Rerank, compose.InvokableLambda(func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		var query string
+		var lookup cacheLookup
+		_ = compose.ProcessState[*retrieveState](ctx, func(ctx context.Context, state *retrieveState) error {
+			query = state.Query
+			lookup = state.Cache
+			return nil
 		})
-
-		topk := 10
-		if config.Cfg != nil && config.Cfg.MilvusConf.TopK != "" {
-			if val, err := strconv.Atoi(config.Cfg.MilvusConf.TopK); err == nil {
-				topk = val
+		reranked, err := reranker.Rerank(ctx, query, docs, topk)
+		if err != nil {
+			// 重排失败时退回融合顺序，不影响问答；该结果不写缓存，避免在缓存有效期内一直使用未重排的结果
+			log.Printf("重排失败，使用融合结果: %v", err)
+			if len(docs) > topk {
+				docs = docs[:topk]
 			}
+			return docs, nil
 		}
 
-		if len(results) > topk {
-			results = results[:topk]
-		}
-
-		// 异步写入缓存
-		go func(ctx context.Context, results []*schema.Document) {
-			// 从上下文获取query
-			if query, ok := ctx.Value("query").(string); ok {
-				retrievalCache.SetRetrieval(context.Background(), query, results)
-			}
-		}(ctx, results)
-
-		return results, nil
+		// 缓存最终结果，写入在后台进行，不影响本次返回
+		go cache.store(context.WithoutCancel(ctx), lookup, query, reranked)
+		return reranked, nil
 	}))
 
 	// 构建节点指向
 	_ = g.AddEdge(compose.START, Trans_String)
-	_ = g.AddEdge(Trans_String, MilvusRetriever)
-	_ = g.AddEdge(Trans_String, ESRetriever)
+	_ = g.AddBranch(Trans_String, compose.NewGraphMultiBranch(func(ctx context.Context, query string) (map[string]bool, error) {
+		hit := false
+		_ = compose.ProcessState[*retrieveState](ctx, func(ctx context.Context, state *retrieveState) error {
+			hit = state.Cache.Hit
+			return nil
+		})
+		if hit {
+			return map[string]bool{CacheHit: true}, nil
+		}
+		return map[string]bool{MilvusRetriever: true, ESRetriever: true}, nil
+	}, map[string]bool{CacheHit: true, MilvusRetriever: true, ESRetriever: true}))
 	_ = g.AddEdge(MilvusRetriever, Reranker)
 	_ = g.AddEdge(ESRetriever, Reranker)
-	_ = g.AddEdge(Reranker, compose.END)
+	_ = g.AddEdge(Reranker, Rerank)
+	_ = g.AddEdge(Rerank, compose.END)
+	_ = g.AddEdge(CacheHit, compose.END)
 
 	return g, nil
 }
+
+// retrieveState 检索图的局部状态，保存转换后的查询与缓存查找结果供后续节点使用
+type retrieveState struct {
+	Query string
+	Cache cacheLookup
+}
+
+// retrieveTopK 检索图最终返回的文档数
+func retrieveTopK() int {
+	topk := 10
+	if config.Cfg != nil && config.Cfg.MilvusConf.TopK != "" {
+		if val, err := strconv.Atoi(config.Cfg.MilvusConf.TopK); err == nil && val > 0 {
+			topk = val
+		}
+	}
+	return topk
+}
+
+// topKRetriever 固定单路召回的文档数，调用方显式传入的 TopK 仍然优先
+type topKRetriever struct {
+	einoretriever.Retriever
+	topK int
+}
+
+func (r *topKRetriever) Retrieve(ctx context.Context, query string, opts ...einoretriever.Option) ([]*schema.Document, error) {
+	return r.Retriever.Retrieve(ctx, query, append([]einoretriever.Option{einoretriever.WithTopK(r.topK)}, opts...)...)
+}
+
+// FusionOptions 多路召回的融合策略与各路权重，不同集合的检索图可以使用不同的配置
+type FusionOptions struct {
+	Method  algorithm.FusionMethod
+	K       float64
+	Weights map[string]float64 // 来源名 -> 权重，未列出的来源权重为 1
+}
+
+func (o FusionOptions) weight(source string) float64 {
+	if w, ok := o.Weights[source]; ok {
+		return w
+	}
+	return 1
+}
+
+// fusionFromConfig 读取 RETRIEVAL_FUSION* 配置
+func fusionFromConfig() (FusionOptions, error) {
+	conf := config.Cfg.FusionConf
+	opts := FusionOptions{Method: algorithm.FusionMethod(conf.Method), K: algorithm.DefaultRRFK, Weights: map[string]float64{}}
+	switch opts.Method {
+	case algorithm.FusionRRF, algorithm.FusionMinMax, algorithm.FusionZScore, algorithm.FusionDBSF:
+	default:
+		return opts, fmt.Errorf("不支持的融合策略: %s，可选 rrf / minmax / zscore / dbsf", conf.Method)
+	}
+	if conf.K != "" {
+		k, err := strconv.ParseFloat(conf.K, 64)
+		if err != nil || k <= 0 {
+			return opts, fmt.Errorf("RETRIEVAL_FUSION_K 必须为正数: %s", conf.K)
+		}
+		opts.K = k
+	}
+	for _, item := range strings.Split(conf.Weights, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(item, ":")
+		w, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if !ok || err != nil || w < 0 {
+			return opts, fmt.Errorf("RETRIEVAL_FUSION_WEIGHTS 格式错误: %s，应为 来源:权重，如 milvus:1,es:0.5", item)
+		}
+		opts.Weights[strings.TrimSpace(name)] = w
+	}
+	return opts, nil
+}
@@ -0,0 +1,199 @@
+package rag_flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/config"
+	"go-agent/rag/rag_tools/db"
+	"go-agent/rag/rag_tools/dbschema"
+	"go-agent/rag/rag_tools/indexer"
+	"go-agent/rag/rag_tools/retriever"
+	"go-agent/tool"
+	"go-agent/tool/sql_guard"
+	"go-agent/tool/storage"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+const SchemaRetriever = "SchemaRetriever"
+
+var (
+	schemaIndexer indexer.Upserter
+	schemaSyncMu  sync.Mutex
+)
+
+// SchemaSyncReport 一次表结构同步的结果
+type SchemaSyncReport struct {
+	Tables    int      `json:"tables"`    // 本次读取到的表数量
+	Indexed   []string `json:"indexed"`   // 新增或结构变化后重新索引的表
+	Deleted   []string `json:"deleted"`   // 已不存在或被禁止访问、从知识库删除的表
+	Unchanged int      `json:"unchanged"` // 结构未变化的表数量
+	Duration  string   `json:"duration"`
+}
+
+// InitSchemaIndex 创建表结构集合的索引器（集合不存在时自动创建），SCHEMA_SYNC_ON_START=true 时在后台同步一次
+func InitSchemaIndex(ctx context.Context) error {
+	idx, err := indexer.GetIndexer(ctx, "schema")
+	if err != nil {
+		return err
+	}
+	u, ok := idx.(indexer.Upserter)
+	if !ok {
+		return fmt.Errorf("索引器 schema 不支持按主键覆盖写入")
+	}
+	schemaIndexer = u
+
+	if config.Cfg.SchemaConf.SyncOnStart == "true" {
+		go func() {
+			report, err := SyncSchema(context.Background())
+			if err != nil {
+				log.Printf("表结构同步失败: %v", err)
+				return
+			}
+			log.Printf("表结构同步完成: 表=%d, 重新索引=%d, 删除=%d, 未变化=%d",
+				report.Tables, len(report.Indexed), len(report.Deleted), report.Unchanged)
+		}()
+	}
+	return nil
+}
+
+// SyncSchema 读取 MySQL 表结构并增量同步到表结构集合：结构变化的表重新索引，不存在的表删除。可重复执行
+func SyncSchema(ctx context.Context) (*SchemaSyncReport, error) {
+	if schemaIndexer == nil {
+		return nil, fmt.Errorf("表结构索引未初始化，请先调用 InitSchemaIndex")
+	}
+	schemaSyncMu.Lock()
+	defer schemaSyncMu.Unlock()
+	start := time.Now()
+
+	conn, err := storage.NewMySQL(ctx, config.Cfg.MySQLConf)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sampleValues, _ := strconv.Atoi(config.Cfg.SchemaConf.SampleRows)
+	tables, err := dbschema.Introspect(ctx, conn, sql_guard.PolicyFromConfig(), dbschema.SampleOptions{
+		Values:      sampleValues,
+		DenyColumns: strings.Split(config.Cfg.SchemaConf.SampleDenyColumns, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	collection := config.Cfg.SchemaConf.Collection
+	indexed, err := indexedHashes(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SchemaSyncReport{Tables: len(tables), Indexed: []string{}, Deleted: []string{}}
+	var docs []*schema.Document
+	var removedIDs []string
+	current := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		doc := t.Document()
+		current[doc.ID] = true
+		hash, exists := indexed[doc.ID]
+		if exists && hash == doc.MetaData["hash"] {
+			report.Unchanged++
+			continue
+		}
+		docs = append(docs, doc)
+		report.Indexed = append(report.Indexed, t.Name)
+	}
+	for id := range indexed {
+		if !current[id] {
+			removedIDs = append(removedIDs, id)
+			report.Deleted = append(report.Deleted, strings.TrimPrefix(id, dbschema.DocIDPrefix))
+		}
+	}
+
+	// 新增与结构变化的表按主键覆盖写入，写入失败时旧文档保持不变；只删除已不存在的表
+	if len(docs) > 0 {
+		if _, err := schemaIndexer.Upsert(ctx, docs); err != nil {
+			return nil, fmt.Errorf("索引表结构失败: %w", err)
+		}
+	}
+	if len(removedIDs) > 0 {
+		if err := db.Milvus.Delete(ctx, collection, "", idInExpr(removedIDs)); err != nil {
+			return nil, fmt.Errorf("删除已不存在的表结构文档失败: %w", err)
+		}
+	}
+
+	report.Duration = time.Since(start).String()
+	return report, nil
+}
+
+// indexedHashes 返回集合中已有的表结构文档 ID 与内容哈希
+func indexedHashes(ctx context.Context, collection string) (map[string]string, error) {
+	if err := db.Milvus.LoadCollection(ctx, collection, false); err != nil {
+		return nil, fmt.Errorf("加载表结构集合失败: %w", err)
+	}
+	rs, err := db.Milvus.Query(ctx, collection, nil, fmt.Sprintf("id like %q", dbschema.DocIDPrefix+"%"), []string{"id", "metadata"})
+	if err != nil {
+		return nil, fmt.Errorf("查询已索引的表结构失败: %w", err)
+	}
+
+	hashes := make(map[string]string)
+	ids, meta := rs.GetColumn("id"), rs.GetColumn("metadata")
+	if ids == nil {
+		return hashes, nil
+	}
+	for i := 0; i < ids.Len(); i++ {
+		id, err := ids.GetAsString(i)
+		if err != nil {
+			return nil, err
+		}
+		hashes[id] = ""
+		if col, ok := meta.(*entity.ColumnJSONBytes); ok {
+			if raw, err := col.ValueByIdx(i); err == nil {
+				hashes[id] = metadataHash(raw)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// BuildSchemaRetrieverGraph 只检索表结构集合，输入 query，输出表结构文档
+func BuildSchemaRetrieverGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*schema.Document], error) {
+	g := compose.NewGraph[[]*schema.Message, []*schema.Document]()
+
+	r, err := retriever.GetRetriever(ctx, "schema")
+	if err != nil {
+		return nil, err
+	}
+
+	_ = g.AddLambdaNode(Trans_String, compose.InvokableLambda(tool.MsgsToQuery))
+	_ = g.AddRetrieverNode(SchemaRetriever, r)
+	_ = g.AddEdge(compose.START, Trans_String)
+	_ = g.AddEdge(Trans_String, SchemaRetriever)
+	_ = g.AddEdge(SchemaRetriever, compose.END)
+
+	return g, nil
+}
+
+func idInExpr(ids []string) string {
+	quoted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	return "id in [" + strings.Join(quoted, ", ") + "]"
+}
+
+func metadataHash(raw []byte) string {
+	var meta map[string]any
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return ""
+	}
+	hash, _ := meta["hash"].(string)
+	return hash
+}
@@ -0,0 +1,253 @@
+package rag_flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/config"
+	"go-agent/rag/rag_tools/db"
+	"go-agent/rag/rag_tools/indexer"
+	"go-agent/rag/rag_tools/retriever"
+	"go-agent/rag/rag_tools/sqlknowledge"
+	"go-agent/tool"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	milvusret "github.com/cloudwego/eino-ext/components/retriever/milvus"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+const SQLKnowledgeRetriever = "SQLKnowledgeRetriever"
+
+var ErrKnowledgeNotFound = errors.New("条目不存在")
+
+var (
+	knowledgeIndexer indexer.Upserter
+	knowledgeMu      sync.Mutex
+)
+
+// InitSQLKnowledge 创建术语与示例集合的索引器（集合不存在时自动创建）
+func InitSQLKnowledge(ctx context.Context) error {
+	idx, err := indexer.GetIndexer(ctx, "sql_knowledge")
+	if err != nil {
+		return err
+	}
+	u, ok := idx.(indexer.Upserter)
+	if !ok {
+		return fmt.Errorf("索引器 sql_knowledge 不支持按主键覆盖写入")
+	}
+	knowledgeIndexer = u
+	return nil
+}
+
+// KnowledgeFilter 条目列表的过滤条件，为空的字段不参与过滤
+type KnowledgeFilter struct {
+	Kind   sqlknowledge.Kind
+	Status sqlknowledge.Status
+}
+
+// ListSQLKnowledge 按更新时间从新到旧列出术语与示例
+func ListSQLKnowledge(ctx context.Context, f KnowledgeFilter) ([]*sqlknowledge.Entry, error) {
+	expr := []string{fmt.Sprintf("metadata[\"source\"] == %q", sqlknowledge.SourceMeta)}
+	if f.Kind != "" {
+		expr = append(expr, fmt.Sprintf("metadata[\"kind\"] == %q", f.Kind))
+	}
+	if f.Status != "" {
+		expr = append(expr, fmt.Sprintf("metadata[\"status\"] == %q", f.Status))
+	}
+	entries, err := queryKnowledge(ctx, strings.Join(expr, " and "))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UpdatedAt.After(entries[j].UpdatedAt) })
+	return entries, nil
+}
+
+// GetSQLKnowledge 查询单个条目，不存在时返回 ErrKnowledgeNotFound
+func GetSQLKnowledge(ctx context.Context, id string) (*sqlknowledge.Entry, error) {
+	entries, err := queryKnowledge(ctx, "id == "+strconv.Quote(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrKnowledgeNotFound
+	}
+	return entries[0], nil
+}
+
+// CreateSQLKnowledge 校验并写入新条目，未指定状态时视为已确认
+func CreateSQLKnowledge(ctx context.Context, e *sqlknowledge.Entry) (*sqlknowledge.Entry, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	now := time.Now()
+	e.ID = sqlknowledge.NewID(e.Kind)
+	e.CreatedAt, e.UpdatedAt = now, now
+	if err := storeKnowledge(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UpdateSQLKnowledge 修改条目并重新索引，条目的 ID、类型与创建信息不可修改
+func UpdateSQLKnowledge(ctx context.Context, id string, update func(e *sqlknowledge.Entry) error) (*sqlknowledge.Entry, error) {
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	old, err := GetSQLKnowledge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e := *old
+	if err := update(&e); err != nil {
+		return nil, err
+	}
+	e.ID, e.Kind, e.CreatedAt, e.CreatedBy = old.ID, old.Kind, old.CreatedAt, old.CreatedBy
+	e.UpdatedAt = time.Now()
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 按主键 upsert 覆盖旧文档，写入失败时旧版本保持不变
+	if err := upsertKnowledge(ctx, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// DeleteSQLKnowledge 删除条目，不存在时返回 ErrKnowledgeNotFound
+func DeleteSQLKnowledge(ctx context.Context, id string) error {
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	if _, err := GetSQLKnowledge(ctx, id); err != nil {
+		return err
+	}
+	return deleteKnowledge(ctx, id)
+}
+
+// ProposeSQLExample 将执行成功的 SQL 提议为待确认的示例，同一条 SQL 已存在时不重复提议。
+// 返回 nil 表示已存在
+func ProposeSQLExample(ctx context.Context, question, sql string, tables []string, sessionID string) (*sqlknowledge.Entry, error) {
+	now := time.Now()
+	e := &sqlknowledge.Entry{
+		ID:        sqlknowledge.ProposalID(sql),
+		Kind:      sqlknowledge.KindExample,
+		Status:    sqlknowledge.StatusProposed,
+		Question:  question,
+		SQL:       sql,
+		Tables:    tables,
+		SessionID: sessionID,
+		CreatedBy: "auto",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	knowledgeMu.Lock()
+	defer knowledgeMu.Unlock()
+
+	if _, err := GetSQLKnowledge(ctx, e.ID); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, ErrKnowledgeNotFound) {
+		return nil, err
+	}
+	if err := storeKnowledge(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// BuildSQLKnowledgeRetrieverGraph 只检索已确认的术语与示例，输入 query，输出条目文档
+func BuildSQLKnowledgeRetrieverGraph(ctx context.Context) (*compose.Graph[[]*schema.Message, []*schema.Document], error) {
+	g := compose.NewGraph[[]*schema.Message, []*schema.Document]()
+
+	r, err := retriever.GetRetriever(ctx, "sql_knowledge")
+	if err != nil {
+		return nil, err
+	}
+	filter := fmt.Sprintf("metadata[\"status\"] == %q", sqlknowledge.StatusVerified)
+
+	_ = g.AddLambdaNode(Trans_String, compose.InvokableLambda(tool.MsgsToQuery))
+	_ = g.AddLambdaNode(SQLKnowledgeRetriever, compose.InvokableLambda(func(ctx context.Context, query string) ([]*schema.Document, error) {
+		return r.Retrieve(ctx, query, milvusret.WithFilter(filter))
+	}))
+	_ = g.AddEdge(compose.START, Trans_String)
+	_ = g.AddEdge(Trans_String, SQLKnowledgeRetriever)
+	_ = g.AddEdge(SQLKnowledgeRetriever, compose.END)
+
+	return g, nil
+}
+
+func storeKnowledge(ctx context.Context, e *sqlknowledge.Entry) error {
+	if knowledgeIndexer == nil {
+		return fmt.Errorf("术语与示例知识库未初始化，请先调用 InitSQLKnowledge")
+	}
+	if _, err := knowledgeIndexer.Store(ctx, []*schema.Document{e.Document()}); err != nil {
+		return fmt.Errorf("索引条目失败: %w", err)
+	}
+	return nil
+}
+
+// upsertKnowledge 按主键覆盖写入，向量化或写入失败时不会删除任何数据
+func upsertKnowledge(ctx context.Context, e *sqlknowledge.Entry) error {
+	if knowledgeIndexer == nil {
+		return fmt.Errorf("术语与示例知识库未初始化，请先调用 InitSQLKnowledge")
+	}
+	if _, err := knowledgeIndexer.Upsert(ctx, []*schema.Document{e.Document()}); err != nil {
+		return fmt.Errorf("更新条目失败: %w", err)
+	}
+	return nil
+}
+
+func deleteKnowledge(ctx context.Context, id string) error {
+	if err := db.Milvus.Delete(ctx, config.Cfg.SQLKnowledgeConf.Collection, "", idInExpr([]string{id})); err != nil {
+		return fmt.Errorf("删除条目失败: %w", err)
+	}
+	return nil
+}
+
+// queryKnowledge 以强一致性查询条目，保证增删改之后立即可见
+func queryKnowledge(ctx context.Context, expr string) ([]*sqlknowledge.Entry, error) {
+	collection := config.Cfg.SQLKnowledgeConf.Collection
+	if err := db.Milvus.LoadCollection(ctx, collection, false); err != nil {
+		return nil, fmt.Errorf("加载术语与示例集合失败: %w", err)
+	}
+	rs, err := db.Milvus.Query(ctx, collection, nil, expr, []string{"id", "metadata"},
+		client.WithSearchQueryConsistencyLevel(entity.ClStrong))
+	if err != nil {
+		return nil, fmt.Errorf("查询术语与示例失败: %w", err)
+	}
+
+	col, ok := rs.GetColumn("metadata").(*entity.ColumnJSONBytes)
+	if !ok {
+		return []*sqlknowledge.Entry{}, nil
+	}
+	entries := make([]*sqlknowledge.Entry, 0, col.Len())
+	for i := 0; i < col.Len(); i++ {
+		raw, err := col.ValueByIdx(i)
+		if err != nil {
+			return nil, err
+		}
+		var meta struct {
+			Entry *sqlknowledge.Entry `json:"entry"`
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil || meta.Entry == nil {
+			continue
+		}
+		entries = append(entries, meta.Entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/algorithm"
+	"go-agent/config"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+var (
+	bm25Index *BM25Index
+	bm25Once  sync.Once
+	bm25Err   error
+)
+
+// GetBM25 返回进程内共享的全文索引，bm25 索引器与检索器共用。首次调用时按 BM25_INDEX_PATH 加载
+func GetBM25() (*BM25Index, error) {
+	bm25Once.Do(func() {
+		bm25Index, bm25Err = NewBM25Index(config.Cfg.BM25Conf.IndexPath)
+	})
+	return bm25Index, bm25Err
+}
+
+// scorer 即 algorithm.NewBM25 返回的评分器
+type scorer interface {
+	Score(query, doc []string) float64
+}
+
+type bm25Doc struct {
+	ID       string         `json:"id"`
+	Content  string         `json:"content"`
+	MetaData map[string]any `json:"metadata,omitempty"`
+	tokens   []string
+}
+
+// BM25Index 进程内的倒排索引，供没有 Elasticsearch 的部署与本地调试做全文检索。
+// path 不为空时每次写入后整体落盘，启动时从文件加载
+type BM25Index struct {
+	mu       sync.RWMutex
+	path     string
+	docs     map[string]*bm25Doc
+	postings map[string]map[string]struct{} // 词项 -> 包含该词项的文档 ID
+	scorer   scorer                         // 写入后置空，检索时按当前语料重建 IDF 与平均长度
+}
+
+// NewBM25Index 创建索引，path 为空时只保存在内存中
+func NewBM25Index(path string) (*BM25Index, error) {
+	idx := &BM25Index{
+		path:     path,
+		docs:     make(map[string]*bm25Doc),
+		postings: make(map[string]map[string]struct{}),
+	}
+	if path == "" {
+		return idx, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 BM25 索引文件失败: %w", err)
+	}
+	var docs []*bm25Doc
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, fmt.Errorf("解析 BM25 索引文件失败: %w", err)
+	}
+	for _, d := range docs {
+		idx.add(d)
+	}
+	log.Printf("BM25 索引已加载: %s，共 %d 个分块", path, len(docs))
+	return idx, nil
+}
+
+// Upsert 写入文档，ID 相同的文档会被替换，返回写入的文档 ID。
+// 任一文档不合法时不写入任何文档，落盘失败时恢复写入前的内容
+func (idx *BM25Index) Upsert(docs []*schema.Document) ([]string, error) {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return nil, fmt.Errorf("BM25 索引的文档缺少 ID")
+		}
+		ids = append(ids, doc.ID)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	prev := idx.snapshot(ids)
+	for _, doc := range docs {
+		idx.remove(doc.ID)
+		idx.add(&bm25Doc{ID: doc.ID, Content: doc.Content, MetaData: doc.MetaData})
+	}
+	if err := idx.persist(); err != nil {
+		idx.restore(ids, prev)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Delete 删除文档，落盘失败时恢复删除前的内容
+func (idx *BM25Index) Delete(ids ...string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	prev := idx.snapshot(ids)
+	for _, id := range ids {
+		idx.remove(id)
+	}
+	if err := idx.persist(); err != nil {
+		idx.restore(ids, prev)
+		return err
+	}
+	return nil
+}
+
+// Search 返回与查询得分最高的 topK 个文档，只对包含查询词项的文档评分
+func (idx *BM25Index) Search(query string, topK int, scoreThreshold *float64) []*schema.Document {
+	terms := algorithm.Tokenize(query)
+	if len(terms) == 0 || topK <= 0 {
+		return nil
+	}
+
+	idx.mu.Lock()
+	if idx.scorer == nil && len(idx.docs) > 0 {
+		corpus := make([][]string, 0, len(idx.docs))
+		for _, d := range idx.docs {
+			corpus = append(corpus, d.tokens)
+		}
+		idx.scorer = algorithm.NewBM25(corpus)
+	}
+	bm := idx.scorer
+	candidates := make(map[string]*bm25Doc)
+	for _, t := range terms {
+		for id := range idx.postings[t] {
+			candidates[id] = idx.docs[id]
+		}
+	}
+	idx.mu.Unlock()
+
+	results := make([]*schema.Document, 0, len(candidates))
+	for _, d := range candidates {
+		score := bm.Score(terms, d.tokens)
+		if scoreThreshold != nil && score < *scoreThreshold {
+			continue
+		}
+		meta := make(map[string]any, len(d.MetaData))
+		for k, v := range d.MetaData {
+			meta[k] = v
+		}
+		doc := &schema.Document{ID: d.ID, Content: d.Content, MetaData: meta}
+		results = append(results, doc.WithScore(score))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score() != results[j].Score() {
+			return results[i].Score() > results[j].Score()
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// Len 返回索引中的文档数
+func (idx *BM25Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+func (idx *BM25Index) add(d *bm25Doc) {
+	d.tokens = algorithm.Tokenize(d.Content)
+	idx.docs[d.ID] = d
+	for _, t := range d.tokens {
+		ids, ok := idx.postings[t]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.postings[t] = ids
+		}
+		ids[d.ID] = struct{}{}
+	}
+	idx.scorer = nil
+}
+
+func (idx *BM25Index) remove(id string) {
+	d, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, t := range d.tokens {
+		if ids, ok := idx.postings[t]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+	}
+	delete(idx.docs, id)
+	idx.scorer = nil
+}
+
+// snapshot 记录 ids 修改前的文档，调用方需持有写锁
+func (idx *BM25Index) snapshot(ids []string) map[string]*bm25Doc {
+	prev := make(map[string]*bm25Doc, len(ids))
+	for _, id := range ids {
+		if d, ok := idx.docs[id]; ok {
+			prev[id] = d
+		}
+	}
+	return prev
+}
+
+// restore 将 ids 恢复为 snapshot 记录的内容，修改前不存在的文档被删除
+func (idx *BM25Index) restore(ids []string, prev map[string]*bm25Doc) {
+	for _, id := range ids {
+		idx.remove(id)
+		if d, ok := prev[id]; ok {
+			idx.add(d)
+		}
+	}
+}
+
+// persist 将全部文档写入临时文件后替换，避免写入中途退出损坏索引文件
+func (idx *BM25Index) persist() error {
+	if idx.path == "" {
+		return nil
+	}
+	docs := make([]*bm25Doc, 0, len(idx.docs))
+	for _, d := range idx.docs {
+		docs = append(docs, d)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return fmt.Errorf("序列化 BM25 索引失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return fmt.Errorf("创建 BM25 索引目录失败: %w", err)
+	}
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("写入 BM25 索引文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return fmt.Errorf("写入 BM25 索引文件失败: %w", err)
+	}
+	return nil
+}
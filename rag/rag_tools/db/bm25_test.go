@@ -0,0 +1,167 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func testDocs() []*schema.Document {
+	return []*schema.Document{
+		{ID: "a", Content: "差旅报销流程：提交发票后由部门经理审批", MetaData: map[string]any{"source": "hr"}},
+		{ID: "b", Content: "年假申请流程"},
+		{ID: "c", Content: "报销 报销 报销 常见问题"},
+	}
+}
+
+func ids(docs []*schema.Document) []string {
+	out := make([]string, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.ID)
+	}
+	return out
+}
+
+func TestBM25Search(t *testing.T) {
+	idx, err := NewBM25Index("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Upsert(testDocs()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		topK  int
+		want  []string
+	}{
+		{"词频高的文档排在前面", "报销", 10, []string{"c", "a"}},
+		{"多个词项", "报销流程", 10, []string{"a", "c", "b"}},
+		{"topK 截断，短文档优先", "流程", 1, []string{"b"}},
+		{"没有匹配", "工资", 10, []string{}},
+		{"查询没有词项", "，。", 10, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(idx.Search(tt.query, tt.topK, nil))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// 结果是副本，修改不影响索引
+	res := idx.Search("差旅", 1, nil)
+	res[0].MetaData["source"] = "changed"
+	if again := idx.Search("差旅", 1, nil); again[0].MetaData["source"] != "hr" {
+		t.Error("修改检索结果影响了索引")
+	}
+}
+
+func TestBM25Upsert(t *testing.T) {
+	idx, _ := NewBM25Index("")
+	if _, err := idx.Upsert(testDocs()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 相同 ID 替换旧内容，旧词项不再命中
+	if _, err := idx.Upsert([]*schema.Document{{ID: "b", Content: "加班调休规则"}}); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 3 {
+		t.Errorf("Len = %d, want 3", idx.Len())
+	}
+	if got := ids(idx.Search("年假", 10, nil)); len(got) != 0 {
+		t.Errorf("替换后仍命中旧内容: %v", got)
+	}
+	if got := ids(idx.Search("调休", 10, nil)); len(got) != 1 || got[0] != "b" {
+		t.Errorf("Search(调休) = %v", got)
+	}
+
+	// 任一文档缺少 ID 时整批不写入
+	if _, err := idx.Upsert([]*schema.Document{{ID: "d", Content: "新文档"}, {Content: "缺少 ID"}}); err == nil {
+		t.Error("缺少 ID 时应返回错误")
+	}
+	if idx.Len() != 3 {
+		t.Errorf("写入失败后 Len = %d, want 3", idx.Len())
+	}
+
+	if err := idx.Delete("a", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(idx.Search("差旅", 10, nil)); len(got) != 0 {
+		t.Errorf("删除后仍命中: %v", got)
+	}
+}
+
+func TestBM25Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bm25", "index.json")
+	idx, err := NewBM25Index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Upsert(testDocs()); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 从文件重新加载后内容与排序一致
+	loaded, err := NewBM25Index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 2 {
+		t.Errorf("Len = %d, want 2", loaded.Len())
+	}
+	got := loaded.Search("报销", 10, nil)
+	if len(got) != 2 || got[0].ID != "c" || got[1].MetaData["source"] != "hr" {
+		t.Errorf("Search = %v", got)
+	}
+}
+
+func TestBM25PersistFailureRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+	idx, err := NewBM25Index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Upsert(testDocs()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 临时文件路径被目录占用，落盘失败
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Upsert([]*schema.Document{{ID: "a", Content: "已修改"}, {ID: "d", Content: "新文档"}}); err == nil {
+		t.Fatal("落盘失败时应返回错误")
+	}
+	if idx.Len() != 3 {
+		t.Errorf("Len = %d, want 3", idx.Len())
+	}
+	if got := ids(idx.Search("差旅", 10, nil)); len(got) != 1 || got[0] != "a" {
+		t.Errorf("回滚后 Search(差旅) = %v", got)
+	}
+	if got := idx.Search("新文档", 10, nil); len(got) != 0 {
+		t.Errorf("回滚后仍有新文档: %v", ids(got))
+	}
+
+	if err := idx.Delete("a"); err == nil {
+		t.Fatal("落盘失败时应返回错误")
+	}
+	if got := ids(idx.Search("差旅", 10, nil)); len(got) != 1 {
+		t.Errorf("删除回滚后 Search(差旅) = %v", got)
+	}
+}
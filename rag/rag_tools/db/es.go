@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"go-agent/config"
+	"log"
 
 	"github.com/elastic/go-elasticsearch/v8"
 )
@@ -35,10 +36,19 @@ func NewES() (*elasticsearch.Client, error) {
 	if res.StatusCode == 404 {
 		// 索引不存在，开始创建并定义 Mapping
 		// 定义 Mapping 结构
+		content := map[string]interface{}{"type": "text"}
+		indexAnalyzer, searchAnalyzer, err := contentAnalyzer(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		if indexAnalyzer != "" {
+			content["analyzer"] = indexAnalyzer
+			content["search_analyzer"] = searchAnalyzer
+		}
 		mapping := map[string]interface{}{
 			"mappings": map[string]interface{}{
 				"properties": map[string]interface{}{
-					"content":  map[string]interface{}{"type": "text"},
+					"content":  content,
 					"metadata": map[string]interface{}{"type": "object"},
 					"content_vector": map[string]interface{}{
 						"type":       "dense_vector",
@@ -63,7 +73,107 @@ func NewES() (*elasticsearch.Client, error) {
 			return nil, fmt.Errorf("创建 ES 索引返回错误: %s", createRes.String())
 		}
 		fmt.Printf("ES 索引 [%s] 初始化成功\n", indexName)
+	} else if current := mappedAnalyzer(ctx, client, indexName); current == "" {
+		// 已有索引的分词器无法修改，只提示重建
+		if a, _, err := contentAnalyzer(ctx, client); err == nil && a != "" {
+			log.Printf("ES 索引 [%s] 的 content 字段使用默认分词器，中文全文检索效果较差，可删除索引后重新入库以使用 %s", indexName, a)
+		}
 	}
 
 	return client, nil
 }
+
+// 中文分词插件及其索引、查询时使用的分词器
+var analyzerPlugins = []struct {
+	name, plugin, index, search string
+}{
+	{"ik", "analysis-ik", "ik_max_word", "ik_smart"},
+	{"smartcn", "analysis-smartcn", "smartcn", "smartcn"},
+}
+
+// contentAnalyzer 按 ES_ANALYZER 选择 content 字段的分词器，返回空字符串表示使用 ES 默认的 standard。
+// auto 时优先使用已安装的 ik，其次 smartcn；显式指定的插件未安装时返回错误
+func contentAnalyzer(ctx context.Context, client *elasticsearch.Client) (index, search string, err error) {
+	want := config.Cfg.ESConf.Analyzer
+	if want == "" || want == "standard" {
+		return "", "", nil
+	}
+
+	installed, err := installedPlugins(ctx, client)
+	if err != nil {
+		return "", "", err
+	}
+	for _, a := range analyzerPlugins {
+		if want != "auto" && want != a.name {
+			continue
+		}
+		if installed[a.plugin] {
+			return a.index, a.search, nil
+		}
+		if want == a.name {
+			return "", "", fmt.Errorf("ES 未安装 %s 插件，无法使用 %s 分词器", a.plugin, a.name)
+		}
+	}
+	if want != "auto" {
+		return "", "", fmt.Errorf("不支持的 ES 分词器: %s，可选 auto / ik / smartcn / standard", want)
+	}
+	log.Println("ES 未安装 ik 或 smartcn 分词插件，content 字段使用默认分词器")
+	return "", "", nil
+}
+
+// installedPlugins 返回集群中已安装的插件
+func installedPlugins(ctx context.Context, client *elasticsearch.Client) (map[string]bool, error) {
+	res, err := client.Cat.Plugins(
+		client.Cat.Plugins.WithContext(ctx),
+		client.Cat.Plugins.WithFormat("json"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询 ES 插件失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("查询 ES 插件返回错误: %s", res.String())
+	}
+
+	var plugins []struct {
+		Component string `json:"component"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&plugins); err != nil {
+		return nil, fmt.Errorf("解析 ES 插件列表失败: %w", err)
+	}
+	installed := make(map[string]bool, len(plugins))
+	for _, p := range plugins {
+		installed[p.Component] = true
+	}
+	return installed, nil
+}
+
+// mappedAnalyzer 返回已有索引中 content 字段的分词器，未设置或查询失败时返回空字符串
+func mappedAnalyzer(ctx context.Context, client *elasticsearch.Client, indexName string) string {
+	res, err := client.Indices.GetMapping(
+		client.Indices.GetMapping.WithContext(ctx),
+		client.Indices.GetMapping.WithIndex(indexName),
+	)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return ""
+	}
+
+	var mappings map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Analyzer string `json:"analyzer"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&mappings); err != nil {
+		return ""
+	}
+	for _, m := range mappings {
+		return m.Mappings.Properties["content"].Analyzer
+	}
+	return ""
+}
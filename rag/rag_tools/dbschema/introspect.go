@@ -0,0 +1,301 @@
+package dbschema
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"go-agent/tool/sql_guard"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// DocIDPrefix 表结构文档 ID 前缀，文档 ID 为 schema:{table}
+	DocIDPrefix = "schema:"
+
+	maxSampleLen = 64
+
+	// 只对低基数的短字段采样：在前 sampleScanRows 行中去重后不超过 maxSampleDistinct 个值才视为枚举类字段，
+	// 邮箱、手机号等几乎每行不同的字段不会被采样
+	sampleScanRows     = 1000
+	maxSampleDistinct  = 20
+	maxSampleColumnLen = 32
+)
+
+// SampleOptions 字段示例值的采样配置
+type SampleOptions struct {
+	Values      int      // 每个字段最多保留的示例值数量，0 为不采样
+	DenyColumns []string // 不采样的字段：字段名包含该词（不区分大小写），或 table.column 精确匹配
+}
+
+// Column 字段信息
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Key      string // PRI / UNI / MUL
+	Default  string
+	Comment  string
+	Samples  []string // 示例值（去重）
+}
+
+// ForeignKey 外键
+type ForeignKey struct {
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// Table 一张表的结构
+type Table struct {
+	Name        string
+	Comment     string
+	Columns     []*Column
+	ForeignKeys []ForeignKey
+}
+
+// Introspect 通过 information_schema 读取当前库的表结构，policy 禁止访问的表会被跳过。
+// sample.Values 大于 0 时为低基数的枚举类字段收集示例值
+func Introspect(ctx context.Context, db *sql.DB, policy *sql_guard.Policy, sample SampleOptions) ([]*Table, error) {
+	tables, err := listTables(ctx, db, policy)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*Table, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	if err := loadColumns(ctx, db, byName); err != nil {
+		return nil, err
+	}
+	if err := loadForeignKeys(ctx, db, byName); err != nil {
+		return nil, err
+	}
+	if sample.Values > 0 {
+		for _, t := range tables {
+			if err := loadSamples(ctx, db, t, sample); err != nil {
+				return nil, fmt.Errorf("采样表 %s 失败: %w", t.Name, err)
+			}
+		}
+	}
+	return tables, nil
+}
+
+func listTables(ctx context.Context, db *sql.DB, policy *sql_guard.Policy) ([]*Table, error) {
+	rows, err := db.QueryContext(ctx, "SELECT table_name, table_comment FROM information_schema.tables "+
+		"WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name")
+	if err != nil {
+		return nil, fmt.Errorf("查询表列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []*Table
+	for rows.Next() {
+		t := &Table{}
+		if err := rows.Scan(&t.Name, &t.Comment); err != nil {
+			return nil, err
+		}
+		if policy != nil && !policy.TableAllowed(t.Name) {
+			continue
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+func loadColumns(ctx context.Context, db *sql.DB, tables map[string]*Table) error {
+	rows, err := db.QueryContext(ctx, "SELECT table_name, column_name, column_type, is_nullable, column_key, column_default, column_comment "+
+		"FROM information_schema.columns WHERE table_schema = DATABASE() ORDER BY table_name, ordinal_position")
+	if err != nil {
+		return fmt.Errorf("查询字段失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, nullable string
+		var def sql.NullString
+		c := &Column{}
+		if err := rows.Scan(&table, &c.Name, &c.Type, &nullable, &c.Key, &def, &c.Comment); err != nil {
+			return err
+		}
+		t, ok := tables[table]
+		if !ok {
+			continue
+		}
+		c.Nullable = nullable == "YES"
+		c.Default = def.String
+		t.Columns = append(t.Columns, c)
+	}
+	return rows.Err()
+}
+
+func loadForeignKeys(ctx context.Context, db *sql.DB, tables map[string]*Table) error {
+	rows, err := db.QueryContext(ctx, "SELECT table_name, column_name, referenced_table_name, referenced_column_name "+
+		"FROM information_schema.key_column_usage WHERE table_schema = DATABASE() AND referenced_table_name IS NOT NULL "+
+		"ORDER BY table_name, ordinal_position")
+	if err != nil {
+		return fmt.Errorf("查询外键失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		var fk ForeignKey
+		if err := rows.Scan(&table, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+		}
+	}
+	return rows.Err()
+}
+
+// loadSamples 为表中可采样的字段收集去重后的示例值，去重后的值过多时视为高基数字段，不保留示例
+func loadSamples(ctx context.Context, db *sql.DB, t *Table, opts SampleOptions) error {
+	for _, c := range t.Columns {
+		if !sampleable(t.Name, c, opts.DenyColumns) {
+			continue
+		}
+		col := quoteIdent(c.Name)
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT %s FROM (SELECT %s FROM %s LIMIT %d) s LIMIT %d",
+			col, col, quoteIdent(t.Name), sampleScanRows, maxSampleDistinct+1))
+		if err != nil {
+			return err
+		}
+		var values []string
+		for rows.Next() {
+			var v sql.RawBytes
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return err
+			}
+			if v == nil || !utf8.Valid(v) {
+				continue
+			}
+			s := string(v)
+			if r := []rune(s); len(r) > maxSampleLen {
+				s = string(r[:maxSampleLen]) + "..."
+			}
+			values = append(values, s)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if len(values) > maxSampleDistinct {
+			continue
+		}
+		if len(values) > opts.Values {
+			values = values[:opts.Values]
+		}
+		c.Samples = values
+	}
+	return nil
+}
+
+// sampleable 只采样整数状态码与短字符串字段；enum / set 的取值已写在类型中，无需采样
+func sampleable(table string, c *Column, deny []string) bool {
+	name := strings.ToLower(c.Name)
+	for _, d := range deny {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" {
+			continue
+		}
+		if tbl, col, ok := strings.Cut(d, "."); ok {
+			if tbl == strings.ToLower(table) && col == name {
+				return false
+			}
+			continue
+		}
+		if strings.Contains(name, d) {
+			return false
+		}
+	}
+
+	typ := strings.ToLower(c.Type)
+	switch {
+	case strings.HasPrefix(typ, "tinyint(1)"):
+		return false // 布尔字段
+	case strings.HasPrefix(typ, "tinyint"), strings.HasPrefix(typ, "smallint"):
+		return true
+	case strings.HasPrefix(typ, "char("), strings.HasPrefix(typ, "varchar("):
+		size, _, _ := strings.Cut(typ[strings.Index(typ, "(")+1:], ")")
+		n, err := strconv.Atoi(size)
+		return err == nil && n <= maxSampleColumnLen
+	}
+	return false
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Describe 生成供 SQL 生成模型阅读的表结构描述
+func (t *Table) Describe() string {
+	return t.describe(true)
+}
+
+// describe withSamples 为 false 时不包含示例值，用于计算表结构的 hash
+func (t *Table) describe(withSamples bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "表名: %s\n", t.Name)
+	if t.Comment != "" {
+		fmt.Fprintf(&b, "说明: %s\n", t.Comment)
+	}
+	b.WriteString("字段:\n")
+	for _, c := range t.Columns {
+		fmt.Fprintf(&b, "- %s %s", c.Name, c.Type)
+		switch c.Key {
+		case "PRI":
+			b.WriteString(" 主键")
+		case "UNI":
+			b.WriteString(" 唯一")
+		case "MUL":
+			b.WriteString(" 索引")
+		}
+		if !c.Nullable {
+			b.WriteString(" NOT NULL")
+		}
+		if c.Default != "" {
+			fmt.Fprintf(&b, " 默认值: %s", c.Default)
+		}
+		if c.Comment != "" {
+			fmt.Fprintf(&b, " 注释: %s", c.Comment)
+		}
+		if withSamples && len(c.Samples) > 0 {
+			fmt.Fprintf(&b, " 示例: %s", strings.Join(c.Samples, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if len(t.ForeignKeys) > 0 {
+		b.WriteString("外键:\n")
+		for _, fk := range t.ForeignKeys {
+			fmt.Fprintf(&b, "- %s -> %s.%s\n", fk.Column, fk.RefTable, fk.RefColumn)
+		}
+	}
+	return b.String()
+}
+
+// Document 将表结构转换为索引文档，metadata 中的 hash 用于判断表结构是否变化。
+// hash 不包含示例值，业务数据变化不会触发重新索引
+func (t *Table) Document() *schema.Document {
+	content := t.Describe()
+	sum := sha256.Sum256([]byte(t.describe(false)))
+	return &schema.Document{
+		ID:      DocIDPrefix + t.Name,
+		Content: content,
+		MetaData: map[string]any{
+			"source": "schema",
+			"table":  t.Name,
+			"hash":   hex.EncodeToString(sum[:]),
+		},
+	}
+}
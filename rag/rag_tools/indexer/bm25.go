@@ -0,0 +1,32 @@
+package indexer
+
+import (
+	"context"
+	"go-agent/rag/rag_tools/db"
+
+	"github.com/cloudwego/eino/components/indexer"
+	"github.com/cloudwego/eino/schema"
+)
+
+func initBM25() {
+	registerIndexer("bm25", func(ctx context.Context) (indexer.Indexer, error) {
+		index, err := db.GetBM25()
+		if err != nil {
+			return nil, err
+		}
+		return &bm25Indexer{index: index}, nil
+	})
+}
+
+// bm25Indexer 将分块写入进程内的 BM25 倒排索引，不需要向量化
+type bm25Indexer struct {
+	index *db.BM25Index
+}
+
+func (i *bm25Indexer) Store(ctx context.Context, docs []*schema.Document, opts ...indexer.Option) ([]string, error) {
+	return i.index.Upsert(docs)
+}
+
+func (i *bm25Indexer) GetType() string {
+	return "BM25"
+}
@@ -14,6 +14,7 @@ var indexerRegistry = make(map[string]IndexerFactory)
 func NewIndexer() {
 	initMilvus()
 	initES()
+	initBM25()
 }
 
 // registerIndexer 用于具体 Provider 在 init 时注册自己
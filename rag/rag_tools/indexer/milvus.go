@@ -27,46 +27,107 @@ type floatSchema struct {
 
 func initMilvus() {
 	registerIndexer("milvus", func(ctx context.Context) (indexer.Indexer, error) {
-		dim, err := getEmbeddingDim(ctx)
-		if err != nil {
-			return nil, err
-		}
-		log.Printf("embedding dim: %d", dim)
+		return newMilvusIndexer(ctx, &config.Cfg.MilvusConf.CollectionName)
+	})
+	// 数据库表结构使用独立集合，与上传的文档分开检索
+	registerIndexer("schema", func(ctx context.Context) (indexer.Indexer, error) {
+		return newMilvusIndexer(ctx, &config.Cfg.SchemaConf.Collection)
+	})
+	// 业务术语与 NL2SQL 示例使用独立集合
+	registerIndexer("sql_knowledge", func(ctx context.Context) (indexer.Indexer, error) {
+		return newMilvusIndexer(ctx, &config.Cfg.SQLKnowledgeConf.Collection)
+	})
+}
 
-		// 检查现有集合的维度是否匹配
-		if err := checkAndDropIfDimMismatch(ctx, config.Cfg.MilvusConf.CollectionName, dim); err != nil {
-			log.Printf("检查集合维度失败: %v", err)
-		}
+// Upserter 支持按主键覆盖写入的索引器
+type Upserter interface {
+	indexer.Indexer
+	// Upsert 按主键覆盖写入，主键已存在的文档被替换，写入失败时旧文档保持不变
+	Upsert(ctx context.Context, docs []*schema.Document) ([]string, error)
+}
 
-		indexer, err := milvus.NewIndexer(ctx, buildMilvusIndexerConfig(dim))
-		if err != nil {
-			// 自动处理 schema 不匹配：删除旧集合并重建
-			if strings.Contains(err.Error(), "collection schema not match") {
-				log.Printf("collection schema 不匹配，准备删除旧集合并重建: %s", config.Cfg.MilvusConf.CollectionName)
-				_ = db.Milvus.ReleaseCollection(ctx, config.Cfg.MilvusConf.CollectionName)
-				if dropErr := db.Milvus.DropCollection(ctx, config.Cfg.MilvusConf.CollectionName); dropErr != nil {
-					return nil, fmt.Errorf("drop collection failed: %w", dropErr)
-				}
-				if waitErr := waitCollectionDropped(ctx, config.Cfg.MilvusConf.CollectionName, 15*time.Second); waitErr != nil {
-					// 兜底：切换新集合名，避免启动失败
-					newName := fmt.Sprintf("%s_%d", config.Cfg.MilvusConf.CollectionName, time.Now().Unix())
-					log.Printf("旧集合仍存在，改用新集合: %s", newName)
-					config.Cfg.MilvusConf.CollectionName = newName
-				}
-				indexer, err = milvus.NewIndexer(ctx, buildMilvusIndexerConfig(dim))
-				if err != nil {
-					return nil, err
-				}
-			} else {
+// milvusIndexer 在 Milvus 索引器上增加按主键覆盖写入，与 Store 使用相同的向量化与文档转换
+type milvusIndexer struct {
+	*milvus.Indexer
+	conf *milvus.IndexerConfig
+}
+
+func (i *milvusIndexer) Upsert(ctx context.Context, docs []*schema.Document) ([]string, error) {
+	if len(docs) == 0 {
+		return []string{}, nil
+	}
+	texts := make([]string, 0, len(docs))
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		texts = append(texts, doc.Content)
+		ids = append(ids, doc.ID)
+	}
+	vectors, err := i.conf.Embedding.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	rows, err := i.conf.DocumentConverter(ctx, docs, vectors)
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err := i.conf.Client.DescribeCollection(ctx, i.conf.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("describe collection failed: %w", err)
+	}
+	columns, err := entity.AnyToColumns(rows, coll.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("convert rows failed: %w", err)
+	}
+	if _, err := i.conf.Client.Upsert(ctx, i.conf.Collection, i.conf.PartitionName, columns...); err != nil {
+		return nil, fmt.Errorf("upsert failed: %w", err)
+	}
+	return ids, nil
+}
+
+// newMilvusIndexer 创建写入 collection 的索引器，集合无法重建时会改写 collection 为新的集合名
+func newMilvusIndexer(ctx context.Context, collection *string) (Upserter, error) {
+	dim, err := getEmbeddingDim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("embedding dim: %d", dim)
+
+	// 检查现有集合的维度是否匹配
+	if err := checkAndDropIfDimMismatch(ctx, *collection, dim); err != nil {
+		log.Printf("检查集合维度失败: %v", err)
+	}
+
+	conf := buildMilvusIndexerConfig(*collection, dim)
+	indexer, err := milvus.NewIndexer(ctx, conf)
+	if err != nil {
+		// 自动处理 schema 不匹配：删除旧集合并重建
+		if strings.Contains(err.Error(), "collection schema not match") {
+			log.Printf("collection schema 不匹配，准备删除旧集合并重建: %s", *collection)
+			_ = db.Milvus.ReleaseCollection(ctx, *collection)
+			if dropErr := db.Milvus.DropCollection(ctx, *collection); dropErr != nil {
+				return nil, fmt.Errorf("drop collection failed: %w", dropErr)
+			}
+			if waitErr := waitCollectionDropped(ctx, *collection, 15*time.Second); waitErr != nil {
+				// 兜底：切换新集合名，避免启动失败
+				newName := fmt.Sprintf("%s_%d", *collection, time.Now().Unix())
+				log.Printf("旧集合仍存在，改用新集合: %s", newName)
+				*collection = newName
+			}
+			conf = buildMilvusIndexerConfig(*collection, dim)
+			indexer, err = milvus.NewIndexer(ctx, conf)
+			if err != nil {
 				return nil, err
 			}
+		} else {
+			return nil, err
 		}
+	}
 
-		return indexer, nil
-	})
+	return &milvusIndexer{Indexer: indexer, conf: conf}, nil
 }
 
-func buildMilvusIndexerConfig(dim int) *milvus.IndexerConfig {
+func buildMilvusIndexerConfig(collection string, dim int) *milvus.IndexerConfig {
 	emb, err := embedding_model.GetEmbeddingModel(context.Background(), config.Cfg.EmbeddingModelType)
 	if err != nil {
 		return nil
@@ -75,7 +136,7 @@ func buildMilvusIndexerConfig(dim int) *milvus.IndexerConfig {
 	return &milvus.IndexerConfig{
 		Client:     db.Milvus,
 		Embedding:  emb,
-		Collection: config.Cfg.MilvusConf.CollectionName,
+		Collection: collection,
 		MetricType: milvus.COSINE,
 		Fields: []*entity.Field{
 			entity.NewField().
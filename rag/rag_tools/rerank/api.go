@@ -0,0 +1,153 @@
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/config"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 重排接口的请求格式
+const (
+	FormatDashScope = "dashscope" // 阿里云百炼 text-rerank，如 gte-rerank-v2
+	FormatJina      = "jina"      // Jina / Cohere 兼容的 /v1/rerank，vLLM、Xinference 等本地服务也提供该格式
+)
+
+var defaultAPIURL = map[string]string{
+	FormatDashScope: "https://dashscope.aliyuncs.com/api/v1/services/rerank/text-rerank/text-rerank",
+	FormatJina:      "https://api.jina.ai/v1/rerank",
+}
+
+func initAPI() {
+	registerReranker("api", func(ctx context.Context) (Reranker, error) {
+		conf := config.Cfg.RerankConf
+		format := conf.APIFormat
+		url := conf.APIURL
+		if url == "" {
+			url = defaultAPIURL[format]
+		}
+		if url == "" {
+			return nil, fmt.Errorf("不支持的重排接口格式: %s，可选 dashscope / jina", format)
+		}
+		key := conf.APIKey
+		if key == "" && format == FormatDashScope {
+			key = config.Cfg.QwenConf.QwenKey
+		}
+		timeout, err := time.ParseDuration(conf.Timeout)
+		if err != nil {
+			timeout = 10 * time.Second
+		}
+		return NewAPIReranker(format, url, key, conf.Model, timeout)
+	})
+}
+
+// APIReranker 调用交叉编码器重排接口，query 与每个文档成对打分
+type APIReranker struct {
+	format string
+	url    string
+	key    string
+	model  string
+	client *http.Client
+}
+
+func NewAPIReranker(format, url, key, model string, timeout time.Duration) (*APIReranker, error) {
+	if format != FormatDashScope && format != FormatJina {
+		return nil, fmt.Errorf("不支持的重排接口格式: %s，可选 dashscope / jina", format)
+	}
+	return &APIReranker{format: format, url: url, key: key, model: model, client: &http.Client{Timeout: timeout}}, nil
+}
+
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+func (r *APIReranker) Rerank(ctx context.Context, query string, docs []*schema.Document, topN int) ([]*schema.Document, error) {
+	if len(docs) == 0 {
+		return docs, nil
+	}
+	contents := make([]string, len(docs))
+	for i, d := range docs {
+		contents[i] = d.Content
+	}
+	topN = min(topN, len(docs))
+
+	var body any
+	if r.format == FormatDashScope {
+		body = map[string]any{
+			"model":      r.model,
+			"input":      map[string]any{"query": query, "documents": contents},
+			"parameters": map[string]any{"top_n": topN, "return_documents": false},
+		}
+	} else {
+		body = map[string]any{"model": r.model, "query": query, "documents": contents, "top_n": topN, "return_documents": false}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.key != "" {
+		req.Header.Set("Authorization", "Bearer "+r.key)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("调用重排接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取重排接口响应失败: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("重排接口返回 %d: %s", resp.StatusCode, truncate(string(data), 300))
+	}
+
+	var out struct {
+		Results []rerankResult `json:"results"`
+		Output  struct {
+			Results []rerankResult `json:"results"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析重排接口响应失败: %w", err)
+	}
+	results := out.Results
+	if r.format == FormatDashScope {
+		results = out.Output.Results
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RelevanceScore > results[j].RelevanceScore
+	})
+
+	order := make([]int, 0, len(results))
+	scores := make([]float64, 0, len(results))
+	for _, res := range results {
+		if res.Index < 0 || res.Index >= len(docs) {
+			return nil, fmt.Errorf("重排接口返回了越界的下标: %d", res.Index)
+		}
+		order = append(order, res.Index)
+		scores = append(scores, res.RelevanceScore)
+	}
+	return reorder(docs, order, scores, topN), nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
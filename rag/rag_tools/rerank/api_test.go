@@ -0,0 +1,115 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestAPIRerankerRerank(t *testing.T) {
+	docs := []*schema.Document{
+		(&schema.Document{ID: "a", Content: "报销流程"}).WithScore(0.9),
+		(&schema.Document{ID: "b", Content: "年假申请"}).WithScore(0.5),
+		(&schema.Document{ID: "c", Content: "差旅标准"}).WithScore(0.1),
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		status  int
+		resp    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "dashscope 格式",
+			format: FormatDashScope,
+			status: http.StatusOK,
+			resp:   `{"output":{"results":[{"index":2,"relevance_score":0.3},{"index":1,"relevance_score":0.8}]}}`,
+			want:   []string{"b", "c"},
+		},
+		{
+			name:   "jina 格式",
+			format: FormatJina,
+			status: http.StatusOK,
+			resp:   `{"results":[{"index":0,"relevance_score":0.2},{"index":2,"relevance_score":0.7}]}`,
+			want:   []string{"c", "a"},
+		},
+		{
+			name:    "下标越界",
+			format:  FormatJina,
+			status:  http.StatusOK,
+			resp:    `{"results":[{"index":3,"relevance_score":0.9}]}`,
+			wantErr: "越界",
+		},
+		{
+			name:    "非 2xx 状态码",
+			format:  FormatDashScope,
+			status:  http.StatusUnauthorized,
+			resp:    `{"code":"InvalidApiKey"}`,
+			wantErr: "401",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Bearer k" {
+					t.Errorf("Authorization = %q", auth)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.resp))
+			}))
+			defer srv.Close()
+
+			r, err := NewAPIReranker(tt.format, srv.URL, "k", "m", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := r.Rerank(context.Background(), "报销", docs, 2)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 两种格式的 query 与 top_n 位置不同
+			query, topN := got["query"], got["top_n"]
+			if tt.format == FormatDashScope {
+				query = got["input"].(map[string]any)["query"]
+				topN = got["parameters"].(map[string]any)["top_n"]
+			}
+			if query != "报销" || topN != float64(2) {
+				t.Errorf("请求体 = %v", got)
+			}
+
+			if len(out) != len(tt.want) {
+				t.Fatalf("len = %d, want %d", len(out), len(tt.want))
+			}
+			for i, d := range out {
+				if d.ID != tt.want[i] {
+					t.Errorf("out[%d] = %s, want %s", i, d.ID, tt.want[i])
+				}
+				if d.MetaData[RerankScoreMetaKey] == nil {
+					t.Errorf("out[%d] 缺少原得分", i)
+				}
+			}
+			if out[0].Score() < out[1].Score() {
+				t.Errorf("得分未降序: %v, %v", out[0].Score(), out[1].Score())
+			}
+		})
+	}
+}
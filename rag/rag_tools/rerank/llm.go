@@ -0,0 +1,94 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"go-agent/model/chat_model"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxPassageRunes 列表式重排时每个文档写入提示词的最大字数
+const maxPassageRunes = 500
+
+const listwisePrompt = `你是检索结果的相关性排序助手。下面给出一个查询和若干编号的文档片段，请按与查询的相关性从高到低排列全部文档编号。
+只输出编号，格式如：[3] > [1] > [2]，不要输出解释。`
+
+var rankPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+func initLLM() {
+	registerReranker("llm", func(ctx context.Context) (Reranker, error) {
+		cm, err := chat_model.GetChatModel(ctx, config.Cfg.RerankConf.ModelType)
+		if err != nil {
+			return nil, err
+		}
+		return NewLLMReranker(cm), nil
+	})
+}
+
+// LLMReranker 列表式重排：把候选文档一次性交给聊天模型排序，不需要额外部署重排模型
+type LLMReranker struct {
+	model model.BaseChatModel
+}
+
+func NewLLMReranker(cm model.BaseChatModel) *LLMReranker {
+	return &LLMReranker{model: cm}
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []*schema.Document, topN int) ([]*schema.Document, error) {
+	if len(docs) == 0 {
+		return docs, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("查询：" + query + "\n\n文档：")
+	for i, d := range docs {
+		content := []rune(strings.Join(strings.Fields(d.Content), " "))
+		if len(content) > maxPassageRunes {
+			content = append(content[:maxPassageRunes], []rune("...")...)
+		}
+		fmt.Fprintf(&b, "\n[%d] %s", i+1, string(content))
+	}
+
+	out, err := r.model.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(listwisePrompt),
+		schema.UserMessage(b.String()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列表式重排失败: %w", err)
+	}
+	order := parseRanking(out.Content, len(docs))
+	if len(order) == 0 {
+		return nil, fmt.Errorf("无法解析重排结果: %s", truncate(out.Content, 200))
+	}
+	return reorder(docs, order, nil, topN), nil
+}
+
+// parseRanking 解析模型输出的编号顺序并转换为下标，
+// 忽略越界与重复的编号，模型遗漏的文档按原顺序排在最后
+func parseRanking(content string, n int) []int {
+	seen := make([]bool, n)
+	order := make([]int, 0, n)
+	for _, m := range rankPattern.FindAllStringSubmatch(content, -1) {
+		idx, err := strconv.Atoi(m[1])
+		if err != nil || idx < 1 || idx > n || seen[idx-1] {
+			continue
+		}
+		seen[idx-1] = true
+		order = append(order, idx-1)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	for i := range seen {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
@@ -0,0 +1,23 @@
+package rerank
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func initNoop() {
+	registerReranker("none", func(ctx context.Context) (Reranker, error) {
+		return noop{}, nil
+	})
+}
+
+// noop 不做精排，保持融合顺序截取 topN
+type noop struct{}
+
+func (noop) Rerank(ctx context.Context, query string, docs []*schema.Document, topN int) ([]*schema.Document, error) {
+	if len(docs) > topN {
+		docs = docs[:topN]
+	}
+	return docs, nil
+}
@@ -0,0 +1,66 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// RerankScoreMetaKey 重排前的融合得分，重排后 Score() 为重排模型给出的相关性
+const RerankScoreMetaKey = "_fusion_score"
+
+// Reranker 融合之后的第二阶段精排，从候选池中选出与查询最相关的 topN 个文档
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []*schema.Document, topN int) ([]*schema.Document, error)
+}
+
+type RerankerFactory func(ctx context.Context) (Reranker, error)
+
+var rerankerRegistry = make(map[string]RerankerFactory)
+
+func NewReranker() {
+	initNoop()
+	initAPI()
+	initLLM()
+}
+
+// registerReranker 用于具体实现在初始化时注册自己
+func registerReranker(name string, factory RerankerFactory) {
+	rerankerRegistry[name] = factory
+}
+
+func GetReranker(ctx context.Context, name string) (Reranker, error) {
+	create, ok := rerankerRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("未注册的重排类型: %s", name)
+	}
+
+	return create(ctx)
+}
+
+// reorder 按 order 中的下标重新排列文档并截取 topN，scores 不为空时写入新的得分，
+// 原得分保存在 MetaData[RerankScoreMetaKey]
+func reorder(docs []*schema.Document, order []int, scores []float64, topN int) []*schema.Document {
+	out := make([]*schema.Document, 0, min(topN, len(order)))
+	for i, idx := range order {
+		if len(out) >= topN {
+			break
+		}
+		src := docs[idx]
+		meta := make(map[string]any, len(src.MetaData)+1)
+		for k, v := range src.MetaData {
+			meta[k] = v
+		}
+		meta[RerankScoreMetaKey] = src.Score()
+		doc := &schema.Document{ID: src.ID, Content: src.Content, MetaData: meta}
+		if scores != nil {
+			doc.WithScore(scores[i])
+		} else {
+			// 列表式重排没有分数，按名次给出递减的得分
+			doc.WithScore(1 / float64(i+1))
+		}
+		out = append(out, doc)
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package retriever
+
+import (
+	"context"
+	"go-agent/config"
+	"go-agent/rag/rag_tools/db"
+	"strconv"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+func initBM25() {
+	registerRetriever("bm25", func(ctx context.Context) (retriever.Retriever, error) {
+		index, err := db.GetBM25()
+		if err != nil {
+			return nil, err
+		}
+
+		topK, _ := strconv.Atoi(config.Cfg.MilvusConf.TopK)
+		if topK <= 0 {
+			topK = 10
+		}
+		return &bm25Retriever{index: index, topK: topK}, nil
+	})
+}
+
+// bm25Retriever 基于进程内倒排索引的全文检索，不依赖 Elasticsearch
+type bm25Retriever struct {
+	index *db.BM25Index
+	topK  int
+}
+
+func (r *bm25Retriever) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	options := retriever.GetCommonOptions(&retriever.Options{TopK: &r.topK}, opts...)
+	return r.index.Search(query, *options.TopK, options.ScoreThreshold), nil
+}
+
+func (r *bm25Retriever) GetType() string {
+	return "BM25"
+}
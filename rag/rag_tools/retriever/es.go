@@ -3,6 +3,7 @@ package retriever
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"go-agent/config"
 	"go-agent/model/embedding_model"
 	"go-agent/rag/rag_tools/db"
@@ -25,22 +26,26 @@ func initES() {
 		}
 
 		topK, _ := strconv.Atoi(config.Cfg.MilvusConf.TopK) // 复用 TopK 配置或新增 ES TopK
+		if topK <= 0 {
+			topK = 10
+		}
+
+		emb, err := embedding_model.GetQueryEmbedder(context.Background(), config.Cfg.EmbeddingModelType)
+		if err != nil {
+			return nil, err
+		}
 
-		emb, err := embedding_model.GetEmbeddingModel(context.Background(), config.Cfg.EmbeddingModelType)
+		mode, err := esSearchMode()
 		if err != nil {
 			return nil, err
 		}
 
 		return es8.NewRetriever(ctx, &es8.RetrieverConfig{
-			Client:    db.ES,
-			Index:     config.Cfg.ESConf.Index,
-			TopK:      topK,
-			Embedding: emb,
-			// 使用向量相似度搜索
-			SearchMode: search_mode.SearchModeDenseVectorSimilarity(
-				search_mode.DenseVectorSimilarityTypeCosineSimilarity,
-				"content_vector",
-			),
+			Client:     db.ES,
+			Index:      config.Cfg.ESConf.Index,
+			TopK:       topK,
+			Embedding:  emb,
+			SearchMode: mode,
 			ResultParser: func(ctx context.Context, hit types.Hit) (*schema.Document, error) {
 				var src map[string]any
 				if err := json.Unmarshal(hit.Source_, &src); err != nil {
@@ -66,3 +71,25 @@ func initES() {
 		})
 	})
 }
+
+// esSearchMode 按 ES_SEARCH_MODE 选择检索方式，默认使用 BM25 全文检索，使融合的两路信号真正互补
+func esSearchMode() (es8.SearchMode, error) {
+	lexical := lexicalSearch{fields: []string{esContentField}}
+	switch mode := config.Cfg.ESConf.SearchMode; mode {
+	case "", ESModeBM25:
+		return &lexical, nil
+	case ESModeHybrid:
+		boost, err := strconv.ParseFloat(config.Cfg.ESConf.KNNBoost, 32)
+		if err != nil || boost < 0 {
+			boost = 1
+		}
+		return &hybridSearch{lexicalSearch: lexical, knnBoost: float32(boost)}, nil
+	case ESModeDense:
+		return search_mode.SearchModeDenseVectorSimilarity(
+			search_mode.DenseVectorSimilarityTypeCosineSimilarity,
+			esVectorField,
+		), nil
+	default:
+		return nil, fmt.Errorf("不支持的 ES 检索模式: %s，可选 bm25 / hybrid / dense", mode)
+	}
+}
@@ -0,0 +1,90 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino-ext/components/retriever/es8"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/textquerytype"
+)
+
+// ES 检索模式，通过 ES_SEARCH_MODE 选择
+const (
+	ESModeBM25   = "bm25"   // 全文检索，与 Milvus 的向量检索互补
+	ESModeHybrid = "hybrid" // ES 内部同时做近似 kNN 与 BM25，两路得分相加
+	ESModeDense  = "dense"  // 仅向量相似度，与 Milvus 重复，仅用于对比
+)
+
+const (
+	esContentField = "content"
+	esVectorField  = "content_vector"
+)
+
+// lexicalSearch 对 content 做 multi_match 查询，查询时使用字段映射中的 search_analyzer（ik_smart / smartcn）
+type lexicalSearch struct {
+	fields []string
+}
+
+func (s *lexicalSearch) BuildRequest(ctx context.Context, conf *es8.RetrieverConfig, query string, opts ...retriever.Option) (*search.Request, error) {
+	options := retriever.GetCommonOptions(&retriever.Options{TopK: &conf.TopK}, opts...)
+	return &search.Request{
+		Query:   s.query(query),
+		Size:    options.TopK,
+		Source_: excludeVector(),
+	}, nil
+}
+
+func (s *lexicalSearch) query(query string) *types.Query {
+	return &types.Query{MultiMatch: &types.MultiMatchQuery{
+		Query:  query,
+		Fields: s.fields,
+		Type:   &textquerytype.Bestfields,
+	}}
+}
+
+// hybridSearch 在一次请求中同时执行近似 kNN 与 BM25，ES 将两路得分按 boost 加权相加
+type hybridSearch struct {
+	lexicalSearch
+	knnBoost float32
+}
+
+func (s *hybridSearch) BuildRequest(ctx context.Context, conf *es8.RetrieverConfig, query string, opts ...retriever.Option) (*search.Request, error) {
+	options := retriever.GetCommonOptions(&retriever.Options{TopK: &conf.TopK, Embedding: conf.Embedding}, opts...)
+	if options.Embedding == nil {
+		return nil, fmt.Errorf("ES 混合检索需要配置 Embedding")
+	}
+	vectors, err := options.Embedding.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("ES 混合检索向量化失败: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("ES 混合检索向量化结果数量不正确: %d", len(vectors))
+	}
+	vector := make([]float32, len(vectors[0]))
+	for i, v := range vectors[0] {
+		vector[i] = float32(v)
+	}
+
+	k := *options.TopK
+	numCandidates := k * 10
+	return &search.Request{
+		Query: s.query(query),
+		Knn: []types.KnnSearch{{
+			Field:         esVectorField,
+			QueryVector:   vector,
+			K:             &k,
+			NumCandidates: &numCandidates,
+			Boost:         &s.knnBoost,
+		}},
+		Size:    options.TopK,
+		Source_: excludeVector(),
+	}, nil
+}
+
+// excludeVector 返回结果中不带向量字段，减少传输量
+func excludeVector() types.SourceConfig {
+	return &types.SourceFilter{Excludes: []string{esVectorField}}
+}
@@ -21,84 +21,105 @@ func initMilvus() {
 		if err != nil || topK <= 0 {
 			topK = 10
 		}
-		sp, _ := entity.NewIndexAUTOINDEXSearchParam(1)
-		emb, err := embedding_model.GetEmbeddingModel(context.Background(), config.Cfg.EmbeddingModelType)
-		if err != nil {
-			return nil, err
+		return newMilvusRetriever(ctx, config.Cfg.MilvusConf.CollectionName, topK)
+	})
+	// 只检索数据库表结构集合，供 SQL 生成使用
+	registerRetriever("schema", func(ctx context.Context) (retriever.Retriever, error) {
+		topK, err := strconv.Atoi(config.Cfg.SchemaConf.TopK)
+		if err != nil || topK <= 0 {
+			topK = 5
 		}
-		ret, err := milvus.NewRetriever(ctx, &milvus.RetrieverConfig{
-			Client:       db.Milvus,
-			Embedding:    emb,
-			TopK:         topK,
-			Collection:   config.Cfg.MilvusConf.CollectionName,
-			VectorField:  "vector",
-			OutputFields: []string{"id", "content", "metadata"},
-			MetricType:   entity.COSINE,
-			Sp:           sp,
-			VectorConverter: func(ctx context.Context, vectors [][]float64) ([]entity.Vector, error) {
-				vecs := make([]entity.Vector, 0, len(vectors))
-				for _, v := range vectors {
-					v32 := make([]float32, len(v))
-					for i, val := range v {
-						v32[i] = float32(val)
-					}
-					vecs = append(vecs, entity.FloatVector(v32))
-				}
-				return vecs, nil
-			},
-			DocumentConverter: func(ctx context.Context, result client.SearchResult) ([]*schema.Document, error) {
-				docs := make([]*schema.Document, result.IDs.Len())
-				for i := range docs {
-					docs[i] = &schema.Document{MetaData: map[string]any{}}
+		return newMilvusRetriever(ctx, config.Cfg.SchemaConf.Collection, topK)
+	})
+	// 检索业务术语与 NL2SQL 示例，调用时通过 milvus.WithFilter 只召回已确认的条目
+	registerRetriever("sql_knowledge", func(ctx context.Context) (retriever.Retriever, error) {
+		topK, err := strconv.Atoi(config.Cfg.SQLKnowledgeConf.TopK)
+		if err != nil || topK <= 0 {
+			topK = 5
+		}
+		return newMilvusRetriever(ctx, config.Cfg.SQLKnowledgeConf.Collection, topK)
+	})
+}
+
+func newMilvusRetriever(ctx context.Context, collection string, topK int) (retriever.Retriever, error) {
+	sp, _ := entity.NewIndexAUTOINDEXSearchParam(1)
+	emb, err := embedding_model.GetQueryEmbedder(context.Background(), config.Cfg.EmbeddingModelType)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := milvus.NewRetriever(ctx, &milvus.RetrieverConfig{
+		Client:       db.Milvus,
+		Embedding:    emb,
+		TopK:         topK,
+		Collection:   collection,
+		VectorField:  "vector",
+		OutputFields: []string{"id", "content", "metadata"},
+		MetricType:   entity.COSINE,
+		Sp:           sp,
+		VectorConverter: func(ctx context.Context, vectors [][]float64) ([]entity.Vector, error) {
+			vecs := make([]entity.Vector, 0, len(vectors))
+			for _, v := range vectors {
+				v32 := make([]float32, len(v))
+				for i, val := range v {
+					v32[i] = float32(val)
 				}
+				vecs = append(vecs, entity.FloatVector(v32))
+			}
+			return vecs, nil
+		},
+		DocumentConverter: func(ctx context.Context, result client.SearchResult) ([]*schema.Document, error) {
+			docs := make([]*schema.Document, result.IDs.Len())
+			for i := range docs {
+				docs[i] = &schema.Document{MetaData: map[string]any{}}
+			}
 
-				for _, field := range result.Fields {
-					switch field.Name() {
-					case "id":
-						for i := range docs {
-							id, err := result.IDs.GetAsString(i)
-							if err != nil {
-								return nil, err
-							}
-							docs[i].ID = id
+			for _, field := range result.Fields {
+				switch field.Name() {
+				case "id":
+					for i := range docs {
+						id, err := result.IDs.GetAsString(i)
+						if err != nil {
+							return nil, err
+						}
+						docs[i].ID = id
+					}
+				case "content":
+					for i := range docs {
+						content, err := field.GetAsString(i)
+						if err != nil {
+							return nil, err
 						}
-					case "content":
-						for i := range docs {
-							content, err := field.GetAsString(i)
-							if err != nil {
-								return nil, err
-							}
-							docs[i].Content = content
+						docs[i].Content = content
+					}
+				case "metadata":
+					for i := range docs {
+						raw, err := field.Get(i)
+						if err != nil {
+							return nil, err
 						}
-					case "metadata":
-						for i := range docs {
-							raw, err := field.Get(i)
-							if err != nil {
-								return nil, err
-							}
-							if b, ok := raw.([]byte); ok {
-								_ = json.Unmarshal(b, &docs[i].MetaData)
-							}
+						if b, ok := raw.([]byte); ok {
+							_ = json.Unmarshal(b, &docs[i].MetaData)
 						}
 					}
 				}
+			}
 
-				// 写入相似度分数（Milvus 返回的是 distance）
-				for i := range docs {
-					if i < len(result.Scores) {
-						distance := float64(result.Scores[i])
-						docs[i].MetaData["distance"] = distance
-						docs[i].WithScore(1 - distance)
-					}
+			// 写入相似度分数：COSINE 度量下 Milvus 返回的是余弦相似度，越大越相似，
+			// 融合时按分数归一化依赖“越大越好”，distance 仅作展示
+			for i := range docs {
+				if i < len(result.Scores) {
+					similarity := float64(result.Scores[i])
+					docs[i].MetaData["distance"] = 1 - similarity
+					docs[i].WithScore(similarity)
 				}
+			}
 
-				return docs, nil
-			},
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		return ret, nil
+			return docs, nil
+		},
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
 }
@@ -14,6 +14,7 @@ var retrieverRegistry = make(map[string]RetrieverFactory)
 func NewRetriever() {
 	initMilvus()
 	initES()
+	initBM25()
 }
 
 // registerRetriever 用于具体 Provider 在 init 时注册自己
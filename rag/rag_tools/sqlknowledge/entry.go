@@ -0,0 +1,174 @@
+package sqlknowledge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+)
+
+// Kind 条目类型
+type Kind string
+
+const (
+	KindGlossary Kind = "glossary" // 业务术语：行业黑话 -> SQL 表达式
+	KindExample  Kind = "example"  // 已验证的 问题 -> SQL 示例
+)
+
+// Status 条目状态，只有 verified 的条目会参与 SQL 生成
+type Status string
+
+const (
+	StatusVerified Status = "verified"
+	StatusProposed Status = "proposed" // 审批执行成功后自动提议，等待人工确认
+)
+
+// SourceMeta 知识库文档 metadata 中的 source 取值
+const SourceMeta = "sql_knowledge"
+
+var ErrInvalid = errors.New("条目内容不完整")
+
+// Entry 业务术语或 NL2SQL 示例
+type Entry struct {
+	ID     string `json:"id"`
+	Kind   Kind   `json:"kind"`
+	Status Status `json:"status"`
+
+	// 业务术语
+	Term       string   `json:"term,omitempty"`       // 术语，如 "活跃用户"
+	Synonyms   []string `json:"synonyms,omitempty"`   // 同义说法，如 "日活"
+	Expression string   `json:"expression,omitempty"` // 对应的 SQL 表达式，如 "last_login >= CURDATE() - INTERVAL 30 DAY"
+
+	// NL2SQL 示例
+	Question string `json:"question,omitempty"`
+	SQL      string `json:"sql,omitempty"`
+
+	Description string    `json:"description,omitempty"`
+	Tables      []string  `json:"tables,omitempty"`     // 涉及的表
+	SessionID   string    `json:"session_id,omitempty"` // 自动提议时来源的会话
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate 检查必填字段并补全默认状态
+func (e *Entry) Validate() error {
+	switch e.Kind {
+	case KindGlossary:
+		if strings.TrimSpace(e.Term) == "" || strings.TrimSpace(e.Expression) == "" {
+			return fmt.Errorf("%w: 业务术语需要 term 与 expression", ErrInvalid)
+		}
+	case KindExample:
+		if strings.TrimSpace(e.Question) == "" || strings.TrimSpace(e.SQL) == "" {
+			return fmt.Errorf("%w: 示例需要 question 与 sql", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: kind 只能是 glossary 或 example", ErrInvalid)
+	}
+	switch e.Status {
+	case "":
+		e.Status = StatusVerified
+	case StatusVerified, StatusProposed:
+	default:
+		return fmt.Errorf("%w: status 只能是 verified 或 proposed", ErrInvalid)
+	}
+	return nil
+}
+
+// Content 用于向量化的文本：术语按术语与同义词召回，示例按问题召回
+func (e *Entry) Content() string {
+	if e.Kind == KindGlossary {
+		var b strings.Builder
+		fmt.Fprintf(&b, "术语: %s", e.Term)
+		if len(e.Synonyms) > 0 {
+			fmt.Fprintf(&b, "\n同义词: %s", strings.Join(e.Synonyms, ", "))
+		}
+		if e.Description != "" {
+			fmt.Fprintf(&b, "\n说明: %s", e.Description)
+		}
+		return b.String()
+	}
+	return e.Question
+}
+
+// Document 将条目转换为索引文档，完整条目保存在 metadata 的 entry 中
+func (e *Entry) Document() *schema.Document {
+	return &schema.Document{
+		ID:      e.ID,
+		Content: e.Content(),
+		MetaData: map[string]any{
+			"source": SourceMeta,
+			"kind":   string(e.Kind),
+			"status": string(e.Status),
+			"entry":  e,
+		},
+	}
+}
+
+// NewID 生成条目 ID，格式为 {kind}:{uuid}，可按前缀区分条目类型
+func NewID(kind Kind) string {
+	return string(kind) + ":" + uuid.NewString()
+}
+
+// ProposalID 自动提议的示例按 SQL 生成固定 ID，同一条 SQL 重复执行不会产生多条提议
+func ProposalID(sql string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(sql), " "))))
+	return string(KindExample) + ":" + hex.EncodeToString(sum[:16])
+}
+
+// FromMetadata 从文档 metadata 还原条目
+func FromMetadata(meta map[string]any) (*Entry, error) {
+	raw, ok := meta["entry"]
+	if !ok {
+		return nil, fmt.Errorf("metadata 中缺少 entry")
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// FormatGlossary 将术语整理为提示词片段，没有术语时返回空串
+func FormatGlossary(entries []*Entry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		if e.Kind != KindGlossary {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("\n\n业务术语（生成 SQL 时按对应表达式理解）：")
+		}
+		fmt.Fprintf(&b, "\n- %s", e.Term)
+		if len(e.Synonyms) > 0 {
+			fmt.Fprintf(&b, "（%s）", strings.Join(e.Synonyms, "、"))
+		}
+		fmt.Fprintf(&b, ": %s", e.Expression)
+		if e.Description != "" {
+			fmt.Fprintf(&b, " —— %s", e.Description)
+		}
+	}
+	return b.String()
+}
+
+// FewShotMessages 将示例转换为 用户需求 -> SQL 的多轮对话，作为 few-shot 注入模板
+func FewShotMessages(entries []*Entry) []*schema.Message {
+	var msgs []*schema.Message
+	for _, e := range entries {
+		if e.Kind != KindExample {
+			continue
+		}
+		msgs = append(msgs, schema.UserMessage("用户需求："+e.Question), schema.AssistantMessage(e.SQL, nil))
+	}
+	return msgs
+}
@@ -0,0 +1,566 @@
+package analyst_tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 图表类型
+const (
+	ChartTypeTable      = "table"
+	ChartTypeBar        = "bar"
+	ChartTypeGroupedBar = "grouped_bar"
+	ChartTypeStackedBar = "stacked_bar"
+	ChartTypeLine       = "line"
+	ChartTypePie        = "pie"
+	ChartTypeScatter    = "scatter"
+	ChartTypeDualAxis   = "dual_axis"
+)
+
+const (
+	maxPieSlices     = 6  // 饼图最多扇区数
+	maxSeriesPivot   = 10 // 按分组列拆分的最大系列数
+	dualAxisMinRatio = 10 // 两个指标量级相差超过该倍数时使用双轴
+)
+
+var supportedChartTypes = map[string]bool{
+	ChartTypeTable:      true,
+	ChartTypeBar:        true,
+	ChartTypeGroupedBar: true,
+	ChartTypeStackedBar: true,
+	ChartTypeLine:       true,
+	ChartTypePie:        true,
+	ChartTypeScatter:    true,
+	ChartTypeDualAxis:   true,
+}
+
+// ChartHint 调用方指定的图表偏好，字段均可选
+type ChartHint struct {
+	Type      string   `json:"type,omitempty"`      // 图表类型
+	Dimension string   `json:"dimension,omitempty"` // 维度列（X 轴 / 饼图名称）
+	Measures  []string `json:"measures,omitempty"`  // 指标列
+	Series    string   `json:"series,omitempty"`    // 分组列，透视为多个系列
+}
+
+// ChartRecommendation 图表推荐结果及理由
+type ChartRecommendation struct {
+	Type      string   `json:"type"`             // 图表类型
+	Dimension string   `json:"dimension"`        // 维度列
+	Series    string   `json:"series,omitempty"` // 分组列
+	Measures  []string `json:"measures"`         // 指标列
+	Reason    string   `json:"reason"`           // 推荐理由
+}
+
+// RecommendChartType 按列类型选择维度与指标并推荐图表类型，hint 可为空
+func RecommendChartType(data *ParsedData, hint *ChartHint) *ChartRecommendation {
+	if data.RowCount == 0 {
+		return &ChartRecommendation{Type: ChartTypeTable, Reason: "查询结果为空，使用表格展示"}
+	}
+	if data.ColumnTypes == nil {
+		InferColumnTypes(data)
+	}
+
+	rec := autoRecommend(data)
+	if hint == nil {
+		return rec
+	}
+	return applyHint(data, rec, hint)
+}
+
+// autoRecommend 根据列类型自动推荐
+func autoRecommend(data *ParsedData) *ChartRecommendation {
+	measures := data.ColumnsOfType(ColumnTypeInt, ColumnTypeFloat)
+	dates := data.ColumnsOfType(ColumnTypeDate)
+	categories := data.ColumnsOfType(ColumnTypeCategory, ColumnTypeBool)
+	texts := data.ColumnsOfType(ColumnTypeText)
+
+	if len(measures) == 0 {
+		return &ChartRecommendation{Type: ChartTypeTable, Reason: "结果中没有数值列，使用表格展示"}
+	}
+	if data.RowCount == 1 {
+		return &ChartRecommendation{Type: ChartTypeTable, Measures: measures, Reason: "结果只有一行，使用表格展示指标"}
+	}
+
+	// 时间序列
+	if len(dates) > 0 {
+		rec := &ChartRecommendation{Type: ChartTypeLine, Dimension: dates[0], Measures: measures}
+		if series := pickSeriesColumn(data, categories, ""); series != "" && len(measures) == 1 {
+			rec.Series = series
+			rec.Reason = fmt.Sprintf("%s 为时间列，按 %s 分组拆分为多条折线展示 %s 的走势", dates[0], series, measures[0])
+			return rec
+		}
+		if len(measures) >= 2 && scaleRatio(data, measures[0], measures[1]) >= dualAxisMinRatio {
+			rec.Type = ChartTypeDualAxis
+			rec.Measures = measures[:2]
+			rec.Reason = fmt.Sprintf("%s 为时间列，%s 与 %s 量级相差较大，使用柱线双轴图", dates[0], measures[0], measures[1])
+			return rec
+		}
+		rec.Reason = fmt.Sprintf("%s 为时间列，使用折线图展示 %s 的走势", dates[0], strings.Join(measures, "、"))
+		return rec
+	}
+
+	// 分类对比
+	dims := append(categories, texts...)
+	if len(dims) > 0 {
+		dim := dims[0]
+		rec := &ChartRecommendation{Type: ChartTypeBar, Dimension: dim, Measures: measures}
+		if series := pickSeriesColumn(data, categories, dim); series != "" && len(measures) == 1 {
+			rec.Type = ChartTypeStackedBar
+			rec.Series = series
+			rec.Reason = fmt.Sprintf("%s 与 %s 均为分类列，使用堆叠柱状图展示 %s 的构成", dim, series, measures[0])
+			return rec
+		}
+		if len(measures) >= 2 {
+			if scaleRatio(data, measures[0], measures[1]) >= dualAxisMinRatio {
+				rec.Type = ChartTypeDualAxis
+				rec.Measures = measures[:2]
+				rec.Reason = fmt.Sprintf("%s 为分类列，%s 与 %s 量级相差较大，使用柱线双轴图", dim, measures[0], measures[1])
+				return rec
+			}
+			rec.Type = ChartTypeGroupedBar
+			rec.Reason = fmt.Sprintf("%s 为分类列，使用分组柱状图对比 %s", dim, strings.Join(measures, "、"))
+			return rec
+		}
+		if data.RowCount <= maxPieSlices && allPositive(data, measures[0]) {
+			rec.Type = ChartTypePie
+			rec.Reason = fmt.Sprintf("%s 仅有 %d 个取值且 %s 均为正数，使用饼图展示占比", dim, data.RowCount, measures[0])
+			return rec
+		}
+		rec.Reason = fmt.Sprintf("%s 为分类列，使用柱状图对比 %s", dim, measures[0])
+		return rec
+	}
+
+	// 只有数值列
+	if len(measures) >= 2 {
+		return &ChartRecommendation{
+			Type:      ChartTypeScatter,
+			Dimension: measures[0],
+			Measures:  []string{measures[1]},
+			Reason:    fmt.Sprintf("结果只有数值列，使用散点图观察 %s 与 %s 的相关性", measures[0], measures[1]),
+		}
+	}
+	return &ChartRecommendation{
+		Type:     ChartTypeBar,
+		Measures: measures,
+		Reason:   fmt.Sprintf("结果只有一个数值列 %s，按行序使用柱状图展示", measures[0]),
+	}
+}
+
+// applyHint 用调用方提示覆盖自动推荐中合法的部分
+func applyHint(data *ParsedData, rec *ChartRecommendation, hint *ChartHint) *ChartRecommendation {
+	out := *rec
+	var notes []string
+
+	if hint.Type != "" {
+		if supportedChartTypes[hint.Type] {
+			out.Type = hint.Type
+		} else {
+			notes = append(notes, fmt.Sprintf("不支持的图表类型 %s 已忽略", hint.Type))
+		}
+	}
+	if hint.Dimension != "" {
+		if hasColumn(data, hint.Dimension) {
+			out.Dimension = hint.Dimension
+		} else {
+			notes = append(notes, fmt.Sprintf("维度列 %s 不存在已忽略", hint.Dimension))
+		}
+	}
+	if len(hint.Measures) > 0 {
+		valid := make([]string, 0, len(hint.Measures))
+		for _, m := range hint.Measures {
+			if t := data.ColumnTypes[m]; t == ColumnTypeInt || t == ColumnTypeFloat {
+				valid = append(valid, m)
+			} else {
+				notes = append(notes, fmt.Sprintf("指标列 %s 不是数值列已忽略", m))
+			}
+		}
+		if len(valid) > 0 {
+			out.Measures = valid
+		}
+	}
+	if hint.Series != "" {
+		if hasColumn(data, hint.Series) {
+			out.Series = hint.Series
+		} else {
+			notes = append(notes, fmt.Sprintf("分组列 %s 不存在已忽略", hint.Series))
+		}
+	}
+
+	// 非透视类图表不使用分组列
+	if out.Type != ChartTypeLine && out.Type != ChartTypeBar && out.Type != ChartTypeStackedBar && out.Type != ChartTypeGroupedBar {
+		out.Series = ""
+	}
+	if out.Dimension != "" {
+		out.Measures = without(out.Measures, out.Dimension)
+	}
+
+	if len(out.Measures) == 0 && out.Type != ChartTypeTable {
+		notes = append(notes, "没有可用的指标列，改用表格")
+		out.Type = ChartTypeTable
+	}
+
+	out.Reason = fmt.Sprintf("按调用方提示使用 %s", out.Type)
+	if len(notes) > 0 {
+		out.Reason += "（" + strings.Join(notes, "；") + "）"
+	}
+	out.Reason += "；自动推荐为 " + rec.Type + "：" + rec.Reason
+	return &out
+}
+
+// BuildChart 根据推荐结果生成 ECharts 配置
+func BuildChart(data *ParsedData, rec *ChartRecommendation) (map[string]interface{}, error) {
+	var option map[string]interface{}
+	switch rec.Type {
+	case ChartTypeBar, ChartTypeGroupedBar:
+		option = buildCartesian(data, rec, "bar", false)
+	case ChartTypeStackedBar:
+		option = buildCartesian(data, rec, "bar", true)
+	case ChartTypeLine:
+		option = buildCartesian(data, rec, "line", false)
+	case ChartTypeDualAxis:
+		option = buildDualAxis(data, rec)
+	case ChartTypePie:
+		option = buildPie(data, rec)
+	case ChartTypeScatter:
+		option = buildScatter(data, rec)
+	default:
+		return GenerateTableChart(data)
+	}
+	if option == nil {
+		return GenerateTableChart(data)
+	}
+
+	option["title"] = map[string]interface{}{
+		"text":    chartTitle(rec.Type),
+		"subtext": rec.Reason,
+	}
+	return option, nil
+}
+
+// buildCartesian 生成直角坐标系图表，支持按分组列透视或多指标多系列
+func buildCartesian(data *ParsedData, rec *ChartRecommendation, seriesType string, stack bool) map[string]interface{} {
+	if len(rec.Measures) == 0 {
+		return nil
+	}
+	xKeys, xLabels := axisCategories(data, rec.Dimension)
+
+	series := make([]map[string]interface{}, 0)
+	legend := make([]string, 0)
+
+	if rec.Series != "" {
+		// 透视：每个分组值一个系列
+		measure := rec.Measures[0]
+		groups := orderedDistinct(data, rec.Series)
+		if len(groups) > maxSeriesPivot {
+			groups = groups[:maxSeriesPivot]
+		}
+		for _, grp := range groups {
+			values := make(map[string]float64)
+			for i, row := range data.Rows {
+				if fmt.Sprintf("%v", row[rec.Series]) != grp {
+					continue
+				}
+				if num, ok := toFloat(row[measure]); ok {
+					values[rowKey(row, rec.Dimension, i)] += num
+				}
+			}
+			series = append(series, cartesianSeries(grp, seriesType, stack, alignValues(xKeys, values)))
+			legend = append(legend, grp)
+		}
+	} else {
+		for _, measure := range rec.Measures {
+			values := make(map[string]float64)
+			for i, row := range data.Rows {
+				if num, ok := toFloat(row[measure]); ok {
+					values[rowKey(row, rec.Dimension, i)] += num
+				}
+			}
+			series = append(series, cartesianSeries(measure, seriesType, stack, alignValues(xKeys, values)))
+			legend = append(legend, measure)
+		}
+	}
+
+	return map[string]interface{}{
+		"tooltip": map[string]interface{}{"trigger": "axis"},
+		"legend":  map[string]interface{}{"data": legend},
+		"xAxis": map[string]interface{}{
+			"type": "category",
+			"name": rec.Dimension,
+			"data": xLabels,
+		},
+		"yAxis": map[string]interface{}{
+			"type": "value",
+		},
+		"series": series,
+	}
+}
+
+// buildDualAxis 第一个指标为柱状（左轴），第二个指标为折线（右轴）
+func buildDualAxis(data *ParsedData, rec *ChartRecommendation) map[string]interface{} {
+	if len(rec.Measures) < 2 {
+		return buildCartesian(data, rec, "bar", false)
+	}
+	xKeys, xLabels := axisCategories(data, rec.Dimension)
+
+	series := make([]map[string]interface{}, 0, 2)
+	for i, measure := range rec.Measures[:2] {
+		values := make(map[string]float64)
+		for j, row := range data.Rows {
+			if num, ok := toFloat(row[measure]); ok {
+				values[rowKey(row, rec.Dimension, j)] += num
+			}
+		}
+		typ := "bar"
+		if i == 1 {
+			typ = "line"
+		}
+		series = append(series, map[string]interface{}{
+			"name":       measure,
+			"type":       typ,
+			"yAxisIndex": i,
+			"data":       alignValues(xKeys, values),
+		})
+	}
+
+	return map[string]interface{}{
+		"tooltip": map[string]interface{}{"trigger": "axis"},
+		"legend":  map[string]interface{}{"data": rec.Measures[:2]},
+		"xAxis": map[string]interface{}{
+			"type": "category",
+			"name": rec.Dimension,
+			"data": xLabels,
+		},
+		"yAxis": []map[string]interface{}{
+			{"type": "value", "name": rec.Measures[0]},
+			{"type": "value", "name": rec.Measures[1], "position": "right"},
+		},
+		"series": series,
+	}
+}
+
+func buildPie(data *ParsedData, rec *ChartRecommendation) map[string]interface{} {
+	if rec.Dimension == "" || len(rec.Measures) == 0 {
+		return nil
+	}
+	measure := rec.Measures[0]
+	seriesData := make([]map[string]interface{}, 0, data.RowCount)
+	for _, row := range data.Rows {
+		num, ok := toFloat(row[measure])
+		if !ok {
+			continue
+		}
+		seriesData = append(seriesData, map[string]interface{}{
+			"name":  fmt.Sprintf("%v", row[rec.Dimension]),
+			"value": num,
+		})
+	}
+
+	return map[string]interface{}{
+		"tooltip": map[string]interface{}{"trigger": "item"},
+		"legend":  map[string]interface{}{"orient": "vertical", "left": "left"},
+		"series": []map[string]interface{}{
+			{
+				"name": measure,
+				"type": "pie",
+				"data": seriesData,
+			},
+		},
+	}
+}
+
+func buildScatter(data *ParsedData, rec *ChartRecommendation) map[string]interface{} {
+	if len(rec.Measures) == 0 {
+		return nil
+	}
+	xCol, yCol := rec.Dimension, rec.Measures[0]
+	if t := data.ColumnTypes[xCol]; t != ColumnTypeInt && t != ColumnTypeFloat {
+		// 散点图的 X 轴必须为数值列
+		if len(rec.Measures) < 2 {
+			return nil
+		}
+		xCol, yCol = rec.Measures[0], rec.Measures[1]
+	}
+
+	points := make([][]float64, 0, data.RowCount)
+	for _, row := range data.Rows {
+		x, okX := toFloat(row[xCol])
+		y, okY := toFloat(row[yCol])
+		if okX && okY {
+			points = append(points, []float64{x, y})
+		}
+	}
+
+	return map[string]interface{}{
+		"tooltip": map[string]interface{}{"trigger": "item"},
+		"xAxis":   map[string]interface{}{"type": "value", "name": xCol},
+		"yAxis":   map[string]interface{}{"type": "value", "name": yCol},
+		"series": []map[string]interface{}{
+			{
+				"name": yCol,
+				"type": "scatter",
+				"data": points,
+			},
+		},
+	}
+}
+
+func cartesianSeries(name, typ string, stack bool, data []interface{}) map[string]interface{} {
+	s := map[string]interface{}{
+		"name": name,
+		"type": typ,
+		"data": data,
+	}
+	if stack {
+		s["stack"] = "total"
+	}
+	return s
+}
+
+// axisCategories 返回 X 轴取值；时间维度按时间排序，其他维度保持出现顺序，无维度时使用行号
+func axisCategories(data *ParsedData, dim string) ([]string, []interface{}) {
+	if dim == "" {
+		keys := make([]string, data.RowCount)
+		labels := make([]interface{}, data.RowCount)
+		for i := range data.Rows {
+			keys[i] = fmt.Sprintf("#%d", i)
+			labels[i] = i + 1
+		}
+		return keys, labels
+	}
+
+	keys := orderedDistinct(data, dim)
+	if data.ColumnTypes[dim] == ColumnTypeDate {
+		sort.SliceStable(keys, func(i, j int) bool {
+			ti, _ := toDate(keys[i])
+			tj, _ := toDate(keys[j])
+			return ti.Before(tj)
+		})
+	}
+	labels := make([]interface{}, len(keys))
+	for i, k := range keys {
+		labels[i] = k
+	}
+	return keys, labels
+}
+
+func rowKey(row map[string]interface{}, dim string, idx int) string {
+	if dim == "" {
+		return fmt.Sprintf("#%d", idx)
+	}
+	return fmt.Sprintf("%v", row[dim])
+}
+
+// alignValues 按 X 轴顺序对齐取值，缺失处为 nil
+func alignValues(keys []string, values map[string]float64) []interface{} {
+	out := make([]interface{}, len(keys))
+	for i, k := range keys {
+		if v, ok := values[k]; ok {
+			out[i] = v
+		}
+	}
+	return out
+}
+
+// pickSeriesColumn 选择一个基数适中的分类列作为分组列
+func pickSeriesColumn(data *ParsedData, candidates []string, exclude string) string {
+	for _, col := range candidates {
+		if col == exclude {
+			continue
+		}
+		n := len(orderedDistinct(data, col))
+		if n >= 2 && n <= maxSeriesPivot && n < data.RowCount {
+			return col
+		}
+	}
+	return ""
+}
+
+func orderedDistinct(data *ParsedData, col string) []string {
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+	for _, row := range data.Rows {
+		if isNull(row[col]) {
+			continue
+		}
+		v := fmt.Sprintf("%v", row[col])
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// scaleRatio 返回两列绝对均值之比（大/小），用于判断是否需要双轴
+func scaleRatio(data *ParsedData, a, b string) float64 {
+	ma, mb := absMean(data, a), absMean(data, b)
+	if ma == 0 || mb == 0 {
+		return 0
+	}
+	return math.Max(ma, mb) / math.Min(ma, mb)
+}
+
+func absMean(data *ParsedData, col string) float64 {
+	var sum float64
+	var n int
+	for _, row := range data.Rows {
+		if num, ok := toFloat(row[col]); ok {
+			sum += math.Abs(num)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func allPositive(data *ParsedData, col string) bool {
+	for _, row := range data.Rows {
+		num, ok := toFloat(row[col])
+		if !ok || num <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hasColumn(data *ParsedData, col string) bool {
+	for _, c := range data.Columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+func without(cols []string, col string) []string {
+	out := make([]string, 0, len(cols))
+	for _, c := range cols {
+		if c != col {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func chartTitle(typ string) string {
+	switch typ {
+	case ChartTypeLine:
+		return "数据趋势折线图"
+	case ChartTypePie:
+		return "数据分布饼图"
+	case ChartTypeScatter:
+		return "数据相关性散点图"
+	case ChartTypeDualAxis:
+		return "指标对比双轴图"
+	case ChartTypeStackedBar:
+		return "数据构成堆叠柱状图"
+	case ChartTypeGroupedBar:
+		return "数据对比分组柱状图"
+	default:
+		return "数据分析柱状图"
+	}
+}
@@ -0,0 +1,483 @@
+package analyst_tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	iqrFactor          = 1.5  // IQR 离群判定系数
+	zScoreThreshold    = 3.0  // z-score 离群阈值
+	maxOutliersPerCol  = 10   // 每列最多报告的离群点
+	trendAlpha         = 0.05 // 趋势显著性水平
+	minTrendPoints     = 3    // 趋势检测的最少数据点
+	minSeasonalCycles  = 2    // 周期检测要求至少覆盖的完整周期数
+	seasonalityMinACF  = 0.5  // 周期判定的自相关阈值
+	flatSlopeTolerance = 1e-9 // 斜率视为持平的阈值
+)
+
+// Findings 确定性检测结果，作为 LLM 报告必须引用的事实依据
+type Findings struct {
+	TimeColumn  string         `json:"time_column,omitempty"` // 时间索引列
+	Outliers    []Outlier      `json:"outliers,omitempty"`    // 离群点
+	Trends      []Trend        `json:"trends,omitempty"`      // 线性趋势
+	Changes     []PeriodChange `json:"changes,omitempty"`     // 环比变化
+	Seasonality []Seasonality  `json:"seasonality,omitempty"` // 周期性
+}
+
+// Outlier 离群点
+type Outlier struct {
+	Column   string   `json:"column"`    // 列名
+	RowIndex int      `json:"row_index"` // 行号（从0开始）
+	Label    string   `json:"label"`     // 行标签（时间或维度值）
+	Value    float64  `json:"value"`     // 取值
+	ZScore   float64  `json:"z_score"`   // 标准分
+	Methods  []string `json:"methods"`   // 命中的检测方法：iqr / zscore
+}
+
+// Trend 线性趋势
+type Trend struct {
+	Column      string  `json:"column"`      // 指标列
+	Points      int     `json:"points"`      // 数据点数
+	Slope       float64 `json:"slope"`       // 每期变化量
+	Intercept   float64 `json:"intercept"`   // 截距
+	R2          float64 `json:"r2"`          // 拟合优度
+	PValue      float64 `json:"p_value"`     // 斜率显著性 p 值
+	Significant bool    `json:"significant"` // 是否显著（p < 0.05）
+	Direction   string  `json:"direction"`   // up / down / flat
+}
+
+// PeriodChange 环比变化（最后一期对比上一期）
+type PeriodChange struct {
+	Column    string  `json:"column"`     // 指标列
+	From      string  `json:"from"`       // 上一期
+	To        string  `json:"to"`         // 当期
+	Previous  float64 `json:"previous"`   // 上一期取值
+	Current   float64 `json:"current"`    // 当期取值
+	Change    float64 `json:"change"`     // 变化量
+	ChangePct float64 `json:"change_pct"` // 变化率（%），上一期为0时为0
+}
+
+// Seasonality 周期性
+type Seasonality struct {
+	Column   string  `json:"column"`   // 指标列
+	Period   int     `json:"period"`   // 周期长度（期数）
+	Strength float64 `json:"strength"` // 去趋势后的自相关系数
+}
+
+// timePoint 按时间聚合后的数据点
+type timePoint struct {
+	t     time.Time
+	label string
+	value float64
+}
+
+// DetectFindings 对解析数据运行离群、趋势、环比与周期检测
+func DetectFindings(data *ParsedData) *Findings {
+	findings := &Findings{}
+	if data == nil || data.RowCount == 0 {
+		return findings
+	}
+
+	measures := data.ColumnsOfType(ColumnTypeInt, ColumnTypeFloat)
+	timeCols := data.ColumnsOfType(ColumnTypeDate)
+	labelCol := ""
+	if len(timeCols) > 0 {
+		labelCol = timeCols[0]
+		findings.TimeColumn = labelCol
+	} else if dims := data.ColumnsOfType(ColumnTypeCategory, ColumnTypeText); len(dims) > 0 {
+		labelCol = dims[0]
+	}
+
+	for _, col := range measures {
+		findings.Outliers = append(findings.Outliers, detectOutliers(data, col, labelCol)...)
+	}
+
+	if findings.TimeColumn == "" {
+		return findings
+	}
+
+	for _, col := range measures {
+		series := buildTimeSeries(data, findings.TimeColumn, col)
+		if len(series) < 2 {
+			continue
+		}
+		values := make([]float64, len(series))
+		for i, p := range series {
+			values[i] = p.value
+		}
+
+		if trend, ok := detectTrend(col, values); ok {
+			findings.Trends = append(findings.Trends, trend)
+		}
+		findings.Changes = append(findings.Changes, periodChange(col, series))
+		if season, ok := detectSeasonality(col, values); ok {
+			findings.Seasonality = append(findings.Seasonality, season)
+		}
+	}
+
+	return findings
+}
+
+// detectOutliers 同时使用 IQR 围栏与 z-score 判定离群点
+func detectOutliers(data *ParsedData, col, labelCol string) []Outlier {
+	idx := make([]int, 0, data.RowCount)
+	values := make([]float64, 0, data.RowCount)
+	for i, row := range data.Rows {
+		if isNull(row[col]) {
+			continue
+		}
+		if num, ok := toFloat(row[col]); ok {
+			idx = append(idx, i)
+			values = append(values, num)
+		}
+	}
+	if len(values) < 4 {
+		return nil
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	lower, upper := q1-iqrFactor*iqr, q3+iqrFactor*iqr
+
+	mean, std := meanStd(values)
+
+	outliers := make([]Outlier, 0)
+	for i, v := range values {
+		methods := make([]string, 0, 2)
+		if iqr > 0 && (v < lower || v > upper) {
+			methods = append(methods, "iqr")
+		}
+		z := 0.0
+		if std > 0 {
+			z = (v - mean) / std
+		}
+		if math.Abs(z) > zScoreThreshold {
+			methods = append(methods, "zscore")
+		}
+		if len(methods) == 0 {
+			continue
+		}
+
+		label := ""
+		if labelCol != "" {
+			label = fmt.Sprintf("%v", data.Rows[idx[i]][labelCol])
+		}
+		outliers = append(outliers, Outlier{
+			Column:   col,
+			RowIndex: idx[i],
+			Label:    label,
+			Value:    v,
+			ZScore:   z,
+			Methods:  methods,
+		})
+	}
+
+	// 偏离程度最大的优先
+	sort.Slice(outliers, func(i, j int) bool {
+		return math.Abs(outliers[i].ZScore) > math.Abs(outliers[j].ZScore)
+	})
+	if len(outliers) > maxOutliersPerCol {
+		outliers = outliers[:maxOutliersPerCol]
+	}
+	return outliers
+}
+
+// buildTimeSeries 按时间列排序，同一时间点的多行取和
+func buildTimeSeries(data *ParsedData, timeCol, col string) []timePoint {
+	byKey := make(map[string]*timePoint)
+	for _, row := range data.Rows {
+		t, ok := toDate(row[timeCol])
+		if !ok {
+			continue
+		}
+		num, ok := toFloat(row[col])
+		if !ok || isNull(row[col]) {
+			continue
+		}
+		key := fmt.Sprintf("%v", row[timeCol])
+		if p, exists := byKey[key]; exists {
+			p.value += num
+			continue
+		}
+		byKey[key] = &timePoint{t: t, label: key, value: num}
+	}
+
+	series := make([]timePoint, 0, len(byKey))
+	for _, p := range byKey {
+		series = append(series, *p)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].t.Before(series[j].t)
+	})
+	return series
+}
+
+// detectTrend 对序列做最小二乘线性回归，并以 t 检验判断斜率显著性
+func detectTrend(col string, values []float64) (Trend, bool) {
+	n := len(values)
+	if n < minTrendPoints {
+		return Trend{}, false
+	}
+
+	slope, intercept, r2, se := linearFit(values)
+	trend := Trend{
+		Column:    col,
+		Points:    n,
+		Slope:     slope,
+		Intercept: intercept,
+		R2:        r2,
+		PValue:    1,
+		Direction: "flat",
+	}
+
+	df := float64(n - 2)
+	switch {
+	case se > 0 && df > 0:
+		trend.PValue = studentTTwoTailed(slope/se, df)
+	case se == 0 && math.Abs(slope) > flatSlopeTolerance:
+		// 完全线性的序列
+		trend.PValue = 0
+	}
+	trend.Significant = trend.PValue < trendAlpha
+
+	if trend.Significant {
+		if slope > 0 {
+			trend.Direction = "up"
+		} else if slope < 0 {
+			trend.Direction = "down"
+		}
+	}
+	return trend, true
+}
+
+// periodChange 计算最后一期相对上一期的变化
+func periodChange(col string, series []timePoint) PeriodChange {
+	prev, cur := series[len(series)-2], series[len(series)-1]
+	change := PeriodChange{
+		Column:   col,
+		From:     prev.label,
+		To:       cur.label,
+		Previous: prev.value,
+		Current:  cur.value,
+		Change:   cur.value - prev.value,
+	}
+	if prev.value != 0 {
+		change.ChangePct = change.Change / math.Abs(prev.value) * 100
+	}
+	return change
+}
+
+// detectSeasonality 在去趋势后的残差上寻找自相关最强的周期
+func detectSeasonality(col string, values []float64) (Seasonality, bool) {
+	n := len(values)
+	maxLag := n / minSeasonalCycles
+	if maxLag < 2 {
+		return Seasonality{}, false
+	}
+
+	slope, intercept, _, _ := linearFit(values)
+	residuals := make([]float64, n)
+	for i, v := range values {
+		residuals[i] = v - (slope*float64(i) + intercept)
+	}
+
+	best := Seasonality{Column: col}
+	for lag := 2; lag <= maxLag; lag++ {
+		acf := autocorrelation(residuals, lag)
+		if acf > best.Strength {
+			best.Period = lag
+			best.Strength = acf
+		}
+	}
+	if best.Strength < seasonalityMinACF {
+		return Seasonality{}, false
+	}
+	return best, true
+}
+
+// linearFit 以下标为自变量做最小二乘拟合，返回斜率、截距、R² 与斜率标准误
+func linearFit(values []float64) (slope, intercept, r2, se float64) {
+	n := float64(len(values))
+	var sumX, sumY float64
+	for i, v := range values {
+		sumX += float64(i)
+		sumY += v
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for i, v := range values {
+		dx, dy := float64(i)-meanX, v-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, meanY, 0, 0
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+
+	var sse float64
+	for i, v := range values {
+		r := v - (slope*float64(i) + intercept)
+		sse += r * r
+	}
+	if syy > 0 {
+		r2 = 1 - sse/syy
+	}
+	if len(values) > 2 {
+		se = math.Sqrt(sse/(n-2)) / math.Sqrt(sxx)
+	}
+	return slope, intercept, r2, se
+}
+
+func autocorrelation(values []float64, lag int) float64 {
+	mean, _ := meanStd(values)
+	var num, den float64
+	for i, v := range values {
+		d := v - mean
+		den += d * d
+		if i+lag < len(values) {
+			num += d * (values[i+lag] - mean)
+		}
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// quantile 线性插值分位数，sorted 需已升序
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// studentTTwoTailed 返回自由度为 df 的 t 分布双尾 p 值
+func studentTTwoTailed(t, df float64) float64 {
+	x := df / (df + t*t)
+	return regIncBeta(df/2, 0.5, x)
+}
+
+// regIncBeta 正则化不完全 Beta 函数 I_x(a, b)，连分式展开
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+func betaCF(a, b, x float64) float64 {
+	const (
+		maxIter = 200
+		eps     = 3e-14
+		fpmin   = 1e-300
+	)
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1.0, 1-qab*x/qap
+	if math.Abs(d) < fpmin {
+		d = fpmin
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		m2 := float64(2 * m)
+		aa := float64(m) * (b - float64(m)) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + float64(m)) * (qab + float64(m)) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return h
+}
+
+// FormatFindings 将检测结果格式化为提示词文本
+func FormatFindings(f *Findings) string {
+	if f == nil {
+		return "无\n"
+	}
+	var sb strings.Builder
+	for _, t := range f.Trends {
+		sb.WriteString(fmt.Sprintf("- [趋势] %s: 方向 %s, 每期变化 %.4f, R²=%.3f, p=%.4f, 显著=%v (%d 期)\n",
+			t.Column, t.Direction, t.Slope, t.R2, t.PValue, t.Significant, t.Points))
+	}
+	for _, c := range f.Changes {
+		sb.WriteString(fmt.Sprintf("- [环比] %s: %s→%s, %.2f→%.2f, 变化 %.2f (%.2f%%)\n",
+			c.Column, c.From, c.To, c.Previous, c.Current, c.Change, c.ChangePct))
+	}
+	for _, s := range f.Seasonality {
+		sb.WriteString(fmt.Sprintf("- [周期] %s: 周期 %d 期, 自相关 %.3f\n", s.Column, s.Period, s.Strength))
+	}
+	for _, o := range f.Outliers {
+		sb.WriteString(fmt.Sprintf("- [异常] %s 第 %d 行 (%s): 取值 %.2f, z=%.2f, 方法 %s\n",
+			o.Column, o.RowIndex, o.Label, o.Value, o.ZScore, strings.Join(o.Methods, "+")))
+	}
+	if sb.Len() == 0 {
+		return "未检测到显著趋势或异常\n"
+	}
+	return sb.String()
+}
@@ -3,6 +3,7 @@ package analyst_tools
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
@@ -10,35 +11,37 @@ import (
 
 // AnalysisResult 分析结果
 type AnalysisResult struct {
-	TextAnalysis string      `json:"text_analysis"` // 文字分析报告
-	ChartConfig  interface{} `json:"chart_config"`  // ECharts配置
-	Statistics   *Statistics `json:"statistics"`    // 统计数据
+	TextAnalysis string               `json:"text_analysis"`        // 文字分析报告
+	ChartConfig  interface{}          `json:"chart_config"`         // ECharts配置
+	Chart        *ChartRecommendation `json:"chart_recommendation"` // 图表推荐及理由
+	Statistics   *Statistics          `json:"statistics"`           // 统计数据
+	Findings     *Findings            `json:"findings"`             // 趋势与异常检测结果
 }
 
-// Statistics 统计数据
+// Statistics 统计数据，顶层字段为主指标列的统计，Columns 为逐列画像
 type Statistics struct {
-	Mean      float64   `json:"mean"`      // 均值
-	Median    float64   `json:"median"`    // 中位数
-	StdDev    float64   `json:"std_dev"`   // 标准差
-	Min       float64   `json:"min"`       // 最小值
-	Max       float64   `json:"max"`       // 最大值
-	Count     int       `json:"count"`     // 总数
-	Quartiles []float64 `json:"quartiles"` // 四分位数
+	PrimaryColumn string           `json:"primary_column,omitempty"` // 主指标列
+	Mean          float64          `json:"mean"`                     // 均值
+	Median        float64          `json:"median"`                   // 中位数
+	StdDev        float64          `json:"std_dev"`                  // 标准差
+	Min           float64          `json:"min"`                      // 最小值
+	Max           float64          `json:"max"`                      // 最大值
+	Count         int              `json:"count"`                    // 总数
+	Quartiles     []float64        `json:"quartiles"`                // 四分位数
+	Columns       []*ColumnProfile `json:"columns,omitempty"`        // 逐列画像
 }
 
 // GenerateTextAnalysis 生成文字分析报告
-func GenerateTextAnalysis(ctx context.Context, data *ParsedData, stats *Statistics, cm model.BaseChatModel) (string, error) {
+func GenerateTextAnalysis(ctx context.Context, data *ParsedData, stats *Statistics, findings *Findings, cm model.BaseChatModel) (string, error) {
 	// 构造提示词
 	prompt := fmt.Sprintf(`你是一位专业的数据分析师。请基于以下数据和统计信息，生成一份简洁的分析报告。
 
 数据行数: %d
 列名: %v
-统计信息:
-- 均值: %.2f
-- 中位数: %.2f
-- 标准差: %.2f
-- 范围: [%.2f, %.2f]
-
+逐列画像:
+%s
+检测结果（由程序计算）:
+%s
 数据样例:
 %s
 
@@ -46,14 +49,13 @@ func GenerateTextAnalysis(ctx context.Context, data *ParsedData, stats *Statisti
 1. 数据总体概况
 2. 关键指标解读
 3. 发现的趋势或异常
+
+要求：报告中涉及趋势、环比、周期和异常的数字必须直接引用上述检测结果，不得自行计算或编造；检测结果中没有的结论不要给出。
 `,
 		data.RowCount,
 		data.Columns,
-		stats.Mean,
-		stats.Median,
-		stats.StdDev,
-		stats.Min,
-		stats.Max,
+		FormatColumnProfiles(stats.Columns),
+		FormatFindings(findings),
 		data.SampleRows,
 	)
 
@@ -70,22 +72,38 @@ func GenerateTextAnalysis(ctx context.Context, data *ParsedData, stats *Statisti
 	return response.Content, nil
 }
 
-// GenerateChartConfig 生成ECharts配置
-func GenerateChartConfig(data *ParsedData) (interface{}, error) {
+// GenerateChartConfig 生成ECharts配置，hint 为调用方的图表偏好（可为空）
+func GenerateChartConfig(data *ParsedData, hint *ChartHint) (map[string]interface{}, *ChartRecommendation, error) {
 	// 推荐图表类型
-	chartType := RecommendChartType(data)
+	rec := RecommendChartType(data, hint)
+
+	// 根据推荐结果生成配置
+	chart, err := BuildChart(data, rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chart, rec, nil
+}
 
-	// 根据图表类型生成配置
-	switch chartType {
-	case "bar":
-		return GenerateBarChart(data)
-	case "line":
-		return GenerateLineChart(data)
-	case "pie":
-		return GeneratePieChart(data)
-	case "table":
-		return GenerateTableChart(data)
-	default:
-		return GenerateTableChart(data)
+// FormatColumnProfiles 将逐列画像格式化为提示词文本
+func FormatColumnProfiles(profiles []*ColumnProfile) string {
+	var sb strings.Builder
+	for _, p := range profiles {
+		sb.WriteString(fmt.Sprintf("- %s (%s): 非空 %d, 空值 %d, 基数 %d", p.Name, p.Type, p.Count, p.NullCount, p.Cardinality))
+		switch {
+		case p.Numeric != nil:
+			sb.WriteString(fmt.Sprintf(", 均值 %.2f, 中位数 %.2f, 标准差 %.2f, 范围 [%.2f, %.2f], 总和 %.2f",
+				p.Numeric.Mean, p.Numeric.Median, p.Numeric.StdDev, p.Numeric.Min, p.Numeric.Max, p.Numeric.Sum))
+		case p.MinDate != "":
+			sb.WriteString(fmt.Sprintf(", 时间范围 [%s, %s]", p.MinDate, p.MaxDate))
+		case len(p.TopValues) > 0:
+			top := make([]string, 0, len(p.TopValues))
+			for _, v := range p.TopValues {
+				top = append(top, fmt.Sprintf("%s(%d)", v.Value, v.Count))
+			}
+			sb.WriteString(", 高频值 " + strings.Join(top, ", "))
+		}
+		sb.WriteString("\n")
 	}
+	return sb.String()
 }
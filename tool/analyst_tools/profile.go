@@ -0,0 +1,320 @@
+package analyst_tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType 列的推断类型
+type ColumnType string
+
+const (
+	ColumnTypeInt      ColumnType = "int"
+	ColumnTypeFloat    ColumnType = "float"
+	ColumnTypeDate     ColumnType = "date"
+	ColumnTypeBool     ColumnType = "bool"
+	ColumnTypeCategory ColumnType = "category"
+	ColumnTypeText     ColumnType = "text"
+)
+
+const (
+	topKValues          = 5    // 分类列保留的高频值个数
+	maxCategoryDistinct = 50   // 分类列最大基数
+	maxCategoryRatio    = 0.5  // 分类列基数占比上限
+	maxCategoryAvgLen   = 32.0 // 分类列平均长度上限
+)
+
+// 常见的日期时间格式
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"2006-01",
+	"2006/01",
+}
+
+// ValueCount 分类值及其出现次数
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// NumericStats 数值列统计
+type NumericStats struct {
+	Mean      float64   `json:"mean"`      // 均值
+	Median    float64   `json:"median"`    // 中位数
+	StdDev    float64   `json:"std_dev"`   // 标准差
+	Min       float64   `json:"min"`       // 最小值
+	Max       float64   `json:"max"`       // 最大值
+	Sum       float64   `json:"sum"`       // 总和
+	Quartiles []float64 `json:"quartiles"` // 四分位数
+}
+
+// ColumnProfile 单列画像
+type ColumnProfile struct {
+	Name        string        `json:"name"`                 // 列名
+	Type        ColumnType    `json:"type"`                 // 推断类型
+	Count       int           `json:"count"`                // 非空值数量
+	NullCount   int           `json:"null_count"`           // 空值数量
+	Cardinality int           `json:"cardinality"`          // 不同值个数
+	Numeric     *NumericStats `json:"numeric,omitempty"`    // 数值统计（int/float）
+	TopValues   []ValueCount  `json:"top_values,omitempty"` // 高频值（category/bool）
+	MinDate     string        `json:"min_date,omitempty"`   // 最早日期（date）
+	MaxDate     string        `json:"max_date,omitempty"`   // 最晚日期（date）
+}
+
+// InferColumnTypes 推断每一列的类型并写入 ParsedData.ColumnTypes
+func InferColumnTypes(data *ParsedData) {
+	data.ColumnTypes = make(map[string]ColumnType, len(data.Columns))
+	for _, col := range data.Columns {
+		data.ColumnTypes[col] = inferColumnType(data.Rows, col)
+	}
+}
+
+// inferColumnType 所有非空值都满足某类型时才判定为该类型，按 bool > int > float > date > category/text 的顺序尝试
+func inferColumnType(rows []map[string]interface{}, col string) ColumnType {
+	isBool, isInt, isFloat, isDate := true, true, true, true
+	nonNull := 0
+	totalLen := 0
+	distinct := make(map[string]struct{})
+
+	for _, row := range rows {
+		val := row[col]
+		if isNull(val) {
+			continue
+		}
+		nonNull++
+		str := fmt.Sprintf("%v", val)
+		totalLen += len([]rune(str))
+		distinct[str] = struct{}{}
+
+		if _, ok := toBool(val); !ok {
+			isBool = false
+		}
+		num, ok := toFloat(val)
+		if !ok {
+			isInt, isFloat = false, false
+		} else if num != math.Trunc(num) {
+			isInt = false
+		}
+		if _, ok := toDate(val); !ok {
+			isDate = false
+		}
+	}
+
+	switch {
+	case nonNull == 0:
+		return ColumnTypeText
+	case isBool:
+		return ColumnTypeBool
+	case isInt:
+		return ColumnTypeInt
+	case isFloat:
+		return ColumnTypeFloat
+	case isDate:
+		return ColumnTypeDate
+	}
+
+	avgLen := float64(totalLen) / float64(nonNull)
+	ratio := float64(len(distinct)) / float64(nonNull)
+	if len(distinct) <= maxCategoryDistinct && (ratio <= maxCategoryRatio || nonNull <= 10) && avgLen <= maxCategoryAvgLen {
+		return ColumnTypeCategory
+	}
+	return ColumnTypeText
+}
+
+// ProfileColumns 对所有列进行画像
+func ProfileColumns(data *ParsedData) []*ColumnProfile {
+	if data.ColumnTypes == nil {
+		InferColumnTypes(data)
+	}
+
+	profiles := make([]*ColumnProfile, 0, len(data.Columns))
+	for _, col := range data.Columns {
+		profiles = append(profiles, profileColumn(data, col, data.ColumnTypes[col]))
+	}
+	return profiles
+}
+
+func profileColumn(data *ParsedData, col string, typ ColumnType) *ColumnProfile {
+	p := &ColumnProfile{Name: col, Type: typ}
+
+	counts := make(map[string]int)
+	values := make([]float64, 0, data.RowCount)
+	var minDate, maxDate time.Time
+
+	for _, row := range data.Rows {
+		val := row[col]
+		if isNull(val) {
+			p.NullCount++
+			continue
+		}
+		p.Count++
+		counts[fmt.Sprintf("%v", val)]++
+
+		switch typ {
+		case ColumnTypeInt, ColumnTypeFloat:
+			if num, ok := toFloat(val); ok {
+				values = append(values, num)
+			}
+		case ColumnTypeDate:
+			if t, ok := toDate(val); ok {
+				if minDate.IsZero() || t.Before(minDate) {
+					minDate = t
+				}
+				if maxDate.IsZero() || t.After(maxDate) {
+					maxDate = t
+				}
+			}
+		}
+	}
+	p.Cardinality = len(counts)
+
+	switch typ {
+	case ColumnTypeInt, ColumnTypeFloat:
+		p.Numeric = computeNumericStats(values)
+	case ColumnTypeDate:
+		if !minDate.IsZero() {
+			p.MinDate = minDate.Format("2006-01-02 15:04:05")
+			p.MaxDate = maxDate.Format("2006-01-02 15:04:05")
+		}
+	case ColumnTypeCategory, ColumnTypeBool:
+		p.TopValues = topValues(counts, topKValues)
+	}
+
+	return p
+}
+
+// computeNumericStats 计算数值序列的描述统计
+func computeNumericStats(values []float64) *NumericStats {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	stats := &NumericStats{
+		Min: sorted[0],
+		Max: sorted[len(sorted)-1],
+	}
+
+	for _, v := range values {
+		stats.Sum += v
+	}
+	stats.Mean = stats.Sum / float64(len(values))
+
+	stats.Median = quantile(sorted, 0.5)
+
+	variance := 0.0
+	for _, v := range values {
+		diff := v - stats.Mean
+		variance += diff * diff
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(values)))
+
+	// 与异常检测使用同一线性插值分位数，两处的四分位数保持一致
+	stats.Quartiles = []float64{sorted[0], quantile(sorted, 0.25), stats.Median, quantile(sorted, 0.75), sorted[len(sorted)-1]}
+
+	return stats
+}
+
+// topValues 按出现次数降序取前 k 个值
+func topValues(counts map[string]int, k int) []ValueCount {
+	list := make([]ValueCount, 0, len(counts))
+	for v, c := range counts {
+		list = append(list, ValueCount{Value: v, Count: c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > k {
+		list = list[:k]
+	}
+	return list
+}
+
+// ColumnsOfType 按列顺序返回指定类型的列名
+func (d *ParsedData) ColumnsOfType(types ...ColumnType) []string {
+	if d.ColumnTypes == nil {
+		InferColumnTypes(d)
+	}
+	cols := make([]string, 0)
+	for _, col := range d.Columns {
+		for _, t := range types {
+			if d.ColumnTypes[col] == t {
+				cols = append(cols, col)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func isNull(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	if s, ok := val.(string); ok {
+		s = strings.TrimSpace(s)
+		return s == "" || strings.EqualFold(s, "null")
+	}
+	return false
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return num, err == nil
+	}
+	return 0, false
+}
+
+func toBool(val interface{}) (bool, bool) {
+	switch v := val.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func toDate(val interface{}) (time.Time, bool) {
+	s, ok := val.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -3,7 +3,6 @@ package analyst_tools
 import (
 	"encoding/json"
 	"fmt"
-	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,26 +10,37 @@ import (
 
 // ParsedData 解析后的结构化数据
 type ParsedData struct {
-	Columns    []string                 `json:"columns"`     // 列名
-	Rows       []map[string]interface{} `json:"rows"`        // 数据行
-	RowCount   int                      `json:"row_count"`   // 行数
-	SampleRows string                   `json:"sample_rows"` // 样本行（用于展示）
+	Columns     []string                 `json:"columns"`      // 列名
+	Rows        []map[string]interface{} `json:"rows"`         // 数据行
+	RowCount    int                      `json:"row_count"`    // 行数
+	SampleRows  string                   `json:"sample_rows"`  // 样本行（用于展示）
+	ColumnTypes map[string]ColumnType    `json:"column_types"` // 列类型推断结果
 }
 
 // ParseSQLResult 解析SQL结果字符串
 func ParseSQLResult(sqlResult string) (*ParsedData, error) {
+	var data *ParsedData
+	var err error
+
 	// 尝试解析为JSON格式
 	var jsonData []map[string]interface{}
-	if err := json.Unmarshal([]byte(sqlResult), &jsonData); err == nil {
-		return parseFromJSON(jsonData)
+	if jsonErr := json.Unmarshal([]byte(sqlResult), &jsonData); jsonErr == nil {
+		data, err = parseFromJSON(jsonData, jsonColumnOrder(sqlResult))
+	} else {
+		// 如果不是JSON，尝试解析为表格格式
+		data, err = parseFromTable(sqlResult)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// 如果不是JSON，尝试解析为表格格式
-	return parseFromTable(sqlResult)
+	// 类型推断
+	InferColumnTypes(data)
+	return data, nil
 }
 
-// parseFromJSON 从JSON数组解析
-func parseFromJSON(jsonData []map[string]interface{}) (*ParsedData, error) {
+// parseFromJSON 从JSON数组解析，ordered 为首行的原始列顺序，缺失时按字母序
+func parseFromJSON(jsonData []map[string]interface{}, ordered []string) (*ParsedData, error) {
 	if len(jsonData) == 0 {
 		return &ParsedData{
 			Columns:    []string{},
@@ -41,11 +51,14 @@ func parseFromJSON(jsonData []map[string]interface{}) (*ParsedData, error) {
 	}
 
 	// 提取列名
-	columns := make([]string, 0)
-	for col := range jsonData[0] {
-		columns = append(columns, col)
+	columns := ordered
+	if len(columns) != len(jsonData[0]) {
+		columns = make([]string, 0, len(jsonData[0]))
+		for col := range jsonData[0] {
+			columns = append(columns, col)
+		}
+		sort.Strings(columns)
 	}
-	sort.Strings(columns)
 
 	// 生成样本行（前3行）
 	sampleRows := ""
@@ -70,6 +83,35 @@ func parseFromJSON(jsonData []map[string]interface{}) (*ParsedData, error) {
 	}, nil
 }
 
+// jsonColumnOrder 读取JSON数组首个对象的键顺序，SELECT 的列顺序即维度/指标的书写顺序
+func jsonColumnOrder(raw string) []string {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+
+	columns := make([]string, 0)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil
+		}
+		columns = append(columns, key)
+	}
+	return columns
+}
+
 // parseFromTable 从表格文本解析
 func parseFromTable(tableText string) (*ParsedData, error) {
 	lines := strings.Split(strings.TrimSpace(tableText), "\n")
@@ -123,7 +165,7 @@ func parseFromTable(tableText string) (*ParsedData, error) {
 	}, nil
 }
 
-// ComputeStatistics 计算统计数据
+// ComputeStatistics 计算统计数据：逐列画像，并以第一个数值列作为主指标
 func ComputeStatistics(data *ParsedData) (*Statistics, error) {
 	if data.RowCount == 0 {
 		return &Statistics{
@@ -131,227 +173,44 @@ func ComputeStatistics(data *ParsedData) (*Statistics, error) {
 		}, nil
 	}
 
-	// 找第一个数值列
-	var values []float64
-	for _, col := range data.Columns {
-		values = make([]float64, 0, data.RowCount)
-		for _, row := range data.Rows {
-			if val, ok := row[col]; ok {
-				if num, ok := val.(float64); ok {
-					values = append(values, num)
-				} else if numStr, ok := val.(string); ok {
-					if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-						values = append(values, num)
-					}
-				}
-			}
-		}
-		// 如果找到数值列，停止搜索
-		if len(values) > 0 {
-			break
-		}
-	}
-
-	if len(values) == 0 {
-		return &Statistics{
-			Count: data.RowCount,
-		}, nil
-	}
-
-	// 排序
-	sorted := make([]float64, len(values))
-	copy(sorted, values)
-	sort.Float64s(sorted)
-
-	// 计算统计量
+	profiles := ProfileColumns(data)
 	stats := &Statistics{
-		Count: len(values),
-		Min:   sorted[0],
-		Max:   sorted[len(sorted)-1],
-	}
-
-	// 均值
-	sum := 0.0
-	for _, v := range values {
-		sum += v
-	}
-	stats.Mean = sum / float64(len(values))
-
-	// 中位数
-	mid := len(sorted) / 2
-	if len(sorted)%2 == 0 {
-		stats.Median = (sorted[mid-1] + sorted[mid]) / 2
-	} else {
-		stats.Median = sorted[mid]
-	}
-
-	// 标准差
-	variance := 0.0
-	for _, v := range values {
-		diff := v - stats.Mean
-		variance += diff * diff
-	}
-	stats.StdDev = math.Sqrt(variance / float64(len(values)))
-
-	// 四分位数
-	q1 := sorted[len(sorted)/4]
-	q3 := sorted[len(sorted)*3/4]
-	stats.Quartiles = []float64{sorted[0], q1, stats.Median, q3, sorted[len(sorted)-1]}
-
-	return stats, nil
-}
-
-// RecommendChartType 推荐图表类型
-func RecommendChartType(data *ParsedData) string {
-	if data.RowCount == 0 {
-		return "table"
-	}
-
-	// 如果行数很少，使用表格
-	if data.RowCount <= 5 {
-		return "table"
+		Count:   data.RowCount,
+		Columns: profiles,
 	}
 
-	// 如果有时间序列列，使用折线图
-	for _, col := range data.Columns {
-		colLower := strings.ToLower(col)
-		if strings.Contains(colLower, "time") || strings.Contains(colLower, "date") {
-			return "line"
-		}
-	}
-
-	// 如果有分类列+数值列，使用柱状图
-	hasCategory := false
-	hasNumeric := false
-	for _, row := range data.Rows {
-		for _, val := range row {
-			if _, ok := val.(string); ok {
-				hasCategory = true
-			}
-			if _, ok := val.(float64); ok {
-				hasNumeric = true
-			}
-		}
-		if hasCategory && hasNumeric {
-			return "bar"
+	// 主指标：第一个数值列
+	for _, p := range profiles {
+		if p.Numeric == nil {
+			continue
 		}
+		stats.PrimaryColumn = p.Name
+		stats.Count = p.Count
+		stats.Mean = p.Numeric.Mean
+		stats.Median = p.Numeric.Median
+		stats.StdDev = p.Numeric.StdDev
+		stats.Min = p.Numeric.Min
+		stats.Max = p.Numeric.Max
+		stats.Quartiles = p.Numeric.Quartiles
+		break
 	}
 
-	// 默认使用柱状图
-	return "bar"
+	return stats, nil
 }
 
 // GenerateBarChart 生成柱状图配置
 func GenerateBarChart(data *ParsedData) (map[string]interface{}, error) {
-	if len(data.Columns) < 2 {
-		return GenerateTableChart(data)
-	}
-
-	xData := make([]interface{}, 0, data.RowCount)
-	yData := make([]interface{}, 0, data.RowCount)
-
-	xCol := data.Columns[0]
-	yCol := data.Columns[1]
-
-	for _, row := range data.Rows {
-		xData = append(xData, row[xCol])
-		yData = append(yData, row[yCol])
-	}
-
-	return map[string]interface{}{
-		"title": map[string]interface{}{
-			"text": "数据分析柱状图",
-		},
-		"tooltip": map[string]interface{}{},
-		"xAxis": map[string]interface{}{
-			"type": "category",
-			"data": xData,
-		},
-		"yAxis": map[string]interface{}{
-			"type": "value",
-		},
-		"series": []map[string]interface{}{
-			{
-				"name": yCol,
-				"type": "bar",
-				"data": yData,
-			},
-		},
-	}, nil
+	return BuildChart(data, RecommendChartType(data, &ChartHint{Type: ChartTypeBar}))
 }
 
 // GenerateLineChart 生成折线图配置
 func GenerateLineChart(data *ParsedData) (map[string]interface{}, error) {
-	if len(data.Columns) < 2 {
-		return GenerateTableChart(data)
-	}
-
-	xData := make([]interface{}, 0, data.RowCount)
-	yData := make([]interface{}, 0, data.RowCount)
-
-	xCol := data.Columns[0]
-	yCol := data.Columns[1]
-
-	for _, row := range data.Rows {
-		xData = append(xData, row[xCol])
-		yData = append(yData, row[yCol])
-	}
-
-	return map[string]interface{}{
-		"title": map[string]interface{}{
-			"text": "数据趋势折线图",
-		},
-		"tooltip": map[string]interface{}{
-			"trigger": "axis",
-		},
-		"xAxis": map[string]interface{}{
-			"type": "category",
-			"data": xData,
-		},
-		"yAxis": map[string]interface{}{
-			"type": "value",
-		},
-		"series": []map[string]interface{}{
-			{
-				"name": yCol,
-				"type": "line",
-				"data": yData,
-			},
-		},
-	}, nil
+	return BuildChart(data, RecommendChartType(data, &ChartHint{Type: ChartTypeLine}))
 }
 
 // GeneratePieChart 生成饼图配置
 func GeneratePieChart(data *ParsedData) (map[string]interface{}, error) {
-	if len(data.Columns) < 2 {
-		return GenerateTableChart(data)
-	}
-
-	seriesData := make([]map[string]interface{}, 0, data.RowCount)
-	nameCol := data.Columns[0]
-	valueCol := data.Columns[1]
-
-	for _, row := range data.Rows {
-		seriesData = append(seriesData, map[string]interface{}{
-			"name":  row[nameCol],
-			"value": row[valueCol],
-		})
-	}
-
-	return map[string]interface{}{
-		"title": map[string]interface{}{
-			"text": "数据分布饼图",
-		},
-		"tooltip": map[string]interface{}{
-			"trigger": "item",
-		},
-		"series": []map[string]interface{}{
-			{
-				"type": "pie",
-				"data": seriesData,
-			},
-		},
-	}, nil
+	return BuildChart(data, RecommendChartType(data, &ChartHint{Type: ChartTypePie}))
 }
 
 // GenerateTableChart 生成表格配置
@@ -0,0 +1,509 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/sql_guard"
+	"go-agent/tool/sql_tools"
+	"go-agent/tool/storage"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	approvalPrefix   = "approval"
+	pendingIndexKey  = "approval:pending" // 待审批请求的 ZSET 索引，score 为创建时间
+	retentionTTL     = 24 * time.Hour     // 已结束的审批保留时间，便于申请人查询执行结果
+	defaultTTL       = 30 * time.Minute   // 待审批请求的默认过期时间
+	defaultSweepTick = time.Minute
+)
+
+var (
+	ErrNotFound      = errors.New("approval request not found")
+	ErrNotPending    = errors.New("approval request is not pending")
+	ErrForbidden     = errors.New("approver not allowed")
+	ErrDuplicateVote = errors.New("approver has already approved")
+	ErrBlocked       = errors.New("edited sql blocked by guard")
+	ErrConflict      = errors.New("approval request modified concurrently")
+)
+
+// Status 审批请求状态
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusRejected Status = "rejected"
+	StatusExpired  Status = "expired"
+)
+
+// Vote 一次审批操作
+type Vote struct {
+	Approver string    `json:"approver"`
+	Role     string    `json:"role,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	SQL      string    `json:"sql,omitempty"` // 审批人修改后的 SQL
+	At       time.Time `json:"at"`
+}
+
+// ResultMessage 代为执行后的结果消息
+type ResultMessage struct {
+	Event   string `json:"event"`
+	Content string `json:"content"`
+}
+
+// Request 一条待审批的 SQL 中断
+type Request struct {
+	ID            string `json:"id"`
+	InterruptID   string `json:"interrupt_id"` // 同一节点再次中断时 ID 不变，因此单独生成审批 ID
+	SessionID     string `json:"session_id"`
+	CheckPointID  string `json:"checkpoint_id"`
+	Requester     string `json:"requester"`
+	OriginalQuery string `json:"original_query,omitempty"`
+
+	SQL     string                    `json:"sql"`
+	Type    sql_guard.StatementType   `json:"type"`
+	Risk    sql_guard.RiskLevel       `json:"risk"`
+	Reasons []string                  `json:"reasons,omitempty"`
+	Tables  []string                  `json:"tables,omitempty"`
+	Preview *sql_tools.ExplainPreview `json:"preview,omitempty"`
+
+	Required  int             `json:"required"` // 需要的审批人数
+	Approvals []Vote          `json:"approvals,omitempty"`
+	Rejection *Vote           `json:"rejection,omitempty"`
+	Status    Status          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Result    []ResultMessage `json:"result,omitempty"`
+	ResultErr string          `json:"result_error,omitempty"`
+}
+
+// Age 等待时长
+func (r *Request) Age() time.Duration {
+	return time.Since(r.CreatedAt)
+}
+
+// expired 待审批请求是否已过期
+func (r *Request) expired() bool {
+	return r.Status == StatusPending && time.Now().After(r.ExpiresAt)
+}
+
+// Inbox 审批收件箱，Redis 不可用时降级为内存模式。Redis 出错期间登记的请求保存在内存中，之后仍从内存读写
+type Inbox struct {
+	client      *redis.Client
+	mu          sync.Mutex
+	fallbackMap map[string]*Request
+	fallback    storage.RedisFallback
+
+	policy   *Policy
+	notifier *Notifier
+	ttl      time.Duration
+}
+
+// NewInbox 创建审批收件箱，需在 Redis 初始化之后调用。审批策略无法生效时返回错误
+func NewInbox() (*Inbox, error) {
+	policy := PolicyFromConfig()
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	b := &Inbox{
+		fallbackMap: make(map[string]*Request),
+		policy:      policy,
+		notifier:    NewNotifier(config.Cfg.ApprovalConf.WebhookURL),
+		ttl:         defaultTTL,
+	}
+	if ttl, err := time.ParseDuration(config.Cfg.HITLConf.ApprovalTTL); err == nil && ttl > 0 {
+		b.ttl = ttl
+	}
+
+	client, err := storage.GetRedisClient()
+	if err != nil {
+		b.fallback.Disable()
+		return b, nil
+	}
+	b.client = client
+	return b, nil
+}
+
+// Submit 登记新的审批请求，按策略计算需要的审批人数并推送 created 事件
+func (b *Inbox) Submit(ctx context.Context, req *Request) error {
+	now := time.Now()
+	if req.ID == "" {
+		req.ID = uuid.NewString()
+	}
+	req.Status = StatusPending
+	req.Required = b.policy.Required(req.Type)
+	req.CreatedAt = now
+	req.ExpiresAt = now.Add(b.ttl)
+
+	_, err := b.mutate(ctx, req.ID, func(cur *Request, exists bool) error {
+		*cur = *req
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.notifier.Notify(EventCreated, req)
+	return nil
+}
+
+// Get 获取审批请求
+func (b *Inbox) Get(ctx context.Context, id string) (*Request, error) {
+	// 降级模式，或请求在降级期间登记
+	b.mu.Lock()
+	cur, ok := b.fallbackMap[id]
+	b.mu.Unlock()
+	if ok || b.fallback.Active() {
+		if !ok {
+			return nil, ErrNotFound
+		}
+		cp := *cur
+		return &cp, nil
+	}
+
+	data, err := b.client.Get(ctx, b.makeKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		b.fallback.Trip("审批收件箱", err)
+		return b.Get(ctx, id)
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal approval request: %w", err)
+	}
+	return &req, nil
+}
+
+// ListPending 按创建时间列出所有未过期的待审批请求
+func (b *Inbox) ListPending(ctx context.Context) ([]*Request, error) {
+	ids, err := b.pendingIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Request, 0, len(ids))
+	for _, id := range ids {
+		req, err := b.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			b.removePending(ctx, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req.Status != StatusPending || req.expired() {
+			continue
+		}
+		list = append(list, req)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Approve 记录一票审批，达到所需人数时返回 ready=true，由调用方恢复执行。
+// 审批人修改 SQL 时会重新做安全检查并清空已有的审批，其他审批人需要针对新 SQL 重新审批
+func (b *Inbox) Approve(ctx context.Context, id string, vote Vote) (*Request, bool, error) {
+	ready := false
+	req, err := b.mutate(ctx, id, func(r *Request, exists bool) error {
+		if err := checkPending(r, exists); err != nil {
+			return err
+		}
+
+		if edited := sql_guard.Normalize(vote.SQL); edited != "" && edited != r.SQL {
+			report := sql_guard.Analyze(edited, sql_guard.PolicyFromConfig())
+			if report.Blocked() {
+				return fmt.Errorf("%w: %v", ErrBlocked, report.Reasons)
+			}
+			r.SQL = edited
+			r.Type = report.Type
+			r.Risk = report.Risk
+			r.Reasons = report.Reasons
+			r.Tables = report.Tables
+			r.Preview = nil
+			r.Required = b.policy.Required(report.Type)
+			r.Approvals = nil
+			vote.SQL = edited
+		} else {
+			vote.SQL = ""
+		}
+
+		role, err := b.checkApprover(r, vote.Approver)
+		if err != nil {
+			return err
+		}
+		for _, v := range r.Approvals {
+			if v.Approver == vote.Approver {
+				return ErrDuplicateVote
+			}
+		}
+
+		vote.Role = role
+		vote.At = time.Now()
+		r.Approvals = append(r.Approvals, vote)
+		if len(r.Approvals) >= r.Required {
+			r.Status = StatusApproved
+			ready = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if ready {
+		b.removePending(ctx, id)
+	}
+	b.notifier.Notify(EventApproved, req)
+	return req, ready, nil
+}
+
+// CanApprove 判断 approver 是否有权审批该请求
+func (b *Inbox) CanApprove(r *Request, approver string) bool {
+	_, err := b.checkApprover(r, approver)
+	return err == nil
+}
+
+// checkApprover 申请人不能审批或拒绝自己的请求，其余按审批策略校验，返回审批人角色
+func (b *Inbox) checkApprover(r *Request, approver string) (string, error) {
+	if approver != "" && approver == r.Requester {
+		return "", fmt.Errorf("%w: 申请人不能审批自己提交的 SQL", ErrForbidden)
+	}
+	return b.policy.CheckApprover(r.Type, approver)
+}
+
+// Reject 拒绝审批请求，任意一位有权审批的人拒绝即结束
+func (b *Inbox) Reject(ctx context.Context, id string, vote Vote) (*Request, error) {
+	req, err := b.mutate(ctx, id, func(r *Request, exists bool) error {
+		if err := checkPending(r, exists); err != nil {
+			return err
+		}
+		role, err := b.checkApprover(r, vote.Approver)
+		if err != nil {
+			return err
+		}
+		vote.Role = role
+		vote.At = time.Now()
+		r.Rejection = &vote
+		r.Status = StatusRejected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.removePending(ctx, id)
+	b.notifier.Notify(EventRejected, req)
+	return req, nil
+}
+
+// SaveResult 保存代为执行的结果，供申请人查询
+func (b *Inbox) SaveResult(ctx context.Context, id string, result []ResultMessage, execErr error) error {
+	_, err := b.mutate(ctx, id, func(r *Request, exists bool) error {
+		if !exists {
+			return ErrNotFound
+		}
+		r.Result = result
+		if execErr != nil {
+			r.ResultErr = execErr.Error()
+		}
+		return nil
+	})
+	return err
+}
+
+// StartJanitor 定期将过期的待审批请求标记为 expired 并推送事件，同时清理内存中超过保留时间的请求
+func (b *Inbox) StartJanitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(defaultSweepTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.sweep(ctx); err != nil {
+					log.Printf("清理过期审批失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (b *Inbox) sweep(ctx context.Context) error {
+	b.sweepFallback()
+	ids, err := b.pendingIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		expired := false
+		req, err := b.mutate(ctx, id, func(r *Request, exists bool) error {
+			if !exists {
+				return ErrNotFound
+			}
+			if r.expired() {
+				r.Status = StatusExpired
+				expired = true
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			b.removePending(ctx, id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if expired {
+			b.removePending(ctx, id)
+			b.notifier.Notify(EventExpired, req)
+		}
+	}
+	return nil
+}
+
+// sweepFallback 删除内存中已结束且超过保留时间的请求，与 Redis 中键的过期时间保持一致
+func (b *Inbox) sweepFallback() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for id, r := range b.fallbackMap {
+		if r.Status != StatusPending && now.After(r.ExpiresAt.Add(retentionTTL)) {
+			delete(b.fallbackMap, id)
+		}
+	}
+}
+
+func checkPending(r *Request, exists bool) error {
+	if !exists {
+		return ErrNotFound
+	}
+	if r.expired() {
+		return fmt.Errorf("%w: expired", ErrNotPending)
+	}
+	if r.Status != StatusPending {
+		return fmt.Errorf("%w: %s", ErrNotPending, r.Status)
+	}
+	return nil
+}
+
+// mutate 读取-修改-写入的原子操作：Redis 模式使用 WATCH 乐观锁，内存模式使用互斥锁
+func (b *Inbox) mutate(ctx context.Context, id string, fn func(r *Request, exists bool) error) (*Request, error) {
+	// 降级模式，或请求在降级期间登记
+	b.mu.Lock()
+	_, inMemory := b.fallbackMap[id]
+	b.mu.Unlock()
+	if inMemory || b.fallback.Active() {
+		return b.mutateFallback(id, fn)
+	}
+
+	key := b.makeKey(id)
+	var result *Request
+	err := storage.WatchUpdate(ctx, b.client, key, func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		req := &Request{}
+		if exists {
+			if err := json.Unmarshal(data, req); err != nil {
+				return fmt.Errorf("failed to unmarshal approval request: %w", err)
+			}
+		}
+		if err := fn(req, exists); err != nil {
+			return err
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("failed to marshal approval request: %w", err)
+		}
+		pipe.Set(ctx, key, data, b.ttl+retentionTTL)
+		if req.Status == StatusPending {
+			pipe.ZAdd(ctx, pendingIndexKey, redis.Z{Score: float64(req.CreatedAt.Unix()), Member: req.ID})
+		}
+		result = req
+		return nil
+	})
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.Is(err, storage.ErrTxConflict):
+		return nil, ErrConflict
+	case errors.Is(err, storage.ErrRedis):
+		// Redis出错时降级到内存模式
+		b.fallback.Trip("审批收件箱", err)
+		return b.mutateFallback(id, fn)
+	default:
+		return nil, err
+	}
+}
+
+// mutateFallback 内存模式下的读取-修改-写入，使用互斥锁保证原子性
+func (b *Inbox) mutateFallback(id string, fn func(r *Request, exists bool) error) (*Request, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	req := &Request{}
+	cur, exists := b.fallbackMap[id]
+	if exists {
+		*req = *cur
+	}
+	if err := fn(req, exists); err != nil {
+		return nil, err
+	}
+	b.fallbackMap[id] = req
+	cp := *req
+	return &cp, nil
+}
+
+// pendingIDs 返回内存中与 Redis 待审批索引中的请求 ID，Redis 出错时只返回内存中的
+func (b *Inbox) pendingIDs(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	ids := make([]string, 0)
+	inMemory := make(map[string]bool, len(b.fallbackMap))
+	for id, req := range b.fallbackMap {
+		inMemory[id] = true
+		if req.Status == StatusPending {
+			ids = append(ids, id)
+		}
+	}
+	b.mu.Unlock()
+
+	if b.fallback.Active() {
+		return ids, nil
+	}
+	redisIDs, err := b.client.ZRange(ctx, pendingIndexKey, 0, -1).Result()
+	if err != nil {
+		b.fallback.Trip("审批收件箱", err)
+		return ids, nil
+	}
+	for _, id := range redisIDs {
+		// 内存中的请求优先，Redis 中的旧版本不再使用
+		if !inMemory[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (b *Inbox) removePending(ctx context.Context, id string) {
+	if b.fallback.Active() {
+		return
+	}
+	if err := b.client.ZRem(ctx, pendingIndexKey, id).Err(); err != nil {
+		log.Printf("移除待审批索引失败: id=%s, err=%v", id, err)
+	}
+}
+
+// makeKey 生成Redis key: approval:{id}
+func (b *Inbox) makeKey(id string) string {
+	return fmt.Sprintf("%s:%s", approvalPrefix, id)
+}
@@ -0,0 +1,118 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"go-agent/tool/sql_guard"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestInbox(t *testing.T) (*Inbox, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return &Inbox{client: client, fallbackMap: make(map[string]*Request), policy: testPolicy(), ttl: time.Minute}, mr
+}
+
+func TestInboxApprove(t *testing.T) {
+	ctx := context.Background()
+	b, mr := newTestInbox(t)
+
+	ddl := &Request{SessionID: "s1", Requester: "bob", SQL: "ALTER TABLE t ADD c INT", Type: sql_guard.StatementDDL}
+	if err := b.Submit(ctx, ddl); err != nil {
+		t.Fatal(err)
+	}
+	if ddl.Required != 2 {
+		t.Errorf("Required = %d, want 2", ddl.Required)
+	}
+	if ids, _ := mr.ZMembers(pendingIndexKey); len(ids) != 1 {
+		t.Errorf("待审批索引 = %v", ids)
+	}
+
+	list, err := b.ListPending(ctx)
+	if err != nil || len(list) != 1 || list[0].ID != ddl.ID {
+		t.Fatalf("ListPending = %v, %v", list, err)
+	}
+
+	tests := []struct {
+		name     string
+		approver string
+		wantErr  error
+		ready    bool
+	}{
+		{"申请人不能自审", "bob", ErrForbidden, false},
+		{"第一票", "alice", nil, false},
+		{"同一人重复投票", "alice", ErrDuplicateVote, false},
+		{"票数达到要求", "carol", nil, true},
+		{"已结束的请求", "dave", ErrNotPending, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, ready, err := b.Approve(ctx, ddl.ID, Vote{Approver: tt.approver})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ready != tt.ready {
+				t.Errorf("ready = %v, want %v", ready, tt.ready)
+			}
+			if tt.ready && req.Status != StatusApproved {
+				t.Errorf("Status = %s", req.Status)
+			}
+		})
+	}
+
+	if ids, _ := mr.ZMembers(pendingIndexKey); len(ids) != 0 {
+		t.Errorf("审批通过后仍在待审批索引中: %v", ids)
+	}
+	got, err := b.Get(ctx, ddl.ID)
+	if err != nil || len(got.Approvals) != 2 || got.Approvals[0].Role != "dba" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+}
+
+func TestInboxReject(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestInbox(t)
+
+	req := &Request{SessionID: "s1", Requester: "bob", SQL: "UPDATE t SET a = 1 WHERE id = 1", Type: sql_guard.StatementWrite}
+	if err := b.Submit(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Reject(ctx, req.ID, Vote{Approver: "bob"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("申请人拒绝自己的请求: err = %v", err)
+	}
+	rejected, err := b.Reject(ctx, req.ID, Vote{Approver: "alice", Reason: "范围太大"})
+	if err != nil || rejected.Status != StatusRejected || rejected.Rejection.Reason != "范围太大" {
+		t.Fatalf("Reject = %+v, %v", rejected, err)
+	}
+	if list, _ := b.ListPending(ctx); len(list) != 0 {
+		t.Errorf("拒绝后仍待审批: %v", list)
+	}
+	if _, err := b.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestInboxFallback(t *testing.T) {
+	ctx := context.Background()
+	b, mr := newTestInbox(t)
+
+	// Redis 出错期间登记的请求保存在内存中，仍可查询与审批
+	mr.Close()
+	req := &Request{SessionID: "s1", Requester: "bob", Type: sql_guard.StatementRead}
+	if err := b.Submit(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	list, err := b.ListPending(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListPending = %v, %v", list, err)
+	}
+	if _, ready, err := b.Approve(ctx, req.ID, Vote{Approver: "alice"}); err != nil || !ready {
+		t.Errorf("Approve = %v, %v", ready, err)
+	}
+}
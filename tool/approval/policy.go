@@ -0,0 +1,116 @@
+package approval
+
+import (
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/sql_guard"
+	"strconv"
+	"strings"
+)
+
+// Rule 某类语句的审批规则
+type Rule struct {
+	Roles    []string // 允许审批的角色，为空时任意审批人均可
+	Required int      // 需要的审批人数
+}
+
+// Policy 审批策略
+type Policy struct {
+	Approvers map[string]string // 审批人 -> 角色，为空时不校验审批人身份
+	Rules     map[sql_guard.StatementType]Rule
+}
+
+// PolicyFromConfig 从全局配置构建审批策略
+func PolicyFromConfig() *Policy {
+	conf := config.Cfg.ApprovalConf
+	return &Policy{
+		Approvers: parseApprovers(conf.Approvers),
+		Rules: map[sql_guard.StatementType]Rule{
+			sql_guard.StatementRead:  {Roles: splitList(conf.ReadRoles), Required: parseRequired(conf.ReadRequired)},
+			sql_guard.StatementWrite: {Roles: splitList(conf.WriteRoles), Required: parseRequired(conf.WriteRequired)},
+			sql_guard.StatementDDL:   {Roles: splitList(conf.DDLRoles), Required: parseRequired(conf.DDLRequired)},
+		},
+	}
+}
+
+// Validate 检查策略能否生效：限制了角色或要求多人审批时必须配置审批人名单，
+// 否则任何身份都能满足规则，多人审批形同虚设
+func (p *Policy) Validate() error {
+	if len(p.Approvers) > 0 {
+		return nil
+	}
+	for t, r := range p.Rules {
+		if len(r.Roles) > 0 || r.Required > 1 {
+			return fmt.Errorf("%s 语句的审批规则限制了角色或要求多人审批，但未配置 APPROVAL_APPROVERS", t)
+		}
+	}
+	return nil
+}
+
+// rule 未知类型按最严格的 DDL 规则处理
+func (p *Policy) rule(t sql_guard.StatementType) Rule {
+	if r, ok := p.Rules[t]; ok {
+		return r
+	}
+	return p.Rules[sql_guard.StatementDDL]
+}
+
+// Required 返回该类语句需要的审批人数
+func (p *Policy) Required(t sql_guard.StatementType) int {
+	if n := p.rule(t).Required; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// CheckApprover 校验审批人是否有权审批该类语句，返回审批人角色
+func (p *Policy) CheckApprover(t sql_guard.StatementType, approver string) (string, error) {
+	if approver == "" {
+		return "", fmt.Errorf("%w: 未认证的审批人", ErrForbidden)
+	}
+	if len(p.Approvers) == 0 {
+		return "", nil
+	}
+	role, ok := p.Approvers[approver]
+	if !ok {
+		return "", fmt.Errorf("%w: %s 不是审批人", ErrForbidden, approver)
+	}
+	roles := p.rule(t).Roles
+	if len(roles) == 0 {
+		return role, nil
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r, role) {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("%w: 角色 %s 无权审批 %s 语句", ErrForbidden, role, t)
+}
+
+// parseApprovers 解析 "alice:dba,bob:analyst" 格式的审批人列表
+func parseApprovers(raw string) map[string]string {
+	approvers := make(map[string]string)
+	for _, item := range splitList(raw) {
+		name, role, _ := strings.Cut(item, ":")
+		approvers[strings.TrimSpace(name)] = strings.TrimSpace(role)
+	}
+	return approvers
+}
+
+func parseRequired(raw string) int {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+func splitList(raw string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,137 @@
+package approval
+
+import (
+	"errors"
+	"go-agent/tool/sql_guard"
+	"reflect"
+	"testing"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		Approvers: map[string]string{"alice": "dba", "bob": "analyst", "carol": "DBA"},
+		Rules: map[sql_guard.StatementType]Rule{
+			sql_guard.StatementRead:  {Required: 1},
+			sql_guard.StatementWrite: {Roles: []string{"dba", "analyst"}, Required: 1},
+			sql_guard.StatementDDL:   {Roles: []string{"dba"}, Required: 2},
+		},
+	}
+}
+
+func TestPolicyCheckApprover(t *testing.T) {
+	p := testPolicy()
+	tests := []struct {
+		name     string
+		typ      sql_guard.StatementType
+		approver string
+		role     string
+		wantErr  bool
+	}{
+		{"未认证", sql_guard.StatementRead, "", "", true},
+		{"不在名单中", sql_guard.StatementRead, "mallory", "", true},
+		{"规则不限角色", sql_guard.StatementRead, "bob", "analyst", false},
+		{"角色在允许列表中", sql_guard.StatementWrite, "bob", "analyst", false},
+		{"角色无权审批 DDL", sql_guard.StatementDDL, "bob", "", true},
+		{"角色大小写不敏感", sql_guard.StatementDDL, "carol", "DBA", false},
+		{"未知类型按 DDL 规则", sql_guard.StatementUnknown, "bob", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := p.CheckApprover(tt.typ, tt.approver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrForbidden) {
+				t.Errorf("err = %v, want ErrForbidden", err)
+			}
+			if role != tt.role {
+				t.Errorf("role = %q, want %q", role, tt.role)
+			}
+		})
+	}
+}
+
+func TestPolicyRequired(t *testing.T) {
+	p := testPolicy()
+	p.Rules[sql_guard.StatementRead] = Rule{}
+	tests := []struct {
+		typ  sql_guard.StatementType
+		want int
+	}{
+		{sql_guard.StatementRead, 1}, // 未配置人数时至少 1 人
+		{sql_guard.StatementWrite, 1},
+		{sql_guard.StatementDDL, 2},
+		{sql_guard.StatementUnknown, 2},
+	}
+	for _, tt := range tests {
+		if got := p.Required(tt.typ); got != tt.want {
+			t.Errorf("Required(%s) = %d, want %d", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *Policy
+		wantErr bool
+	}{
+		{"配置了审批人名单", testPolicy(), false},
+		{
+			name:   "无名单且规则宽松",
+			policy: &Policy{Rules: map[sql_guard.StatementType]Rule{sql_guard.StatementWrite: {Required: 1}}},
+		},
+		{
+			name:    "无名单但要求多人审批",
+			policy:  &Policy{Rules: map[sql_guard.StatementType]Rule{sql_guard.StatementDDL: {Required: 2}}},
+			wantErr: true,
+		},
+		{
+			name:    "无名单但限制了角色",
+			policy:  &Policy{Rules: map[sql_guard.StatementType]Rule{sql_guard.StatementWrite: {Roles: []string{"dba"}, Required: 1}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyNoRoster(t *testing.T) {
+	p := &Policy{Rules: map[sql_guard.StatementType]Rule{sql_guard.StatementRead: {Required: 1}}}
+	if _, err := p.CheckApprover(sql_guard.StatementRead, "anyone"); err != nil {
+		t.Errorf("未配置名单时任意已认证身份均可审批: %v", err)
+	}
+}
+
+func TestInboxRejectsSelfApproval(t *testing.T) {
+	b := &Inbox{policy: testPolicy()}
+	r := &Request{Type: sql_guard.StatementWrite, Requester: "alice"}
+	if b.CanApprove(r, "alice") {
+		t.Error("申请人不应能审批自己的请求")
+	}
+	if !b.CanApprove(r, "bob") {
+		t.Error("其他有权限的审批人应能审批")
+	}
+}
+
+func TestParseApprovers(t *testing.T) {
+	got := parseApprovers(" alice:dba , bob:analyst,,carol")
+	want := map[string]string{"alice": "dba", "bob": "analyst", "carol": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseApprovers = %v, want %v", got, want)
+	}
+}
+
+func TestParseRequired(t *testing.T) {
+	tests := map[string]int{"2": 2, "0": 1, "-1": 1, "abc": 1, "": 1}
+	for raw, want := range tests {
+		if got := parseRequired(raw); got != want {
+			t.Errorf("parseRequired(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EventType 审批事件类型
+type EventType string
+
+const (
+	EventCreated  EventType = "created"
+	EventApproved EventType = "approved" // 收到一票审批，Request.Status 表示是否已达到人数
+	EventRejected EventType = "rejected"
+	EventExpired  EventType = "expired"
+)
+
+// Notifier 通过 Webhook 推送审批事件
+type Notifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewNotifier url 为空时不推送
+func NewNotifier(url string) *Notifier {
+	return &Notifier{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Notify 异步推送事件，失败只记录日志，不影响审批流程
+func (n *Notifier) Notify(event EventType, req *Request) {
+	if n == nil || n.url == "" {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"event":   event,
+		"request": req,
+		"time":    time.Now(),
+	})
+	if err != nil {
+		log.Printf("审批 Webhook 序列化失败: %v", err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+		if err != nil {
+			log.Printf("审批 Webhook 请求创建失败: %v", err)
+			return
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := n.client.Do(httpReq)
+		if err != nil {
+			log.Printf("审批 Webhook 推送失败: event=%s, id=%s, err=%v", event, req.ID, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("审批 Webhook 返回异常状态: event=%s, id=%s, status=%d", event, req.ID, resp.StatusCode)
+		}
+	}()
+}
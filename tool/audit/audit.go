@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"log"
+	"time"
+)
+
+// Record 一次数据库操作的审计记录
+type Record struct {
+	ID           string    `json:"id"`
+	SessionID    string    `json:"session_id"`
+	UserID       string    `json:"user_id,omitempty"`
+	Query        string    `json:"query,omitempty"` // 用户原始自然语言问题
+	Tool         string    `json:"tool"`            // 执行的 MCP 工具
+	SQL          string    `json:"sql,omitempty"`
+	Approver     string    `json:"approver,omitempty"`
+	Decision     string    `json:"decision,omitempty"` // 审批决定，未经审批的操作为空
+	ExecutedAt   time.Time `json:"executed_at"`
+	DurationMs   int64     `json:"duration_ms"`
+	AffectedRows int64     `json:"affected_rows"` // 写操作为影响行数，查询为返回行数，无法解析时为 -1
+	Error        string    `json:"error,omitempty"`
+	ResultHash   string    `json:"result_hash,omitempty"` // 执行结果的 SHA-256，用于事后核对结果未被篡改
+}
+
+// Filter 审计记录查询条件，零值字段不参与过滤
+type Filter struct {
+	SessionID  string
+	UserID     string
+	Approver   string
+	From       time.Time
+	To         time.Time
+	OnlyErrors bool
+	Limit      int
+}
+
+// Logger 审计日志存储，要求只追加
+type Logger interface {
+	Write(ctx context.Context, rec *Record) error
+	// Query 按执行时间倒序返回匹配的记录
+	Query(ctx context.Context, f Filter) ([]*Record, error)
+	Close() error
+}
+
+type LoggerFactory func(ctx context.Context) (Logger, error)
+
+var loggerRegistry = make(map[string]LoggerFactory)
+
+// Default 全局审计日志，在 InitAuditLogger 中初始化
+var Default Logger = nopLogger{}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// registerLogger 用于具体存储在初始化时注册自己
+func registerLogger(name string, factory LoggerFactory) {
+	loggerRegistry[name] = factory
+}
+
+// InitAuditLogger 按 AUDIT_TYPE 创建全局审计日志
+func InitAuditLogger(ctx context.Context) error {
+	initJSONL()
+	initMySQL()
+
+	name := config.Cfg.AuditConf.Type
+	if name == "" || name == "none" {
+		log.Println("审计日志未启用")
+		return nil
+	}
+	create, ok := loggerRegistry[name]
+	if !ok {
+		return fmt.Errorf("未注册的审计日志类型: %s", name)
+	}
+	l, err := create(ctx)
+	if err != nil {
+		return err
+	}
+	Default = l
+	return nil
+}
+
+// Close 关闭全局审计日志
+func Close() error {
+	return Default.Close()
+}
+
+// Write 写入一条审计记录，失败只记录日志，不影响业务执行
+func Write(ctx context.Context, rec *Record) {
+	if err := Default.Write(ctx, rec); err != nil {
+		log.Printf("写入审计日志失败: session=%s, sql=%s, err=%v", rec.SessionID, rec.SQL, err)
+	}
+}
+
+// Query 查询审计记录
+func Query(ctx context.Context, f Filter) ([]*Record, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+	return Default.Query(ctx, f)
+}
+
+// match 判断记录是否满足过滤条件，供不支持条件查询的存储使用
+func (f Filter) match(rec *Record) bool {
+	if f.SessionID != "" && rec.SessionID != f.SessionID {
+		return false
+	}
+	if f.UserID != "" && rec.UserID != f.UserID {
+		return false
+	}
+	if f.Approver != "" && rec.Approver != f.Approver {
+		return false
+	}
+	if !f.From.IsZero() && rec.ExecutedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && rec.ExecutedAt.After(f.To) {
+		return false
+	}
+	if f.OnlyErrors && rec.Error == "" {
+		return false
+	}
+	return true
+}
+
+// nopLogger 未启用审计时使用
+type nopLogger struct{}
+
+func (nopLogger) Write(ctx context.Context, rec *Record) error { return nil }
+
+func (nopLogger) Query(ctx context.Context, f Filter) ([]*Record, error) {
+	return nil, fmt.Errorf("审计日志未启用，请配置 AUDIT_TYPE")
+}
+
+func (nopLogger) Close() error { return nil }
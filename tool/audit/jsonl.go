@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/config"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+func initJSONL() {
+	registerLogger("jsonl", func(ctx context.Context) (Logger, error) {
+		return NewJSONLLogger(config.Cfg.AuditConf.FilePath)
+	})
+}
+
+// JSONLLogger 以只追加的 JSONL 文件保存审计记录，每行一条
+type JSONLLogger struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewJSONLLogger(path string) (*JSONLLogger, error) {
+	if path == "" {
+		path = "logs/audit.jsonl"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志文件失败: %w", err)
+	}
+	log.Printf("审计日志写入文件: %s", path)
+	return &JSONLLogger{path: path, file: f}, nil
+}
+
+func (l *JSONLLogger) Write(ctx context.Context, rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	// 审计记录需要落盘，进程崩溃也不能丢失
+	return l.file.Sync()
+}
+
+// Query 顺序扫描整个文件，适合审计量不大的场景，量大时请使用 mysql
+func (l *JSONLLogger) Query(ctx context.Context, f Filter) ([]*Record, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志文件失败: %w", err)
+	}
+	defer file.Close()
+
+	records := make([]*Record, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if f.match(&rec) {
+			records = append(records, &rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志文件失败: %w", err)
+	}
+
+	// 文件按写入顺序追加，倒序后截取最近的记录
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if len(records) > f.Limit {
+		records = records[:f.Limit]
+	}
+	return records, nil
+}
+
+func (l *JSONLLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-agent/tool/sql_tools"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+	"github.com/google/uuid"
+)
+
+// 审批决定，未经审批直接执行的操作为空
+const (
+	DecisionApproved = "approved" // 经人工审批后执行
+	DecisionRejected = "rejected" // 审批人拒绝，未执行
+	DecisionBlocked  = "blocked"  // 被安全策略拦截，未执行
+)
+
+// WriteDecision 记录没有真正执行的决定（拒绝、拦截），rec 由调用方填写会话、申请人、SQL 与审批人
+func WriteDecision(ctx context.Context, tool, decision string, rec Record) {
+	rec.ID = uuid.NewString()
+	rec.Tool = tool
+	rec.Decision = decision
+	rec.ExecutedAt = time.Now()
+	rec.AffectedRows = -1
+	Write(ctx, &rec)
+}
+
+// Middleware 为每次 MCP 工具调用写入审计记录，describe 从图状态中补充会话、提问与审批信息。
+// 需放在 sql_tools.ErrorAsResultMiddleware 之前，以便同时记录执行错误
+func Middleware(describe func(ctx context.Context) Record) compose.ToolMiddleware {
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, input *compose.ToolInput) (*compose.ToolOutput, error) {
+				start := time.Now()
+				output, err := next(ctx, input)
+
+				rec := describe(ctx)
+				rec.ID = uuid.NewString()
+				rec.Tool = input.Name
+				rec.SQL = statementOf(input.Arguments)
+				rec.ExecutedAt = start
+				rec.DurationMs = time.Since(start).Milliseconds()
+				rec.AffectedRows = -1
+
+				switch {
+				case err != nil:
+					rec.Error = err.Error()
+				case output != nil:
+					if execErr, failed := sql_tools.ParseExecError(output.Result); failed {
+						rec.Error = execErr
+					} else {
+						text := sql_tools.ExtractToolText(output.Result)
+						sum := sha256.Sum256([]byte(text))
+						rec.ResultHash = hex.EncodeToString(sum[:])
+						rec.AffectedRows = affectedRows(text)
+					}
+				}
+
+				Write(ctx, &rec)
+				return output, err
+			}
+		},
+	}
+}
+
+// statementOf 取出工具参数中的 SQL，其他工具（如 describe_table）记录原始参数
+func statementOf(arguments string) string {
+	var args struct {
+		SQL string `json:"sql"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err == nil && args.SQL != "" {
+		return args.SQL
+	}
+	return arguments
+}
+
+// affectedRows 解析执行结果：查询返回 JSON 数组时为行数，写操作取 affectedRows 字段，无法解析时返回 -1
+func affectedRows(text string) int64 {
+	var rows []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &rows); err == nil {
+		return int64(len(rows))
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return -1
+	}
+	for _, key := range []string{"affectedRows", "affected_rows", "rowsAffected", "changes"} {
+		if n, ok := result[key].(float64); ok {
+			return int64(n)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/storage"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func initMySQL() {
+	registerLogger("mysql", func(ctx context.Context) (Logger, error) {
+		return NewMySQLLogger(ctx, config.Cfg.AuditConf.Table)
+	})
+}
+
+// MySQLLogger 将审计记录写入 MySQL 表，表不存在时自动创建
+type MySQLLogger struct {
+	db    *sql.DB
+	table string
+}
+
+func NewMySQLLogger(ctx context.Context, table string) (*MySQLLogger, error) {
+	if table == "" {
+		table = "sql_audit_log"
+	}
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("非法的审计表名: %s", table)
+	}
+
+	db, err := storage.NewMySQL(ctx, config.Cfg.AuditConf.MySQL)
+	if err != nil {
+		return nil, fmt.Errorf("初始化审计库失败: %w", err)
+	}
+
+	l := &MySQLLogger{db: db, table: table}
+	if err := l.createTable(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	log.Printf("审计日志写入 MySQL 表: %s", table)
+	return l, nil
+}
+
+func (l *MySQLLogger) createTable(ctx context.Context) error {
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ("+
+		"`id` VARCHAR(64) NOT NULL PRIMARY KEY,"+
+		"`session_id` VARCHAR(128) NOT NULL,"+
+		"`user_id` VARCHAR(128) NOT NULL DEFAULT '',"+
+		"`query` TEXT,"+
+		"`tool` VARCHAR(64) NOT NULL,"+
+		"`sql_text` TEXT,"+
+		"`approver` VARCHAR(255) NOT NULL DEFAULT '',"+
+		"`decision` VARCHAR(32) NOT NULL DEFAULT '',"+
+		"`executed_at` DATETIME(3) NOT NULL,"+
+		"`duration_ms` BIGINT NOT NULL,"+
+		"`affected_rows` BIGINT NOT NULL,"+
+		"`error` TEXT,"+
+		"`result_hash` CHAR(64) NOT NULL DEFAULT '',"+
+		"KEY `idx_session` (`session_id`),"+
+		"KEY `idx_user` (`user_id`),"+
+		"KEY `idx_executed_at` (`executed_at`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", l.table)
+	if _, err := l.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("创建审计表失败: %w", err)
+	}
+	return nil
+}
+
+func (l *MySQLLogger) Write(ctx context.Context, rec *Record) error {
+	_, err := l.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO `%s` "+
+		"(id, session_id, user_id, query, tool, sql_text, approver, decision, executed_at, duration_ms, affected_rows, error, result_hash) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", l.table),
+		rec.ID, rec.SessionID, rec.UserID, rec.Query, rec.Tool, rec.SQL, rec.Approver, rec.Decision,
+		rec.ExecutedAt, rec.DurationMs, rec.AffectedRows, rec.Error, rec.ResultHash)
+	return err
+}
+
+func (l *MySQLLogger) Query(ctx context.Context, f Filter) ([]*Record, error) {
+	conds := make([]string, 0)
+	args := make([]any, 0)
+	if f.SessionID != "" {
+		conds = append(conds, "session_id = ?")
+		args = append(args, f.SessionID)
+	}
+	if f.UserID != "" {
+		conds = append(conds, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Approver != "" {
+		conds = append(conds, "approver = ?")
+		args = append(args, f.Approver)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "executed_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "executed_at <= ?")
+		args = append(args, f.To)
+	}
+	if f.OnlyErrors {
+		conds = append(conds, "error <> ''")
+	}
+
+	query := fmt.Sprintf("SELECT id, session_id, user_id, COALESCE(query, ''), tool, COALESCE(sql_text, ''), approver, decision, "+
+		"executed_at, duration_ms, affected_rows, COALESCE(error, ''), result_hash FROM `%s`", l.table)
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY executed_at DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*Record, 0)
+	for rows.Next() {
+		var rec Record
+		if err := rows.Scan(&rec.ID, &rec.SessionID, &rec.UserID, &rec.Query, &rec.Tool, &rec.SQL, &rec.Approver, &rec.Decision,
+			&rec.ExecutedAt, &rec.DurationMs, &rec.AffectedRows, &rec.Error, &rec.ResultHash); err != nil {
+			return nil, fmt.Errorf("读取审计日志失败: %w", err)
+		}
+		records = append(records, &rec)
+	}
+	return records, rows.Err()
+}
+
+func (l *MySQLLogger) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,107 @@
+package intent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 意图日志的记录类型
+const (
+	RecordDecision = "decision" // 每次路由的结果，Fallback 不为空的记录即为疑似误判
+	RecordMisroute = "misroute" // 用户或评估人员反馈的误判
+)
+
+// LogRecord 意图日志中的一条记录，用于离线评估与补充路由示例
+type LogRecord struct {
+	Time       time.Time         `json:"time"`
+	Type       string            `json:"type"`
+	SessionID  string            `json:"session_id,omitempty"`
+	Query      string            `json:"query"`
+	Intent     string            `json:"intent,omitempty"`
+	Target     Target            `json:"target,omitempty"`
+	Confidence float64           `json:"confidence,omitempty"`
+	Slots      map[string]string `json:"slots,omitempty"`
+	Fallback   string            `json:"fallback,omitempty"`
+	Forced     bool              `json:"forced,omitempty"`
+	Raw        string            `json:"raw,omitempty"`      // 模型原始输出，便于排查解析失败
+	Expected   string            `json:"expected,omitempty"` // 误判反馈中正确的意图
+	Comment    string            `json:"comment,omitempty"`
+}
+
+var (
+	logMu   sync.Mutex
+	logFile *os.File
+)
+
+// InitLog 打开只追加的意图日志文件，path 为空时不记录
+func InitLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建意图日志目录失败: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("打开意图日志文件失败: %w", err)
+	}
+	logMu.Lock()
+	logFile = f
+	logMu.Unlock()
+	log.Printf("意图日志写入文件: %s", path)
+	return nil
+}
+
+// CloseLog 关闭意图日志文件
+func CloseLog() error {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile == nil {
+		return nil
+	}
+	err := logFile.Close()
+	logFile = nil
+	return err
+}
+
+// Record 追加一条意图日志，写入失败只打印日志，不影响对话
+func Record(rec *LogRecord) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("序列化意图日志失败: %v", err)
+		return
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile == nil {
+		return
+	}
+	if _, err := logFile.Write(append(line, '\n')); err != nil {
+		log.Printf("写入意图日志失败: %v", err)
+	}
+}
+
+// DecisionRecord 将路由结果转换为日志记录
+func DecisionRecord(sessionID, query string, d *Decision) *LogRecord {
+	return &LogRecord{
+		Type:       RecordDecision,
+		SessionID:  sessionID,
+		Query:      query,
+		Intent:     d.Intent,
+		Target:     d.Target,
+		Confidence: d.Confidence,
+		Slots:      d.Slots,
+		Fallback:   d.Fallback,
+		Forced:     d.Forced,
+		Raw:        d.Raw,
+	}
+}
@@ -0,0 +1,101 @@
+package intent
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target 路由目标，对应总控图中的处理路径
+type Target string
+
+const (
+	TargetSQL      Target = "sql"      // 生成并执行 SQL，直接返回结果
+	TargetAnalysis Target = "analysis" // 生成并执行 SQL 后由 Analyst 子图做统计分析与图表
+	TargetRAG      Target = "rag"      // 知识库问答
+	TargetChat     Target = "chat"     // 闲聊与通用问题
+)
+
+var targets = map[Target]bool{TargetSQL: true, TargetAnalysis: true, TargetRAG: true, TargetChat: true}
+
+// Route 一个意图及其路由目标，描述和示例会写入意图识别的提示词
+type Route struct {
+	Name        string   `yaml:"name" json:"name"`
+	Target      Target   `yaml:"target" json:"target"`
+	Description string   `yaml:"description" json:"description"`
+	Examples    []string `yaml:"examples" json:"examples,omitempty"`
+	Slots       []string `yaml:"slots" json:"slots,omitempty"` // 需要从问题中抽取的槽位，如 time_range
+}
+
+type routesFile struct {
+	Routes []Route `yaml:"routes"`
+}
+
+// defaultRoutes 未配置 INTENT_ROUTES_PATH 时使用的内置路由
+func defaultRoutes() []Route {
+	dataSlots := []string{"metric", "dimension", "time_range", "filters"}
+	return []Route{
+		{
+			Name:        "sql",
+			Target:      TargetSQL,
+			Description: "查询、统计或修改业务数据库中的数据，只需要返回数据本身",
+			Examples:    []string{"查询最近10笔订单", "把订单1001的状态改为已发货", "一共有多少注册用户"},
+			Slots:       dataSlots,
+		},
+		{
+			Name:        "analysis",
+			Target:      TargetAnalysis,
+			Description: "对业务数据做趋势、对比、分布、异常等分析，或需要图表、报表",
+			Examples:    []string{"分析最近三个月销售额的变化趋势", "各地区的订单量对比，画个图"},
+			Slots:       dataSlots,
+		},
+		{
+			Name:        "rag",
+			Target:      TargetRAG,
+			Description: "询问已上传文档、规章制度、产品说明等知识库中的内容",
+			Examples:    []string{"报销流程是什么", "产品的退款政策怎么规定的"},
+			Slots:       []string{"topic"},
+		},
+		{
+			Name:        "chat",
+			Target:      TargetChat,
+			Description: "问候、闲聊，或与业务数据和知识库无关的通用问题",
+			Examples:    []string{"你好", "你能做什么"},
+		},
+	}
+}
+
+// LoadRoutes 读取路由配置，path 为空时使用内置路由
+func LoadRoutes(path string) ([]Route, error) {
+	if path == "" {
+		return defaultRoutes(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取意图路由配置失败: %w", err)
+	}
+	var file routesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析意图路由配置失败: %w", err)
+	}
+	if len(file.Routes) == 0 {
+		return nil, fmt.Errorf("意图路由配置为空: %s", path)
+	}
+
+	seen := map[string]bool{}
+	for _, r := range file.Routes {
+		if r.Name == "" || r.Description == "" {
+			return nil, fmt.Errorf("意图路由缺少 name 或 description: %+v", r)
+		}
+		if !targets[r.Target] {
+			return nil, fmt.Errorf("意图 %s 的 target 不支持: %q，可选 sql / analysis / rag / chat", r.Name, r.Target)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("意图路由重复: %s", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return file.Routes, nil
+}
@@ -0,0 +1,165 @@
+package intent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 低于阈值或无法识别时的兜底原因，会写入意图日志供评估使用
+const (
+	FallbackLowConfidence = "low_confidence"
+	FallbackUnparsable    = "unparsable"
+	FallbackUnknownIntent = "unknown_intent"
+)
+
+const defaultClarification = "抱歉，我不太确定您的需求。请问您是想查询数据、分析数据，还是咨询知识库中的内容？"
+
+// Decision 一次意图识别的结果
+type Decision struct {
+	Intent        string            `json:"intent"`
+	Target        Target            `json:"target"`
+	Confidence    float64           `json:"confidence"`
+	Slots         map[string]string `json:"slots,omitempty"`
+	Clarification string            `json:"clarification,omitempty"` // 需要向用户澄清时的追问
+	Fallback      string            `json:"fallback,omitempty"`      // 兜底原因，正常路由时为空
+	Forced        bool              `json:"forced,omitempty"`        // 调用方指定了路由，未经过模型识别
+	Raw           string            `json:"-"`                       // 模型原始输出
+}
+
+// NeedsClarification 是否应向用户追问而不是进入任何路由
+func (d *Decision) NeedsClarification() bool {
+	return d.Fallback != ""
+}
+
+// Router 基于模型的结构化意图识别，模型输出 JSON 格式的意图、置信度与槽位
+type Router struct {
+	model     model.BaseChatModel
+	routes    []Route
+	byName    map[string]Route
+	threshold float64
+	prompt    string
+}
+
+// NewRouter 创建意图路由，置信度低于 threshold 时返回澄清问题
+func NewRouter(cm model.BaseChatModel, routes []Route, threshold float64) *Router {
+	r := &Router{model: cm, routes: routes, byName: make(map[string]Route, len(routes)), threshold: threshold}
+	for _, route := range routes {
+		r.byName[route.Name] = route
+	}
+	r.prompt = buildPrompt(routes)
+	return r
+}
+
+// Classify 识别用户问题的意图，history 为最近的会话消息，用于理解省略了主语的追问。
+// 模型调用失败时返回错误，输出无法解析或置信度不足时返回带澄清问题的结果
+func (r *Router) Classify(ctx context.Context, query string, history []*schema.Message) (*Decision, error) {
+	out, err := r.model.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(r.prompt),
+		schema.UserMessage(withHistory(query, history)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("意图识别失败: %w", err)
+	}
+
+	d := r.parse(out.Content)
+	d.Raw = out.Content
+	if d.NeedsClarification() && d.Clarification == "" {
+		d.Clarification = defaultClarification
+	}
+	return d, nil
+}
+
+// Force 调用方指定路由时跳过模型识别，name 可以是意图名或路由目标
+func (r *Router) Force(name string) (*Decision, error) {
+	if route, ok := r.byName[name]; ok {
+		return &Decision{Intent: route.Name, Target: route.Target, Confidence: 1, Forced: true}, nil
+	}
+	if targets[Target(name)] {
+		return &Decision{Intent: name, Target: Target(name), Confidence: 1, Forced: true}, nil
+	}
+	return nil, fmt.Errorf("未知的路由: %s", name)
+}
+
+func (r *Router) parse(content string) *Decision {
+	var out struct {
+		Intent        string         `json:"intent"`
+		Confidence    float64        `json:"confidence"`
+		Slots         map[string]any `json:"slots"`
+		Clarification string         `json:"clarification"`
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end <= start || json.Unmarshal([]byte(content[start:end+1]), &out) != nil {
+		return &Decision{Fallback: FallbackUnparsable}
+	}
+
+	d := &Decision{Intent: out.Intent, Confidence: out.Confidence, Clarification: out.Clarification}
+	for k, v := range out.Slots {
+		if v == nil || v == "" {
+			continue
+		}
+		if d.Slots == nil {
+			d.Slots = map[string]string{}
+		}
+		d.Slots[k] = fmt.Sprint(v)
+	}
+
+	route, ok := r.byName[out.Intent]
+	switch {
+	case !ok:
+		d.Fallback = FallbackUnknownIntent
+	case out.Confidence < r.threshold:
+		d.Target = route.Target
+		d.Fallback = FallbackLowConfidence
+	default:
+		d.Target = route.Target
+	}
+	return d
+}
+
+// historyTurns 意图识别时附带的最近消息数
+const historyTurns = 4
+
+// withHistory 有会话历史时在用户输入前附上最近的对话
+func withHistory(query string, history []*schema.Message) string {
+	if len(history) > historyTurns {
+		history = history[len(history)-historyTurns:]
+	}
+	if len(history) == 0 {
+		return query
+	}
+	var b strings.Builder
+	b.WriteString("最近对话：")
+	for _, m := range history {
+		content := []rune(m.Content)
+		if len(content) > 200 {
+			content = append(content[:200], []rune("...")...)
+		}
+		fmt.Fprintf(&b, "\n[%s]: %s", m.Role, string(content))
+	}
+	b.WriteString("\n\n用户输入：" + query)
+	return b.String()
+}
+
+func buildPrompt(routes []Route) string {
+	var b strings.Builder
+	b.WriteString("你是一个意图识别专家。请判断用户输入属于以下哪一个意图：\n")
+	for _, r := range routes {
+		fmt.Fprintf(&b, "\n- %s：%s", r.Name, r.Description)
+		if len(r.Examples) > 0 {
+			fmt.Fprintf(&b, "\n  示例：%s", strings.Join(r.Examples, "；"))
+		}
+		if len(r.Slots) > 0 {
+			fmt.Fprintf(&b, "\n  需要抽取的槽位：%s", strings.Join(r.Slots, ", "))
+		}
+	}
+	b.WriteString("\n\n只输出一个 JSON 对象，不要输出其他内容，格式如下：\n")
+	b.WriteString(`{"intent": "意图名", "confidence": 0到1之间的置信度, "slots": {"槽位名": "从问题中抽取的值"}, "clarification": "意图不明确时向用户追问的问题，明确时为空"}`)
+	b.WriteString("\n问题中没有提到的槽位不要输出；无法确定意图时降低 confidence 并给出 clarification。")
+	b.WriteString("\n输入中附带最近对话时，只识别最后的用户输入，对话用于理解省略了主语的追问，如上一轮是数据查询，“按地区拆开看看”仍是数据类意图。")
+	return b.String()
+}
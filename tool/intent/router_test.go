@@ -0,0 +1,113 @@
+package intent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouterParse(t *testing.T) {
+	r := NewRouter(nil, defaultRoutes(), 0.6)
+
+	tests := []struct {
+		name     string
+		content  string
+		intent   string
+		target   Target
+		fallback string
+		slots    map[string]string
+	}{
+		{
+			name:    "纯 JSON",
+			content: `{"intent": "sql", "confidence": 0.9}`,
+			intent:  "sql",
+			target:  TargetSQL,
+		},
+		{
+			name:    "代码块与前后说明文字",
+			content: "好的，结果如下：\n```json\n{\"intent\": \"analysis\", \"confidence\": 0.8}\n```\n以上。",
+			intent:  "analysis",
+			target:  TargetAnalysis,
+		},
+		{
+			name:    "槽位转为字符串并丢弃空值",
+			content: `{"intent": "sql", "confidence": 0.95, "slots": {"metric": "销售额", "time_range": "", "filters": null, "limit": 10}}`,
+			intent:  "sql",
+			target:  TargetSQL,
+			slots:   map[string]string{"metric": "销售额", "limit": "10"},
+		},
+		{
+			name:     "置信度低于阈值",
+			content:  `{"intent": "rag", "confidence": 0.3, "clarification": "您是想查询知识库吗？"}`,
+			intent:   "rag",
+			target:   TargetRAG,
+			fallback: FallbackLowConfidence,
+		},
+		{
+			name:     "置信度等于阈值时正常路由",
+			content:  `{"intent": "chat", "confidence": 0.6}`,
+			intent:   "chat",
+			target:   TargetChat,
+			fallback: "",
+		},
+		{
+			name:     "未知意图",
+			content:  `{"intent": "weather", "confidence": 0.99}`,
+			intent:   "weather",
+			fallback: FallbackUnknownIntent,
+		},
+		{
+			name:     "没有 JSON",
+			content:  "sql",
+			fallback: FallbackUnparsable,
+		},
+		{
+			name:     "JSON 不完整",
+			content:  `{"intent": "sql", "confidence": }`,
+			fallback: FallbackUnparsable,
+		},
+		{
+			name:     "字段类型错误",
+			content:  `{"intent": "sql", "confidence": "high"}`,
+			fallback: FallbackUnparsable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := r.parse(tt.content)
+			if d.Intent != tt.intent || d.Target != tt.target || d.Fallback != tt.fallback {
+				t.Errorf("parse() = {intent:%q target:%q fallback:%q}, want {intent:%q target:%q fallback:%q}",
+					d.Intent, d.Target, d.Fallback, tt.intent, tt.target, tt.fallback)
+			}
+			if !reflect.DeepEqual(d.Slots, tt.slots) {
+				t.Errorf("Slots = %v, want %v", d.Slots, tt.slots)
+			}
+			if d.NeedsClarification() != (tt.fallback != "") {
+				t.Errorf("NeedsClarification() = %v", d.NeedsClarification())
+			}
+		})
+	}
+}
+
+func TestRouterForce(t *testing.T) {
+	r := NewRouter(nil, defaultRoutes(), 0.6)
+
+	tests := []struct {
+		name    string
+		target  Target
+		wantErr bool
+	}{
+		{"analysis", TargetAnalysis, false}, // 意图名
+		{"rag", TargetRAG, false},
+		{"unknown", "", true},
+	}
+	for _, tt := range tests {
+		d, err := r.Force(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Force(%q) err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && (d.Target != tt.target || !d.Forced || d.Confidence != 1) {
+			t.Errorf("Force(%q) = %+v", tt.name, d)
+		}
+	}
+}
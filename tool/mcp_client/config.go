@@ -0,0 +1,143 @@
+package mcp_client
+
+import (
+	"fmt"
+	"go-agent/config"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TransportInProcess = "inprocess"
+	TransportStdio     = "stdio"
+	TransportSSE       = "sse"
+	TransportHTTP      = "http" // streamable HTTP
+)
+
+// SQLServer 内置数据库 MCP 服务器的名称，NL2SQL 链路通过它执行 SQL
+const SQLServer = "mysql"
+
+// ServerConfig 单个 MCP 服务器的连接配置
+type ServerConfig struct {
+	Name      string            `yaml:"name"`      // 服务器名称，同时作为工具名前缀
+	Transport string            `yaml:"transport"` // inprocess / stdio / sse / http
+	Command   string            `yaml:"command"`   // stdio：启动的命令
+	Args      []string          `yaml:"args"`
+	Env       map[string]string `yaml:"env"`     // stdio：追加到子进程的环境变量
+	URL       string            `yaml:"url"`     // sse / http：服务端地址
+	Headers   map[string]string `yaml:"headers"` // sse / http：附加的请求头，如鉴权
+	Tools     []string          `yaml:"tools"`   // 工具白名单（原始名称），为空时暴露全部工具
+}
+
+type serversFile struct {
+	Servers []ServerConfig `yaml:"servers"`
+}
+
+// loadServers 读取内置数据库服务器与 MCP_SERVERS_PATH 中的服务器配置，
+// 配置文件中同名的 mysql 服务器会覆盖内置配置。值中的 ${VAR} 按环境变量展开
+func loadServers(path string) ([]ServerConfig, error) {
+	servers := []ServerConfig{sqlServerConfig()}
+	if err := validate(servers[0]); err != nil {
+		return nil, fmt.Errorf("MCP_SERVER_MODE 配置错误: %w", err)
+	}
+	if path == "" {
+		return servers, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 MCP 服务器配置失败: %w", err)
+	}
+	var file serversFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析 MCP 服务器配置失败: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, s := range file.Servers {
+		s = expand(s)
+		if err := validate(s); err != nil {
+			return nil, err
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("MCP 服务器名称重复: %s", s.Name)
+		}
+		seen[s.Name] = true
+
+		if s.Name == SQLServer {
+			servers[0] = s
+			continue
+		}
+		servers = append(servers, s)
+	}
+	return servers, nil
+}
+
+// sqlServerConfig 按 MCP_SERVER_MODE 生成内置数据库服务器配置
+func sqlServerConfig() ServerConfig {
+	conf := ServerConfig{
+		Name:      SQLServer,
+		Transport: TransportStdio,
+		Tools:     []string{"mysql_query", "list_tables", "describe_table"},
+		Env: map[string]string{
+			"MYSQL_HOST": config.Cfg.MySQLConf.Host,
+			"MYSQL_PORT": config.Cfg.MySQLConf.Port,
+			"MYSQL_USER": config.Cfg.MySQLConf.Username,
+			"MYSQL_PASS": config.Cfg.MySQLConf.Password,
+			"MYSQL_DB":   config.Cfg.MySQLConf.Database,
+		},
+	}
+	switch config.Cfg.MCPConf.Mode {
+	case "", "inprocess":
+		conf.Transport = TransportInProcess
+		conf.Env = nil
+	case "stdio":
+		conf.Command = config.Cfg.MCPConf.ServerPath
+	case "npx":
+		conf.Command = "npx"
+		conf.Args = []string{"-y", "mcp-server-mysql"}
+	default:
+		// 未知模式交给 validate 报错
+		conf.Transport = config.Cfg.MCPConf.Mode
+	}
+	return conf
+}
+
+func validate(s ServerConfig) error {
+	if s.Name == "" {
+		return fmt.Errorf("MCP 服务器缺少 name")
+	}
+	switch s.Transport {
+	case TransportInProcess:
+		if _, ok := inProcessServers[s.Name]; !ok {
+			return fmt.Errorf("MCP 服务器 %s: 没有同名的进程内服务器", s.Name)
+		}
+	case TransportStdio:
+		if s.Command == "" {
+			return fmt.Errorf("MCP 服务器 %s: stdio 模式需要 command", s.Name)
+		}
+	case TransportSSE, TransportHTTP:
+		if s.URL == "" {
+			return fmt.Errorf("MCP 服务器 %s: %s 模式需要 url", s.Name, s.Transport)
+		}
+	default:
+		return fmt.Errorf("MCP 服务器 %s: 未知的传输方式 %q", s.Name, s.Transport)
+	}
+	return nil
+}
+
+func expand(s ServerConfig) ServerConfig {
+	s.Command = os.ExpandEnv(s.Command)
+	s.URL = os.ExpandEnv(s.URL)
+	for i, arg := range s.Args {
+		s.Args[i] = os.ExpandEnv(arg)
+	}
+	for k, v := range s.Env {
+		s.Env[k] = os.ExpandEnv(v)
+	}
+	for k, v := range s.Headers {
+		s.Headers[k] = os.ExpandEnv(v)
+	}
+	return s
+}
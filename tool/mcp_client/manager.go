@@ -0,0 +1,69 @@
+package mcp_client
+
+import (
+	"context"
+	"fmt"
+	"go-agent/config"
+	"log"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+var (
+	servers     map[string]*server
+	serverOrder []string
+)
+
+// InitMCPClients 读取 MCP 服务器配置，连接在首次获取工具时建立
+func InitMCPClients(ctx context.Context) error {
+	confs, err := loadServers(config.Cfg.MCPConf.ServersPath)
+	if err != nil {
+		return err
+	}
+
+	servers = make(map[string]*server, len(confs))
+	serverOrder = make([]string, 0, len(confs))
+	for _, conf := range confs {
+		servers[conf.Name] = newServer(conf)
+		serverOrder = append(serverOrder, conf.Name)
+	}
+	return nil
+}
+
+// GetTools 返回指定服务器的工具，工具名为 {server}__{tool}，可直接用于任意图的 ToolsNode。
+// 指定服务器时任一连接失败即返回错误；不指定时合并全部服务器，连接失败的服务器记录日志后跳过
+func GetTools(ctx context.Context, names ...string) ([]tool.BaseTool, error) {
+	if servers == nil {
+		return nil, fmt.Errorf("MCP 客户端未初始化，请先调用 InitMCPClients")
+	}
+
+	strict := len(names) > 0
+	if !strict {
+		names = serverOrder
+	}
+
+	var tools []tool.BaseTool
+	for _, name := range names {
+		srv, ok := servers[name]
+		if !ok {
+			return nil, fmt.Errorf("未配置的 MCP 服务器: %s", name)
+		}
+		list, err := srv.list(ctx)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			log.Printf("跳过 MCP 服务器 %s: %v", name, err)
+			continue
+		}
+		tools = append(tools, list...)
+	}
+	return tools, nil
+}
+
+// Close 关闭所有已建立的连接
+func Close() {
+	for _, srv := range servers {
+		srv.close()
+	}
+}
@@ -0,0 +1,238 @@
+package mcp_client
+
+import (
+	"context"
+	"fmt"
+	"go-agent/mcp_server"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/cloudwego/eino-ext/components/tool/mcp/officialmcp"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// inProcessServers 可通过 inprocess 方式连接的内置服务器，key 为服务器名称
+var inProcessServers = map[string]*inProcessServer{
+	SQLServer: {build: func(ctx context.Context) (*mcp.Server, error) {
+		opts, err := mcp_server.OptionsFromConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return mcp_server.NewServer(opts), nil
+	}},
+}
+
+// inProcessServer 内置服务器在首次连接时创建，之后的重连复用同一个服务器及其数据库连接池
+type inProcessServer struct {
+	build func(ctx context.Context) (*mcp.Server, error)
+
+	mu  sync.Mutex
+	srv *mcp.Server
+}
+
+// get 返回已创建的服务器，创建失败时不缓存，下次连接时重试
+func (p *inProcessServer) get(ctx context.Context) (*mcp.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.srv == nil {
+		srv, err := p.build(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.srv = srv
+	}
+	return p.srv, nil
+}
+
+// server 一个 MCP 服务器的客户端连接，首次使用时建立，连接断开（如子进程退出）后在下次调用时重建
+type server struct {
+	conf ServerConfig
+	cli  *mcp.Client
+
+	mu            sync.Mutex
+	session       *mcp.ClientSession
+	serverSession *mcp.ServerSession            // inprocess 方式下服务端的会话，随客户端会话一起关闭
+	tools         map[string]tool.InvokableTool // 原始工具名 -> 当前会话上的工具
+	proxies       []tool.BaseTool               // 首次连接时生成的带命名空间工具，重连后保持不变
+}
+
+func newServer(conf ServerConfig) *server {
+	return &server{
+		conf: conf,
+		cli: mcp.NewClient(&mcp.Implementation{
+			Name:    "go-agent-client",
+			Version: "1.0.0",
+		}, nil),
+	}
+}
+
+// list 返回该服务器暴露的带命名空间工具
+func (s *server) list(ctx context.Context) ([]tool.BaseTool, error) {
+	if _, _, err := s.connect(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.proxies, nil
+}
+
+// tool 返回当前会话上的原始工具，未连接时先连接
+func (s *server) tool(ctx context.Context, name string) (*mcp.ClientSession, tool.InvokableTool, error) {
+	session, tools, err := s.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, ok := tools[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("MCP 服务器 %s 不再提供工具 %s", s.conf.Name, name)
+	}
+	return session, t, nil
+}
+
+func (s *server) connect(ctx context.Context) (*mcp.ClientSession, map[string]tool.InvokableTool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session != nil {
+		return s.session, s.tools, nil
+	}
+
+	// 连接的生命周期独立于触发连接的请求
+	ctx = context.WithoutCancel(ctx)
+	transport, err := s.transport(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := s.cli.Connect(ctx, transport, nil)
+	if err != nil {
+		s.closeServerSession()
+		return nil, nil, fmt.Errorf("MCP 服务器 %s 连接失败: %w", s.conf.Name, err)
+	}
+	list, err := officialmcp.GetTools(ctx, &officialmcp.Config{
+		Cli:          session,
+		ToolNameList: s.conf.Tools,
+	})
+	if err != nil {
+		_ = session.Close()
+		s.closeServerSession()
+		return nil, nil, fmt.Errorf("MCP 服务器 %s 获取工具失败: %w", s.conf.Name, err)
+	}
+
+	tools := make(map[string]tool.InvokableTool, len(list))
+	proxies := make([]tool.BaseTool, 0, len(list))
+	for _, t := range list {
+		invokable, ok := t.(tool.InvokableTool)
+		if !ok {
+			continue
+		}
+		info, err := invokable.Info(ctx)
+		if err != nil {
+			_ = session.Close()
+			s.closeServerSession()
+			return nil, nil, err
+		}
+		tools[info.Name] = invokable
+		proxies = append(proxies, newProxyTool(s, info))
+	}
+
+	s.session, s.tools = session, tools
+	if s.proxies == nil {
+		s.proxies = proxies
+	}
+	go s.watch(session)
+	log.Printf("MCP 服务器 %s 已连接，工具数: %d", s.conf.Name, len(tools))
+	return session, tools, nil
+}
+
+// watch 等待会话结束，结束后丢弃该会话，下次调用时重连
+func (s *server) watch(session *mcp.ClientSession) {
+	err := session.Wait()
+	if s.drop(session) {
+		log.Printf("MCP 服务器 %s 连接已断开: %v，将在下次调用时重连", s.conf.Name, err)
+	}
+}
+
+// drop 丢弃指定会话，会话已被替换时不做处理
+func (s *server) drop(session *mcp.ClientSession) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session != session {
+		return false
+	}
+	s.session, s.tools = nil, nil
+	_ = session.Close()
+	s.closeServerSession()
+	return true
+}
+
+// closeServerSession 关闭 inprocess 服务端的会话，调用方需持有 s.mu
+func (s *server) closeServerSession() {
+	if s.serverSession != nil {
+		_ = s.serverSession.Close()
+		s.serverSession = nil
+	}
+}
+
+func (s *server) close() {
+	s.mu.Lock()
+	session := s.session
+	s.mu.Unlock()
+	if session != nil {
+		s.drop(session)
+	}
+}
+
+// transport 每次连接都创建新的传输，stdio 子进程不能复用。调用方需持有 s.mu
+func (s *server) transport(ctx context.Context) (mcp.Transport, error) {
+	switch s.conf.Transport {
+	case TransportInProcess:
+		srv, err := inProcessServers[s.conf.Name].get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clientTransport, serverTransport := mcp.NewInMemoryTransports()
+		ss, err := srv.Connect(ctx, serverTransport, nil)
+		if err != nil {
+			return nil, fmt.Errorf("MCP 服务器启动失败: %w", err)
+		}
+		s.serverSession = ss
+		return clientTransport, nil
+	case TransportStdio:
+		cmd := exec.Command(s.conf.Command, s.conf.Args...)
+		cmd.Env = os.Environ()
+		for k, v := range s.conf.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		cmd.Stderr = os.Stderr
+		return &mcp.CommandTransport{Command: cmd}, nil
+	case TransportSSE:
+		return &mcp.SSEClientTransport{Endpoint: s.conf.URL, HTTPClient: s.httpClient()}, nil
+	case TransportHTTP:
+		return &mcp.StreamableClientTransport{Endpoint: s.conf.URL, HTTPClient: s.httpClient()}, nil
+	}
+	return nil, fmt.Errorf("MCP 服务器 %s: 未知的传输方式 %q", s.conf.Name, s.conf.Transport)
+}
+
+func (s *server) httpClient() *http.Client {
+	if len(s.conf.Headers) == 0 {
+		return nil
+	}
+	return &http.Client{Transport: &headerTransport{headers: s.conf.Headers, base: http.DefaultTransport}}
+}
+
+// headerTransport 为每个请求附加固定请求头
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
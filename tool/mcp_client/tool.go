@@ -0,0 +1,55 @@
+package mcp_client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// NamespaceSep 服务器名与工具名之间的分隔符
+const NamespaceSep = "__"
+
+// ToolName 返回带服务器命名空间的工具名，如 mysql__mysql_query
+func ToolName(server, name string) string {
+	return server + NamespaceSep + name
+}
+
+// proxyTool 以带命名空间的名称暴露 MCP 工具，调用时转发到服务器的当前会话
+type proxyTool struct {
+	srv  *server
+	name string // 服务器上的原始工具名
+	info *schema.ToolInfo
+}
+
+func newProxyTool(srv *server, info *schema.ToolInfo) *proxyTool {
+	namespaced := *info
+	namespaced.Name = ToolName(srv.conf.Name, info.Name)
+	return &proxyTool{srv: srv, name: info.Name, info: &namespaced}
+}
+
+func (t *proxyTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+// InvokableRun 错误格式与 officialmcp 保持一致；连接已关闭时重连并重试一次
+func (t *proxyTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	session, inner, err := t.srv.tool(ctx, t.name)
+	if err != nil {
+		return "", err
+	}
+	output, err := inner.InvokableRun(ctx, argumentsInJSON, opts...)
+	if !errors.Is(err, mcp.ErrConnectionClosed) {
+		return output, err
+	}
+
+	// 请求未能发出（如子进程已退出），丢弃旧会话后重试
+	t.srv.drop(session)
+	_, inner, err = t.srv.tool(ctx, t.name)
+	if err != nil {
+		return "", err
+	}
+	return inner.InvokableRun(ctx, argumentsInJSON, opts...)
+}
@@ -2,12 +2,16 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 )
 
+// keepRecent 压缩后保留为短期记忆的最近消息数
+const keepRecent = 3
+
 type Summarizer struct {
 	Model         model.BaseChatModel
 	MaxHistoryLen int
@@ -32,24 +36,68 @@ func (s *Summarizer) Compress(ctx context.Context, sess *Session) error {
 
 	// 划分：前N轮需要压缩，后M轮保留为短期记忆
 	// 比如保留最近3轮
-	toCompress := sess.History[:len(sess.History)-3]
-	sess.History = sess.History[len(sess.History)-3:]
+	toCompress := sess.History[:len(sess.History)-keepRecent]
+	summary, err := s.summarize(ctx, sess.Summary, toCompress)
+	if err != nil {
+		return err
+	}
+
+	sess.History = sess.History[len(sess.History)-keepRecent:]
+	sess.Summary = summary
+	return nil
+}
 
+// Compact 压缩存储中的会话。调用模型期间不持有任何锁，摘要生成后以乐观锁写回：
+// 期间追加的新消息会保留，其他请求已先完成压缩或删除了会话时放弃本次结果
+func (s *Summarizer) Compact(ctx context.Context, store Store, sessionID string) error {
+	sess, err := store.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if len(sess.History) <= s.MaxHistoryLen || len(sess.History) <= keepRecent {
+		return nil
+	}
+
+	n := len(sess.History) - keepRecent
+	summary, err := s.summarize(ctx, sess.Summary, sess.History[:n])
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		cur, err := store.Get(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		// 摘要变化或历史变短，说明会话已被其他请求压缩或删除
+		if cur.Summary != sess.Summary || len(cur.History) < n {
+			return nil
+		}
+		cur.History = cur.History[n:]
+		cur.Summary = summary
+		err = store.Save(ctx, sessionID, cur)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+// summarize 将已有摘要与较早的消息合并为新的摘要
+func (s *Summarizer) summarize(ctx context.Context, previous string, msgs []*schema.Message) (string, error) {
 	// 格式化待压缩的消息
 	historyText := ""
-	for _, m := range toCompress {
+	for _, m := range msgs {
 		historyText += fmt.Sprintf("[%s]: %s\n", m.Role, m.Content)
 	}
 
 	// 调用LLM生成新摘要
-	prompt := fmt.Sprintf(SummaryPrompt, sess.Summary, historyText)
+	prompt := fmt.Sprintf(SummaryPrompt, previous, historyText)
 	resp, err := s.Model.Generate(ctx, []*schema.Message{
 		schema.UserMessage(prompt),
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	sess.Summary = resp.Content
-	return nil
+	return resp.Content, nil
 }
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/config"
+	"go-agent/tool/storage"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDuplicateEntry MySQL 主键冲突的错误码
+const errDuplicateEntry = 1062
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func initMySQL() {
+	registerStore("mysql", func(ctx context.Context) (Store, error) {
+		return NewMySQLStore(ctx, config.Cfg.MemoryConf.Table, storeTTL())
+	})
+}
+
+// MySQLStore 将会话序列化为 JSON 存入 MySQL 表，表不存在时自动创建；
+// 每行带版本号，写入时以 version 作为条件实现乐观锁
+type MySQLStore struct {
+	db    *sql.DB
+	table string
+	ttl   time.Duration
+}
+
+// NewMySQLStore 创建 MySQL 会话记忆，ttl 为 0 时会话不过期
+func NewMySQLStore(ctx context.Context, table string, ttl time.Duration) (*MySQLStore, error) {
+	if table == "" {
+		table = "chat_memory"
+	}
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("非法的会话记忆表名: %s", table)
+	}
+
+	db, err := storage.NewMySQL(ctx, config.Cfg.MemoryConf.MySQL)
+	if err != nil {
+		return nil, fmt.Errorf("初始化会话记忆库失败: %w", err)
+	}
+
+	s := &MySQLStore{db: db, table: table, ttl: ttl}
+	if err := s.createTable(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	log.Printf("会话记忆写入 MySQL 表: %s", table)
+	return s, nil
+}
+
+func (s *MySQLStore) createTable(ctx context.Context) error {
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ("+
+		"`session_id` VARCHAR(128) NOT NULL PRIMARY KEY,"+
+		"`data` LONGTEXT NOT NULL,"+
+		"`version` BIGINT NOT NULL,"+
+		"`messages` INT NOT NULL DEFAULT 0,"+
+		"`has_summary` TINYINT(1) NOT NULL DEFAULT 0,"+
+		"`updated_at` DATETIME(3) NOT NULL,"+
+		"`expires_at` DATETIME(3) NULL,"+
+		"KEY `idx_updated_at` (`updated_at`),"+
+		"KEY `idx_expires_at` (`expires_at`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", s.table)
+	if _, err := s.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("创建会话记忆表失败: %w", err)
+	}
+	return nil
+}
+
+func (s *MySQLStore) Get(ctx context.Context, id string) (*Session, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM `%s` "+
+		"WHERE session_id = ? AND (expires_at IS NULL OR expires_at > ?)", s.table), id, time.Now()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Session{ID: id}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取会话记忆失败: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal([]byte(data), &sess); err != nil {
+		return nil, fmt.Errorf("解析会话记忆失败: %w", err)
+	}
+	return &sess, nil
+}
+
+func (s *MySQLStore) Save(ctx context.Context, id string, sess *Session) error {
+	result, err := s.mutate(ctx, id, saveFn(sess))
+	if err != nil {
+		return err
+	}
+	saved(sess, result)
+	return nil
+}
+
+func (s *MySQLStore) Append(ctx context.Context, id string, msgs ...*schema.Message) (*Session, error) {
+	return s.mutate(ctx, id, appendFn(msgs))
+}
+
+func (s *MySQLStore) List(ctx context.Context) ([]*SessionInfo, error) {
+	now := time.Now()
+	// 顺带清理已过期的会话
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE expires_at <= ?", s.table), now); err != nil {
+		log.Printf("清理过期会话记忆失败: %v", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT session_id, messages, has_summary, updated_at, expires_at FROM `%s` "+
+		"WHERE expires_at IS NULL OR expires_at > ? ORDER BY updated_at DESC", s.table), now)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话记忆失败: %w", err)
+	}
+	defer rows.Close()
+
+	infos := make([]*SessionInfo, 0)
+	for rows.Next() {
+		var info SessionInfo
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&info.ID, &info.Messages, &info.HasSummary, &info.UpdatedAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("读取会话记忆失败: %w", err)
+		}
+		info.ExpiresAt = expiresAt.Time
+		infos = append(infos, &info)
+	}
+	return infos, rows.Err()
+}
+
+func (s *MySQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE session_id = ?", s.table), id); err != nil {
+		return fmt.Errorf("删除会话记忆失败: %w", err)
+	}
+	return nil
+}
+
+// mutate 读取-修改-写入，以读到的 version 作为更新条件，其他请求先写入时重试
+func (s *MySQLStore) mutate(ctx context.Context, id string, fn func(cur *Session) error) (*Session, error) {
+	for i := 0; i < maxTxRetries; i++ {
+		var (
+			data    string
+			version int64
+			found   = true
+		)
+		cur := &Session{ID: id}
+		err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data, version FROM `%s` WHERE session_id = ?", s.table), id).Scan(&data, &version)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			found = false
+		case err != nil:
+			return nil, fmt.Errorf("读取会话记忆失败: %w", err)
+		default:
+			if err := json.Unmarshal([]byte(data), cur); err != nil {
+				return nil, fmt.Errorf("解析会话记忆失败: %w", err)
+			}
+			// 已过期的行视为新会话，但仍以行上的版本号做更新条件
+			if cur.expired() {
+				cur = &Session{ID: id}
+			}
+		}
+
+		if err := fn(cur); err != nil {
+			return nil, err
+		}
+		cur.ID = id
+		cur.Version = version + 1
+		cur.touch(s.ttl)
+		payload, err := json.Marshal(cur)
+		if err != nil {
+			return nil, fmt.Errorf("序列化会话记忆失败: %w", err)
+		}
+		var expiresAt sql.NullTime
+		if !cur.ExpiresAt.IsZero() {
+			expiresAt = sql.NullTime{Time: cur.ExpiresAt, Valid: true}
+		}
+
+		if !found {
+			_, err = s.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO `%s` "+
+				"(session_id, data, version, messages, has_summary, updated_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)", s.table),
+				id, string(payload), cur.Version, len(cur.History), cur.Summary != "", cur.UpdatedAt, expiresAt)
+			var myErr *mysql.MySQLError
+			if errors.As(err, &myErr) && myErr.Number == errDuplicateEntry {
+				// 其他请求同时创建了该会话，重试
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("写入会话记忆失败: %w", err)
+			}
+			return cur, nil
+		}
+
+		res, err := s.db.ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET data = ?, version = ?, messages = ?, has_summary = ?, updated_at = ?, expires_at = ? "+
+			"WHERE session_id = ? AND version = ?", s.table),
+			string(payload), cur.Version, len(cur.History), cur.Summary != "", cur.UpdatedAt, expiresAt, id, version)
+		if err != nil {
+			return nil, fmt.Errorf("写入会话记忆失败: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			return cur, nil
+		}
+		// 其他请求同时修改了该会话，重试
+	}
+	return nil, ErrConflict
+}
+
+func (s *MySQLStore) Close() error {
+	return s.db.Close()
+}
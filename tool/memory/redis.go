@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/tool/storage"
+	"log"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+const memoryPrefix = "memory"
+
+func initRedis() {
+	registerStore("redis", func(ctx context.Context) (Store, error) {
+		return NewRedisStore(storeTTL()), nil
+	})
+}
+
+// RedisStore 将会话序列化为 JSON 存入 Redis，可在多副本间共享；Redis 出错时降级到内存存储。
+// Redis 出错期间写入的会话保存在内存中，之后仍从内存读写
+type RedisStore struct {
+	client        *redis.Client
+	ttl           time.Duration
+	fallbackStore *InMemoryStore
+	fallback      storage.RedisFallback
+}
+
+// NewRedisStore 创建 Redis 会话记忆，ttl 为 0 时会话不过期
+func NewRedisStore(ttl time.Duration) *RedisStore {
+	s := &RedisStore{ttl: ttl, fallbackStore: NewMemoryStore(ttl)}
+	client, err := storage.GetRedisClient()
+	if err != nil {
+		log.Printf("Redis 不可用，会话记忆降级为内存存储: %v", err)
+		s.fallback.Disable()
+		return s
+	}
+	s.client = client
+	log.Println("会话记忆使用 Redis 存储")
+	return s
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	// 降级模式，或会话在降级期间写入内存
+	if s.useMemory(id) {
+		return s.fallbackStore.Get(ctx, id)
+	}
+
+	data, err := s.client.Get(ctx, s.makeKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return &Session{ID: id}, nil
+		}
+		// Redis出错时降级到内存模式
+		s.fallback.Trip("会话记忆", err)
+		return s.fallbackStore.Get(ctx, id)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("解析会话记忆失败: %w", err)
+	}
+	return &sess, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, id string, sess *Session) error {
+	result, err := s.mutate(ctx, id, saveFn(sess))
+	if err != nil {
+		return err
+	}
+	saved(sess, result)
+	return nil
+}
+
+func (s *RedisStore) Append(ctx context.Context, id string, msgs ...*schema.Message) (*Session, error) {
+	return s.mutate(ctx, id, appendFn(msgs))
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]*SessionInfo, error) {
+	// 降级期间写入内存的会话
+	infos, err := s.fallbackStore.List(ctx)
+	if err != nil || s.fallback.Active() {
+		return infos, err
+	}
+	inMemory := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		inMemory[info.ID] = true
+	}
+
+	iter := s.client.Scan(ctx, 0, s.makeKey("*"), 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		s.fallback.Trip("会话记忆", err)
+		return infos, nil
+	}
+
+	for start := 0; start < len(keys); start += 100 {
+		end := min(start+100, len(keys))
+		vals, err := s.client.MGet(ctx, keys[start:end]...).Result()
+		if err != nil {
+			s.fallback.Trip("会话记忆", err)
+			return s.fallbackStore.List(ctx)
+		}
+		for _, v := range vals {
+			// 扫描与读取之间过期的 key 返回 nil
+			str, ok := v.(string)
+			if !ok {
+				continue
+			}
+			var sess Session
+			if err := json.Unmarshal([]byte(str), &sess); err != nil {
+				continue
+			}
+			// 内存中的会话优先，Redis 中的旧版本不再使用
+			if !inMemory[sess.ID] {
+				infos = append(infos, sess.info())
+			}
+		}
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	_ = s.fallbackStore.Delete(ctx, id)
+	if s.fallback.Active() {
+		return nil
+	}
+	if err := s.client.Del(ctx, s.makeKey(id)).Err(); err != nil {
+		s.fallback.Trip("会话记忆", err)
+	}
+	return nil
+}
+
+// mutate 使用 WATCH 乐观锁读取-修改-写入，其他请求同时写入时重试
+func (s *RedisStore) mutate(ctx context.Context, id string, fn func(cur *Session) error) (*Session, error) {
+	// 降级模式，或会话在降级期间写入内存
+	if s.useMemory(id) {
+		return s.fallbackStore.mutate(id, fn)
+	}
+
+	key := s.makeKey(id)
+	var result *Session
+	err := storage.WatchUpdate(ctx, s.client, key, func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		cur := &Session{ID: id}
+		if exists {
+			if err := json.Unmarshal(data, cur); err != nil {
+				return fmt.Errorf("解析会话记忆失败: %w", err)
+			}
+		}
+		if err := fn(cur); err != nil {
+			return err
+		}
+		cur.ID = id
+		cur.Version++
+		cur.touch(s.ttl)
+		data, err := json.Marshal(cur)
+		if err != nil {
+			return fmt.Errorf("序列化会话记忆失败: %w", err)
+		}
+		pipe.Set(ctx, key, data, s.ttl)
+		result = cur
+		return nil
+	})
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.Is(err, storage.ErrTxConflict):
+		return nil, ErrConflict
+	case errors.Is(err, storage.ErrRedis):
+		// Redis出错时降级到内存模式
+		s.fallback.Trip("会话记忆", err)
+		return s.fallbackStore.mutate(id, fn)
+	default:
+		return nil, err
+	}
+}
+
+// useMemory 当前处于降级模式，或会话由内存存储管理
+func (s *RedisStore) useMemory(id string) bool {
+	return s.fallbackStore.contains(id) || s.fallback.Active()
+}
+
+// makeKey 生成Redis key: memory:{sessionID}
+func (s *RedisStore) makeKey(id string) string {
+	return fmt.Sprintf("%s:%s", memoryPrefix, id)
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/eino/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T, ttl time.Duration) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return &RedisStore{client: client, ttl: ttl, fallbackStore: NewMemoryStore(ttl)}, mr
+}
+
+func TestRedisStoreVersioning(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t, time.Hour)
+
+	sess, err := s.Get(ctx, "s1")
+	if err != nil || sess.Version != 0 || len(sess.History) != 0 {
+		t.Fatalf("Get = %+v, %v", sess, err)
+	}
+
+	// 后台压缩读取后，新的一轮对话先追加了消息
+	if _, err := s.Append(ctx, "s1", schema.UserMessage("q1"), schema.AssistantMessage("a1", nil)); err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := s.Get(ctx, "s1")
+	appended, err := s.Append(ctx, "s1", schema.UserMessage("q2"))
+	if err != nil || appended.Version != 2 || len(appended.History) != 3 {
+		t.Fatalf("Append = %+v, %v", appended, err)
+	}
+
+	// 基于旧版本的整体写入被拒绝，不覆盖新追加的消息
+	stale.Summary = "摘要"
+	if err := s.Save(ctx, "s1", stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("err = %v, want ErrConflict", err)
+	}
+	fresh, _ := s.Get(ctx, "s1")
+	fresh.Summary = "摘要"
+	if err := s.Save(ctx, "s1", fresh); err != nil || fresh.Version != 3 {
+		t.Errorf("Save = %v, version = %d", err, fresh.Version)
+	}
+	if ttl := mr.TTL("memory:s1"); ttl != time.Hour {
+		t.Errorf("TTL = %v, want 1h", ttl)
+	}
+
+	if _, err := s.Append(ctx, "s2", schema.UserMessage("hi")); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := s.List(ctx)
+	if err != nil || len(infos) != 2 || infos[0].ID != "s2" {
+		t.Errorf("List = %v, %v", infos, err)
+	}
+	if err := s.Delete(ctx, "s2"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("memory:s2") {
+		t.Error("Delete 后 Redis 中仍有会话")
+	}
+}
+
+func TestRedisStoreFallback(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t, time.Hour)
+
+	if _, err := s.Append(ctx, "before", schema.UserMessage("q")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redis 出错期间的写入保存在内存中
+	mr.Close()
+	if _, err := s.Append(ctx, "during", schema.UserMessage("q")); err != nil {
+		t.Fatal(err)
+	}
+	if !s.fallback.Active() {
+		t.Error("Redis 出错后应降级到内存存储")
+	}
+	sess, err := s.Get(ctx, "during")
+	if err != nil || len(sess.History) != 1 {
+		t.Errorf("Get = %+v, %v", sess, err)
+	}
+
+	// 降级结束后，降级期间写入的会话仍从内存读写，其他会话回到 Redis
+	recovered := &RedisStore{client: s.client, ttl: s.ttl, fallbackStore: s.fallbackStore}
+	if !recovered.useMemory("during") || recovered.useMemory("before") {
+		t.Error("降级期间写入的会话应继续使用内存存储，其他会话回到 Redis")
+	}
+}
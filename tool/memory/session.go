@@ -9,8 +9,51 @@ import (
 const SummaryMessageExtraKey = "_is_summary_message"
 
 type Session struct {
-	ID        string
-	History   []*schema.Message
-	Summary   string
-	UpdatedAt time.Time
+	ID        string            `json:"id"`
+	History   []*schema.Message `json:"history"`
+	Summary   string            `json:"summary,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"` // 为零值时不过期
+	// Version 乐观锁版本号，每次写入递增，Save 时与存储中的版本不一致即视为并发冲突
+	Version int64 `json:"version"`
+}
+
+// SessionInfo 会话列表中的概要信息，不含消息内容
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Messages   int       `json:"messages"`
+	HasSummary bool      `json:"has_summary"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+// expired 是否已过期，内存与 MySQL 存储依赖该字段判断
+func (s *Session) expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+// clone 复制会话，History 使用新的切片，避免调用方修改存储中的数据
+func (s *Session) clone() *Session {
+	cp := *s
+	cp.History = append([]*schema.Message(nil), s.History...)
+	return &cp
+}
+
+// touch 刷新更新时间与过期时间，ttl 为 0 时不过期
+func (s *Session) touch(ttl time.Duration) {
+	s.UpdatedAt = time.Now()
+	s.ExpiresAt = time.Time{}
+	if ttl > 0 {
+		s.ExpiresAt = s.UpdatedAt.Add(ttl)
+	}
+}
+
+func (s *Session) info() *SessionInfo {
+	return &SessionInfo{
+		ID:         s.ID,
+		Messages:   len(s.History),
+		HasSummary: s.Summary != "",
+		UpdatedAt:  s.UpdatedAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
 }
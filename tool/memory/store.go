@@ -2,37 +2,208 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"go-agent/config"
+	"io"
+	"log"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultTTL   = 7 * 24 * time.Hour // MEMORY_TTL 未配置或无法解析时的默认过期时间
+	maxTxRetries = 5                  // 乐观锁冲突时的最大重试次数
 )
 
+var ErrConflict = errors.New("memory session modified concurrently")
+
+// Store 会话记忆存储，Save 与 Append 基于 Session.Version 做乐观并发控制
 type Store interface {
+	// Get 读取会话，不存在或已过期时返回只有 ID 的空会话
 	Get(ctx context.Context, sessionID string) (*Session, error)
+	// Save 整体写入会话，存储中的版本与 session.Version 不一致时返回 ErrConflict，成功后更新 session.Version
 	Save(ctx context.Context, sessionID string, session *Session) error
+	// Append 原子地向会话追加消息，并发冲突时自动重试，返回写入后的会话
+	Append(ctx context.Context, sessionID string, msgs ...*schema.Message) (*Session, error)
+	// List 按更新时间倒序列出未过期的会话
+	List(ctx context.Context) ([]*SessionInfo, error)
+	Delete(ctx context.Context, sessionID string) error
+}
+
+type StoreFactory func(ctx context.Context) (Store, error)
+
+var storeRegistry = make(map[string]StoreFactory)
+
+// Default 全局会话记忆，在 InitStore 中初始化
+var Default Store = NewMemoryStore(0)
+
+// registerStore 用于具体存储在初始化时注册自己
+func registerStore(name string, factory StoreFactory) {
+	storeRegistry[name] = factory
+}
+
+// InitStore 按 MEMORY_STORE_TYPE 创建全局会话记忆
+func InitStore(ctx context.Context) error {
+	initInMemory()
+	initRedis()
+	initMySQL()
+
+	name := config.Cfg.MemoryConf.Type
+	create, ok := storeRegistry[name]
+	if !ok {
+		return fmt.Errorf("未注册的会话记忆存储类型: %s", name)
+	}
+	s, err := create(ctx)
+	if err != nil {
+		return err
+	}
+	Default = s
+	return nil
+}
+
+// Close 关闭全局会话记忆持有的连接
+func Close() error {
+	if c, ok := Default.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// storeTTL 解析 MEMORY_TTL，配置为 0 时会话不过期
+func storeTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.Cfg.MemoryConf.TTL)
+	if err != nil || ttl < 0 {
+		return defaultTTL
+	}
+	return ttl
+}
+
+// saveFn 整体替换会话内容，存储中的版本与 sess.Version 不一致时放弃写入
+func saveFn(sess *Session) func(cur *Session) error {
+	return func(cur *Session) error {
+		if cur.Version != sess.Version {
+			return ErrConflict
+		}
+		cur.History = append([]*schema.Message(nil), sess.History...)
+		cur.Summary = sess.Summary
+		return nil
+	}
+}
+
+// appendFn 在存储中的最新会话上追加消息
+func appendFn(msgs []*schema.Message) func(cur *Session) error {
+	return func(cur *Session) error {
+		cur.History = append(cur.History, msgs...)
+		return nil
+	}
+}
+
+// saved 将写入后的版本与时间回写到调用方的会话
+func saved(sess, result *Session) {
+	sess.ID = result.ID
+	sess.Version = result.Version
+	sess.UpdatedAt = result.UpdatedAt
+	sess.ExpiresAt = result.ExpiresAt
+}
+
+// sortInfos 按更新时间倒序排列
+func sortInfos(infos []*SessionInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UpdatedAt.After(infos[j].UpdatedAt)
+	})
+}
+
+func initInMemory() {
+	registerStore("memory", func(ctx context.Context) (Store, error) {
+		log.Println("会话记忆使用内存存储，重启后丢失")
+		return NewMemoryStore(storeTTL()), nil
+	})
 }
 
+// InMemoryStore 进程内的会话记忆，不能在多副本间共享，也用作 Redis 不可用时的降级存储
 type InMemoryStore struct {
 	mu   sync.RWMutex
 	data map[string]*Session
+	ttl  time.Duration
 }
 
-func NewMemoryStore() *InMemoryStore {
-	return &InMemoryStore{data: make(map[string]*Session)}
+// NewMemoryStore 创建内存存储，ttl 为 0 时会话不过期
+func NewMemoryStore(ttl time.Duration) *InMemoryStore {
+	return &InMemoryStore{data: make(map[string]*Session), ttl: ttl}
 }
 
 func (s *InMemoryStore) Get(ctx context.Context, id string) (*Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if sess, ok := s.data[id]; ok {
-		return sess, nil
+	if sess, ok := s.data[id]; ok && !sess.expired() {
+		return sess.clone(), nil
 	}
 
 	return &Session{ID: id}, nil
 }
 
 func (s *InMemoryStore) Save(ctx context.Context, id string, sess *Session) error {
+	result, err := s.mutate(id, saveFn(sess))
+	if err != nil {
+		return err
+	}
+	saved(sess, result)
+	return nil
+}
+
+func (s *InMemoryStore) Append(ctx context.Context, id string, msgs ...*schema.Message) (*Session, error) {
+	return s.mutate(id, appendFn(msgs))
+}
+
+func (s *InMemoryStore) List(ctx context.Context) ([]*SessionInfo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[id] = sess
+	infos := make([]*SessionInfo, 0, len(s.data))
+	for id, sess := range s.data {
+		if sess.expired() {
+			delete(s.data, id)
+			continue
+		}
+		infos = append(infos, sess.info())
+	}
+	sortInfos(infos)
+	return infos, nil
+}
 
+func (s *InMemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.data, id)
+	s.mu.Unlock()
 	return nil
 }
+
+// contains 会话是否保存在内存中（包括已过期未清理的）
+func (s *InMemoryStore) contains(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.data[id]
+	return ok
+}
+
+// mutate 在互斥锁内读取-修改-写入，fn 返回错误时放弃修改
+func (s *InMemoryStore) mutate(id string, fn func(cur *Session) error) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := &Session{ID: id}
+	if old, ok := s.data[id]; ok && !old.expired() {
+		cur = old.clone()
+	}
+	if err := fn(cur); err != nil {
+		return nil, err
+	}
+	cur.ID = id
+	cur.Version++
+	cur.touch(s.ttl)
+	s.data[id] = cur
+	return cur.clone(), nil
+}
@@ -0,0 +1,419 @@
+package sql_guard
+
+import (
+	"fmt"
+	"go-agent/config"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	_ "github.com/pingcap/tidb/pkg/parser/test_driver"
+)
+
+// StatementType 语句类型
+type StatementType string
+
+const (
+	StatementRead    StatementType = "read"
+	StatementWrite   StatementType = "write"
+	StatementDDL     StatementType = "ddl"
+	StatementUnknown StatementType = "unknown"
+)
+
+// RiskLevel 风险等级
+type RiskLevel string
+
+const (
+	RiskLow     RiskLevel = "low"     // 只读查询
+	RiskMedium  RiskLevel = "medium"  // 带条件的数据修改
+	RiskHigh    RiskLevel = "high"    // DDL 等结构变更
+	RiskBlocked RiskLevel = "blocked" // 被安全策略拦截，不允许执行
+)
+
+// 无论策略如何都拦截的函数
+var forbiddenFuncs = map[string]bool{
+	"load_file": true,
+	"sleep":     true,
+	"benchmark": true,
+}
+
+// Policy 安全策略
+type Policy struct {
+	AllowTables []string // 表白名单，为空时不限制
+	DenyTables  []string // 表黑名单
+	AllowWrite  bool     // 是否允许 INSERT/UPDATE/DELETE
+	AllowDDL    bool     // 是否允许 CREATE/ALTER 等结构变更
+}
+
+// Report 单条 SQL 的检查结果
+type Report struct {
+	SQL       string        `json:"sql"`
+	Type      StatementType `json:"type"`
+	Operation string        `json:"operation,omitempty"` // 语句操作，如 SELECT、UPDATE、CREATE TABLE
+	Risk      RiskLevel     `json:"risk"`
+	Tables    []string      `json:"tables,omitempty"`
+	Reasons   []string      `json:"reasons,omitempty"` // 风险或拦截原因
+}
+
+// Blocked 是否被拦截
+func (r *Report) Blocked() bool {
+	return r.Risk == RiskBlocked
+}
+
+func (r *Report) block(format string, args ...any) {
+	r.Risk = RiskBlocked
+	r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+}
+
+// PolicyFromConfig 从全局配置构建安全策略
+func PolicyFromConfig() *Policy {
+	return &Policy{
+		AllowTables: splitList(config.Cfg.SQLConf.AllowTables),
+		DenyTables:  splitList(config.Cfg.SQLConf.DenyTables),
+		AllowWrite:  config.Cfg.SQLConf.AllowWrite == "true",
+		AllowDDL:    config.Cfg.SQLConf.AllowDDL == "true",
+	}
+}
+
+// Normalize 去掉模型输出中的 markdown 代码块标记与首尾空白
+func Normalize(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if strings.HasPrefix(sql, "```") {
+		sql = strings.TrimPrefix(sql, "```")
+		if idx := strings.Index(sql, "\n"); idx >= 0 {
+			sql = sql[idx+1:]
+		}
+		sql = strings.TrimSuffix(strings.TrimSpace(sql), "```")
+	}
+	return strings.TrimSpace(sql)
+}
+
+// Analyze 解析 SQL 并按策略给出风险等级，无法解析的 SQL 一律拦截
+func Analyze(sql string, policy *Policy) *Report {
+	report := &Report{SQL: sql, Type: StatementUnknown, Risk: RiskLow}
+
+	// parser.Parser 非并发安全，每次检查单独创建
+	stmts, _, err := parser.New().Parse(sql, "", "")
+	if err != nil {
+		report.block("SQL 解析失败: %v", err)
+		return report
+	}
+	if len(stmts) == 0 {
+		report.block("SQL 为空")
+		return report
+	}
+	if len(stmts) > 1 {
+		report.block("不允许一次执行多条语句（共 %d 条）", len(stmts))
+		return report
+	}
+
+	stmt := stmts[0]
+	v := &tableCollector{}
+	stmt.Accept(v)
+	report.Tables = v.tableList()
+	for _, fn := range v.forbidden {
+		report.block("禁止调用函数 %s", fn)
+	}
+	if v.selectInto {
+		report.block("禁止 SELECT ... INTO 导出数据")
+	}
+
+	checkStatement(report, stmt, policy)
+	checkTables(report, policy)
+	return report
+}
+
+// checkStatement 判断语句类型并检查语句本身的风险
+func checkStatement(report *Report, stmt ast.StmtNode, policy *Policy) {
+	report.Operation = operationOf(stmt)
+	switch s := stmt.(type) {
+	case *ast.SelectStmt, *ast.SetOprStmt, *ast.ShowStmt:
+		report.Type = StatementRead
+	case *ast.ExplainStmt:
+		if s.Stmt == nil {
+			report.Type = StatementRead
+			break
+		}
+		// EXPLAIN ANALYZE 会实际执行被解释的语句，按被解释的语句分类与检查，只读语句的操作仍记为 EXPLAIN
+		checkStatement(report, s.Stmt, policy)
+		if report.Type == StatementRead {
+			report.Operation = "EXPLAIN"
+		}
+		return
+	case *ast.InsertStmt:
+		report.Type = StatementWrite
+		raise(report, RiskMedium, "写入数据")
+	case *ast.UpdateStmt:
+		report.Type = StatementWrite
+		raise(report, RiskMedium, "修改数据")
+		checkWhere(report, "UPDATE", s.Where)
+	case *ast.DeleteStmt:
+		report.Type = StatementWrite
+		raise(report, RiskMedium, "删除数据")
+		checkWhere(report, "DELETE", s.Where)
+	case *ast.LoadDataStmt:
+		report.Type = StatementWrite
+		report.block("禁止 LOAD DATA 导入文件")
+	case *ast.DropDatabaseStmt, *ast.DropTableStmt, *ast.TruncateTableStmt:
+		report.Type = StatementDDL
+		report.block("禁止执行 DROP/TRUNCATE 等破坏性操作")
+	case ast.DDLNode:
+		report.Type = StatementDDL
+		raise(report, RiskHigh, "变更表结构")
+	default:
+		report.block("不支持的语句类型 %T", stmt)
+	}
+
+	switch {
+	case report.Type == StatementWrite && !policy.AllowWrite:
+		report.block("当前策略不允许写操作")
+	case report.Type == StatementDDL && !policy.AllowDDL:
+		report.block("当前策略不允许 DDL 操作")
+	}
+}
+
+// operationOf 返回语句的操作名称，用于按操作配置白名单
+func operationOf(stmt ast.StmtNode) string {
+	switch s := stmt.(type) {
+	case *ast.SelectStmt, *ast.SetOprStmt:
+		return "SELECT"
+	case *ast.ShowStmt:
+		return "SHOW"
+	case *ast.ExplainStmt:
+		return "EXPLAIN"
+	case *ast.InsertStmt:
+		if s.IsReplace {
+			return "REPLACE"
+		}
+		return "INSERT"
+	case *ast.UpdateStmt:
+		return "UPDATE"
+	case *ast.DeleteStmt:
+		return "DELETE"
+	case *ast.LoadDataStmt:
+		return "LOAD DATA"
+	case *ast.CreateTableStmt:
+		return "CREATE TABLE"
+	case *ast.AlterTableStmt:
+		return "ALTER TABLE"
+	case *ast.CreateIndexStmt:
+		return "CREATE INDEX"
+	case *ast.DropIndexStmt:
+		return "DROP INDEX"
+	case *ast.CreateViewStmt:
+		return "CREATE VIEW"
+	case *ast.DropTableStmt:
+		if s.IsView {
+			return "DROP VIEW"
+		}
+		return "DROP TABLE"
+	case *ast.DropDatabaseStmt:
+		return "DROP DATABASE"
+	case *ast.TruncateTableStmt:
+		return "TRUNCATE"
+	case *ast.RenameTableStmt:
+		return "RENAME TABLE"
+	case ast.DDLNode:
+		return "DDL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// checkWhere UPDATE/DELETE 必须带有引用列的 WHERE 条件，防止 WHERE 1=1 之类的全表操作
+func checkWhere(report *Report, op string, where ast.ExprNode) {
+	if where == nil {
+		report.block("%s 语句缺少 WHERE 条件", op)
+		return
+	}
+	v := &columnDetector{}
+	where.Accept(v)
+	if !v.found {
+		report.block("%s 语句的 WHERE 条件未引用任何列", op)
+	}
+}
+
+// checkTables 检查表黑白名单
+func checkTables(report *Report, policy *Policy) {
+	for _, table := range report.Tables {
+		if matchTable(policy.DenyTables, table) {
+			report.block("表 %s 在禁止访问列表中", table)
+		}
+		if len(policy.AllowTables) > 0 && !matchTable(policy.AllowTables, table) {
+			report.block("表 %s 不在允许访问列表中", table)
+		}
+	}
+}
+
+// TableAllowed 判断表是否允许访问
+func (p *Policy) TableAllowed(table string) bool {
+	if matchTable(p.DenyTables, table) {
+		return false
+	}
+	return len(p.AllowTables) == 0 || matchTable(p.AllowTables, table)
+}
+
+// raise 提升风险等级，已拦截时保持不变
+func raise(report *Report, level RiskLevel, reason string) {
+	if report.Risk == RiskBlocked {
+		return
+	}
+	if riskOrder(level) > riskOrder(report.Risk) {
+		report.Risk = level
+	}
+	report.Reasons = append(report.Reasons, reason)
+}
+
+func riskOrder(level RiskLevel) int {
+	switch level {
+	case RiskMedium:
+		return 1
+	case RiskHigh:
+		return 2
+	case RiskBlocked:
+		return 3
+	}
+	return 0
+}
+
+// matchTable 名单项可以是 table 或 db.table，大小写不敏感
+func matchTable(list []string, table string) bool {
+	short := table
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		short = table[idx+1:]
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, table) || strings.EqualFold(item, short) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(raw string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// tableCollector 收集语句中引用的表（排除作用域内的 CTE 名称）、禁用函数与 SELECT ... INTO
+type tableCollector struct {
+	tables     []string
+	scopes     []cteScope
+	forbidden  []string
+	selectInto bool
+}
+
+// cteScope 一个 WITH 子句声明的 CTE 名称，只在声明它的语句内可见
+type cteScope struct {
+	owner ast.Node
+	names map[string]bool
+}
+
+// withClause 返回语句自带的 WITH 子句
+func withClause(n ast.Node) *ast.WithClause {
+	switch s := n.(type) {
+	case *ast.SelectStmt:
+		return s.With
+	case *ast.SetOprStmt:
+		return s.With
+	case *ast.SetOprSelectList:
+		return s.With
+	case *ast.UpdateStmt:
+		return s.With
+	case *ast.DeleteStmt:
+		return s.With
+	}
+	return nil
+}
+
+// isCTE 不带库名的表名是否指向作用域内的 CTE
+func (v *tableCollector) isCTE(name string) bool {
+	for i := len(v.scopes) - 1; i >= 0; i-- {
+		if v.scopes[i].names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *tableCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if with := withClause(n); with != nil {
+		v.scopes = append(v.scopes, cteScope{owner: n, names: make(map[string]bool)})
+	}
+	switch node := n.(type) {
+	case *ast.WithClause:
+		// WITH RECURSIVE 的 CTE 在自身定义中即可引用；普通 CTE 在定义结束后才可见（见 Leave），定义中的同名引用指向真实的表
+		if node.IsRecursive && len(v.scopes) > 0 {
+			for _, cte := range node.CTEs {
+				v.scopes[len(v.scopes)-1].names[cte.Name.L] = true
+			}
+		}
+	case *ast.DeleteTableList:
+		// 多表 DELETE 的删除目标是 FROM 中的表或别名，表已在 FROM 中收集
+		return n, true
+	case *ast.TableName:
+		if node.Schema.O == "" && v.isCTE(node.Name.L) {
+			break
+		}
+		name := node.Name.O
+		if node.Schema.O != "" {
+			name = node.Schema.O + "." + name
+		}
+		v.tables = append(v.tables, name)
+	case *ast.SelectStmt:
+		if node.SelectIntoOpt != nil {
+			v.selectInto = true
+		}
+	case *ast.FuncCallExpr:
+		if forbiddenFuncs[node.FnName.L] {
+			v.forbidden = append(v.forbidden, node.FnName.O)
+		}
+	}
+	return n, false
+}
+
+func (v *tableCollector) Leave(n ast.Node) (ast.Node, bool) {
+	if cte, ok := n.(*ast.CommonTableExpression); ok && len(v.scopes) > 0 {
+		v.scopes[len(v.scopes)-1].names[cte.Name.L] = true
+	}
+	if len(v.scopes) > 0 && v.scopes[len(v.scopes)-1].owner == n {
+		v.scopes = v.scopes[:len(v.scopes)-1]
+	}
+	return n, true
+}
+
+// tableList 去重
+func (v *tableCollector) tableList() []string {
+	seen := make(map[string]bool)
+	list := make([]string, 0, len(v.tables))
+	for _, t := range v.tables {
+		key := strings.ToLower(t)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		list = append(list, t)
+	}
+	return list
+}
+
+// columnDetector 判断表达式中是否引用了列
+type columnDetector struct {
+	found bool
+}
+
+func (v *columnDetector) Enter(n ast.Node) (ast.Node, bool) {
+	if _, ok := n.(*ast.ColumnNameExpr); ok {
+		v.found = true
+	}
+	return n, v.found
+}
+
+func (v *columnDetector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
@@ -0,0 +1,352 @@
+package sql_guard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	readOnly := &Policy{}
+	writable := &Policy{AllowWrite: true, AllowDDL: true}
+
+	tests := []struct {
+		name      string
+		sql       string
+		policy    *Policy
+		typ       StatementType
+		risk      RiskLevel
+		reason    string // 期望出现在 Reasons 中的片段
+		tables    []string
+		operation string
+	}{
+		{
+			name:      "只读查询",
+			sql:       "SELECT id, name FROM users WHERE id = 1",
+			policy:    readOnly,
+			typ:       StatementRead,
+			risk:      RiskLow,
+			tables:    []string{"users"},
+			operation: "SELECT",
+		},
+		{
+			name:      "CTE 名称不计入表",
+			sql:       "WITH t AS (SELECT id FROM orders) SELECT * FROM t",
+			policy:    readOnly,
+			typ:       StatementRead,
+			risk:      RiskLow,
+			tables:    []string{"orders"},
+			operation: "SELECT",
+		},
+		{
+			name:   "多条语句",
+			sql:    "SELECT 1; DROP TABLE users",
+			policy: writable,
+			typ:    StatementUnknown,
+			risk:   RiskBlocked,
+			reason: "多条语句",
+		},
+		{
+			name:   "无法解析",
+			sql:    "SELEC id FROM users",
+			policy: readOnly,
+			typ:    StatementUnknown,
+			risk:   RiskBlocked,
+			reason: "解析失败",
+		},
+		{
+			name:      "带条件的更新",
+			sql:       "UPDATE users SET name = 'a' WHERE id = 1",
+			policy:    writable,
+			typ:       StatementWrite,
+			risk:      RiskMedium,
+			tables:    []string{"users"},
+			operation: "UPDATE",
+		},
+		{
+			name:   "WHERE 1=1 的更新",
+			sql:    "UPDATE users SET name = 'a' WHERE 1=1",
+			policy: writable,
+			typ:    StatementWrite,
+			risk:   RiskBlocked,
+			reason: "未引用任何列",
+		},
+		{
+			name:   "缺少 WHERE 的删除",
+			sql:    "DELETE FROM users",
+			policy: writable,
+			typ:    StatementWrite,
+			risk:   RiskBlocked,
+			reason: "缺少 WHERE",
+		},
+		{
+			name:   "策略不允许写操作",
+			sql:    "INSERT INTO users (name) VALUES ('a')",
+			policy: readOnly,
+			typ:    StatementWrite,
+			risk:   RiskBlocked,
+			reason: "不允许写操作",
+		},
+		{
+			name:      "REPLACE 归为写操作",
+			sql:       "REPLACE INTO users (id, name) VALUES (1, 'a')",
+			policy:    writable,
+			typ:       StatementWrite,
+			risk:      RiskMedium,
+			operation: "REPLACE",
+		},
+		{
+			name:      "建表归为 DDL",
+			sql:       "CREATE TABLE t (id INT)",
+			policy:    writable,
+			typ:       StatementDDL,
+			risk:      RiskHigh,
+			operation: "CREATE TABLE",
+		},
+		{
+			name:      "加索引归为 DDL",
+			sql:       "CREATE INDEX idx_name ON users (name)",
+			policy:    writable,
+			typ:       StatementDDL,
+			risk:      RiskHigh,
+			operation: "CREATE INDEX",
+		},
+		{
+			name:   "策略不允许 DDL",
+			sql:    "ALTER TABLE users ADD COLUMN age INT",
+			policy: readOnly,
+			typ:    StatementDDL,
+			risk:   RiskBlocked,
+			reason: "不允许 DDL",
+		},
+		{
+			name:      "DROP 始终拦截",
+			sql:       "DROP TABLE users",
+			policy:    writable,
+			typ:       StatementDDL,
+			risk:      RiskBlocked,
+			reason:    "破坏性操作",
+			operation: "DROP TABLE",
+		},
+		{
+			name:      "TRUNCATE 始终拦截",
+			sql:       "TRUNCATE TABLE users",
+			policy:    writable,
+			typ:       StatementDDL,
+			risk:      RiskBlocked,
+			operation: "TRUNCATE",
+		},
+		{
+			name:   "禁用函数 SLEEP",
+			sql:    "SELECT SLEEP(10)",
+			policy: readOnly,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "禁止调用函数",
+		},
+		{
+			name:   "子查询中的禁用函数",
+			sql:    "SELECT * FROM users WHERE id IN (SELECT BENCHMARK(1000000, MD5('a')))",
+			policy: readOnly,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "BENCHMARK",
+		},
+		{
+			name:   "禁止 SELECT INTO 导出",
+			sql:    "SELECT * FROM users INTO OUTFILE '/tmp/users.csv'",
+			policy: readOnly,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "INTO",
+		},
+		{
+			name:   "黑名单表",
+			sql:    "SELECT * FROM secrets",
+			policy: &Policy{DenyTables: []string{"secrets"}},
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "禁止访问",
+		},
+		{
+			name:   "黑名单按 db.table 匹配短表名",
+			sql:    "SELECT * FROM app.SECRETS",
+			policy: &Policy{DenyTables: []string{"secrets"}},
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "禁止访问",
+		},
+		{
+			name:   "不在白名单中的表",
+			sql:    "SELECT * FROM users JOIN orders ON users.id = orders.user_id",
+			policy: &Policy{AllowTables: []string{"users"}},
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			reason: "orders 不在允许访问列表中",
+		},
+		{
+			name:   "白名单内的表",
+			sql:    "SELECT * FROM users JOIN orders ON users.id = orders.user_id",
+			policy: &Policy{AllowTables: []string{"users", "orders"}},
+			typ:    StatementRead,
+			risk:   RiskLow,
+			tables: []string{"users", "orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Analyze(tt.sql, tt.policy)
+			if report.Type != tt.typ {
+				t.Errorf("Type = %s, want %s", report.Type, tt.typ)
+			}
+			if report.Risk != tt.risk {
+				t.Errorf("Risk = %s, want %s (reasons: %v)", report.Risk, tt.risk, report.Reasons)
+			}
+			if tt.reason != "" && !strings.Contains(strings.Join(report.Reasons, "; "), tt.reason) {
+				t.Errorf("Reasons = %v, want containing %q", report.Reasons, tt.reason)
+			}
+			if tt.tables != nil && strings.Join(report.Tables, ",") != strings.Join(tt.tables, ",") {
+				t.Errorf("Tables = %v, want %v", report.Tables, tt.tables)
+			}
+			if tt.operation != "" && report.Operation != tt.operation {
+				t.Errorf("Operation = %s, want %s", report.Operation, tt.operation)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"  SELECT 1  ", "SELECT 1"},
+		{"```sql\nSELECT 1\n```", "SELECT 1"},
+		{"```\nSELECT 1\n```", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyTableAllowed(t *testing.T) {
+	p := &Policy{AllowTables: []string{"users", "app.orders"}, DenyTables: []string{"users"}}
+	tests := []struct {
+		table string
+		want  bool
+	}{
+		{"users", false}, // 黑名单优先
+		{"app.orders", true},
+		{"APP.ORDERS", true},
+		{"payments", false},
+	}
+	for _, tt := range tests {
+		if got := p.TableAllowed(tt.table); got != tt.want {
+			t.Errorf("TableAllowed(%q) = %v, want %v", tt.table, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeScopesAndWrappedStatements(t *testing.T) {
+	deny := &Policy{DenyTables: []string{"salaries"}, AllowWrite: true}
+
+	tests := []struct {
+		name      string
+		sql       string
+		policy    *Policy
+		typ       StatementType
+		risk      RiskLevel
+		tables    []string
+		operation string
+	}{
+		{
+			name:   "子查询中的 CTE 不遮蔽外层的同名表",
+			sql:    "SELECT * FROM salaries WHERE id IN (WITH salaries AS (SELECT 1 AS id) SELECT id FROM salaries)",
+			policy: deny,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			tables: []string{"salaries"},
+		},
+		{
+			name:   "普通 CTE 定义中的同名引用指向真实的表",
+			sql:    "WITH salaries AS (SELECT * FROM salaries) SELECT * FROM salaries",
+			policy: deny,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			tables: []string{"salaries"},
+		},
+		{
+			name:   "递归 CTE 的自引用不计入表",
+			sql:    "WITH RECURSIVE n AS (SELECT 1 AS x UNION ALL SELECT x + 1 FROM n WHERE x < 5) SELECT * FROM n",
+			policy: &Policy{},
+			typ:    StatementRead,
+			risk:   RiskLow,
+			tables: []string{},
+		},
+		{
+			name:   "带库名的表不按 CTE 处理",
+			sql:    "WITH salaries AS (SELECT 1 AS id) SELECT * FROM hr.salaries",
+			policy: deny,
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+			tables: []string{"hr.salaries"},
+		},
+		{
+			name:   "UNION 末尾的 INTO OUTFILE",
+			sql:    "SELECT 1 UNION SELECT id FROM users INTO OUTFILE '/tmp/x'",
+			policy: &Policy{},
+			typ:    StatementRead,
+			risk:   RiskBlocked,
+		},
+		{
+			name:      "EXPLAIN 查询",
+			sql:       "EXPLAIN SELECT * FROM users",
+			policy:    &Policy{},
+			typ:       StatementRead,
+			risk:      RiskLow,
+			tables:    []string{"users"},
+			operation: "EXPLAIN",
+		},
+		{
+			name:      "EXPLAIN ANALYZE 按被解释的写操作检查",
+			sql:       "EXPLAIN ANALYZE DELETE FROM users",
+			policy:    &Policy{AllowWrite: true},
+			typ:       StatementWrite,
+			risk:      RiskBlocked,
+			operation: "DELETE",
+		},
+		{
+			name:      "EXPLAIN 写操作受写策略约束",
+			sql:       "EXPLAIN UPDATE users SET name = 'a' WHERE id = 1",
+			policy:    &Policy{},
+			typ:       StatementWrite,
+			risk:      RiskBlocked,
+			operation: "UPDATE",
+		},
+		{
+			name:      "多表 DELETE 不把别名当作表",
+			sql:       "EXPLAIN ANALYZE DELETE u FROM users u JOIN orders o ON u.id = o.user_id WHERE o.id = 1",
+			policy:    &Policy{AllowWrite: true},
+			typ:       StatementWrite,
+			risk:      RiskMedium,
+			tables:    []string{"users", "orders"},
+			operation: "DELETE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Analyze(tt.sql, tt.policy)
+			if report.Type != tt.typ || report.Risk != tt.risk {
+				t.Errorf("Type/Risk = %s/%s, want %s/%s (reasons: %v)", report.Type, report.Risk, tt.typ, tt.risk, report.Reasons)
+			}
+			if tt.tables != nil && strings.Join(report.Tables, ",") != strings.Join(tt.tables, ",") {
+				t.Errorf("Tables = %v, want %v", report.Tables, tt.tables)
+			}
+			if tt.operation != "" && report.Operation != tt.operation {
+				t.Errorf("Operation = %s, want %s", report.Operation, tt.operation)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package sql_tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/tool/mcp_client"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// ExplainRow EXPLAIN 输出中的一行
+type ExplainRow struct {
+	ID           string  `json:"id"`
+	SelectType   string  `json:"select_type"`
+	Table        string  `json:"table"`
+	Type         string  `json:"type"` // 访问类型，ALL 为全表扫描
+	PossibleKeys string  `json:"possible_keys,omitempty"`
+	Key          string  `json:"key,omitempty"` // 实际使用的索引
+	Rows         int64   `json:"rows"`          // 预估扫描行数
+	Filtered     float64 `json:"filtered"`
+	Extra        string  `json:"extra,omitempty"`
+}
+
+// ExplainPreview 审批前的执行计划预览
+type ExplainPreview struct {
+	Plan          []ExplainRow `json:"plan,omitempty"`
+	EstimatedRows int64        `json:"estimated_rows"` // 各表预估扫描行数之和
+	Tables        []string     `json:"tables,omitempty"`
+	Indexes       []string     `json:"indexes,omitempty"`
+	Warnings      []string     `json:"warnings,omitempty"` // 全表扫描、文件排序等提示
+	Error         string       `json:"error,omitempty"`    // 获取执行计划失败时的错误
+}
+
+// Explain 通过 MCP 工具对 SQL 执行 EXPLAIN，不会真正执行语句；失败时错误写入 Error 字段
+func Explain(ctx context.Context, sql string) *ExplainPreview {
+	preview := &ExplainPreview{}
+
+	result, err := callQuery(ctx, "EXPLAIN "+strings.TrimSuffix(strings.TrimSpace(sql), ";"))
+	if err != nil {
+		preview.Error = cleanToolError(err)
+		return preview
+	}
+
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(ExtractToolText(result)), &rows); err != nil {
+		preview.Error = fmt.Sprintf("解析执行计划失败: %v", err)
+		return preview
+	}
+
+	tableSeen := make(map[string]bool)
+	indexSeen := make(map[string]bool)
+	for _, r := range rows {
+		row := ExplainRow{
+			ID:           planString(r, "id"),
+			SelectType:   planString(r, "select_type"),
+			Table:        planString(r, "table"),
+			Type:         planString(r, "type"),
+			PossibleKeys: planString(r, "possible_keys"),
+			Key:          planString(r, "key"),
+			Rows:         int64(planNumber(r, "rows")),
+			Filtered:     planNumber(r, "filtered"),
+			Extra:        planString(r, "Extra"),
+		}
+		preview.Plan = append(preview.Plan, row)
+		preview.EstimatedRows += row.Rows
+
+		// <derived2>、<union1,2> 之类为临时结果集，不计入实际表
+		if row.Table != "" && !strings.HasPrefix(row.Table, "<") && !tableSeen[row.Table] {
+			tableSeen[row.Table] = true
+			preview.Tables = append(preview.Tables, row.Table)
+		}
+		if row.Key != "" && !indexSeen[row.Table+"."+row.Key] {
+			indexSeen[row.Table+"."+row.Key] = true
+			preview.Indexes = append(preview.Indexes, row.Table+"."+row.Key)
+		}
+
+		switch row.Type {
+		case "ALL":
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("表 %s 全表扫描，预计扫描 %d 行", row.Table, row.Rows))
+		case "index":
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("表 %s 全索引扫描，预计扫描 %d 行", row.Table, row.Rows))
+		}
+		if strings.Contains(row.Extra, "Using temporary") {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("表 %s 使用临时表", row.Table))
+		}
+		if strings.Contains(row.Extra, "Using filesort") {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("表 %s 使用文件排序", row.Table))
+		}
+	}
+
+	return preview
+}
+
+// planString 按列名取值，忽略大小写，NULL 返回空串
+func planString(row map[string]any, key string) string {
+	for k, v := range row {
+		if !strings.EqualFold(k, key) || v == nil {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+func planNumber(row map[string]any, key string) float64 {
+	num, _ := strconv.ParseFloat(planString(row, key), 64)
+	return num
+}
+
+// callQuery 通过 MCP 的 mysql_query 工具执行 SQL，返回工具原始输出。
+// 不经过审计中间件，只用于 EXPLAIN；业务 SQL 必须经由总控图的 ToolsNode 执行
+func callQuery(ctx context.Context, sql string) (string, error) {
+	mcpTool, err := mcp_client.GetTools(ctx, mcp_client.SQLServer)
+	if err != nil {
+		return "", err
+	}
+
+	queryTool := mcp_client.ToolName(mcp_client.SQLServer, "mysql_query")
+	var targetTool tool.InvokableTool
+	var toolNames []string
+	for _, t := range mcpTool {
+		if invokable, ok := t.(tool.InvokableTool); ok {
+			info, _ := invokable.Info(ctx)
+			toolNames = append(toolNames, info.Name)
+			if info.Name == queryTool {
+				targetTool = invokable
+				break
+			}
+		}
+	}
+
+	if targetTool == nil {
+		return "", fmt.Errorf("未找到指定的数据库执行工具, 当前可用工具: %v", toolNames)
+	}
+
+	// 使用 json.Marshal 自动处理 SQL 中的换行符和特殊字符转义
+	params := map[string]string{"sql": sql}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("序列化 MCP 参数失败: %w", err)
+	}
+
+	// 使用InvokableRun通过MCP协议发送请求到Server
+	result, err := targetTool.InvokableRun(ctx, string(paramsJSON))
+	if err != nil {
+		return "", fmt.Errorf("MCP 工具执行失败: %w", err)
+	}
+	return result, nil
+}
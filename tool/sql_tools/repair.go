@@ -0,0 +1,71 @@
+package sql_tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cloudwego/eino/compose"
+)
+
+// SQLErrorPrefix 标记 SQL 执行失败的工具输出
+const SQLErrorPrefix = "SQL_ERROR: "
+
+// mcpServerErrorMark officialmcp 在 MCP Server 返回 IsError 时的错误前缀
+const mcpServerErrorMark = "mcp server return error: "
+
+// ErrorAsResultMiddleware 将工具执行错误转换为带 SQLErrorPrefix 的输出，避免中断整个图，交给修正循环处理
+func ErrorAsResultMiddleware() compose.ToolMiddleware {
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, input *compose.ToolInput) (*compose.ToolOutput, error) {
+				output, err := next(ctx, input)
+				if err != nil {
+					return &compose.ToolOutput{Result: SQLErrorPrefix + cleanToolError(err)}, nil
+				}
+				return output, nil
+			}
+		},
+	}
+}
+
+// ParseExecError 判断工具输出是否为执行错误，是则返回错误文本
+func ParseExecError(output string) (string, bool) {
+	if !strings.HasPrefix(output, SQLErrorPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(output, SQLErrorPrefix), true
+}
+
+// ExtractToolText 从 MCP CallToolResult 的 JSON 中提取文本内容，非该格式时原样返回
+func ExtractToolText(result string) string {
+	var callResult struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(result), &callResult); err != nil || len(callResult.Content) == 0 {
+		return result
+	}
+
+	texts := make([]string, 0, len(callResult.Content))
+	for _, c := range callResult.Content {
+		if c.Type == "text" && c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return result
+	}
+	return strings.Join(texts, "\n")
+}
+
+// cleanToolError 去掉 officialmcp 的错误包装，只保留数据库返回的错误信息
+func cleanToolError(err error) string {
+	msg := err.Error()
+	if idx := strings.Index(msg, mcpServerErrorMark); idx >= 0 {
+		return ExtractToolText(msg[idx+len(mcpServerErrorMark):])
+	}
+	return msg
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// redisRetryInterval Redis 出错后暂时只使用内存模式的时长，到期后重新尝试 Redis
+const redisRetryInterval = 30 * time.Second
+
+// RedisFallback 记录 Redis 的降级状态。没有 Redis 客户端时始终使用内存模式；
+// Redis 出错后只在 redisRetryInterval 内使用内存模式，不会因为一次出错永久降级
+type RedisFallback struct {
+	noClient atomic.Bool
+	until    atomic.Int64 // 降级结束时间（UnixNano）
+}
+
+// Disable Redis 不可用时调用，之后始终使用内存模式
+func (f *RedisFallback) Disable() {
+	f.noClient.Store(true)
+}
+
+// Active 当前是否应跳过 Redis 直接使用内存模式
+func (f *RedisFallback) Active() bool {
+	return f.noClient.Load() || time.Now().UnixNano() < f.until.Load()
+}
+
+// Trip Redis 出错时调用，在 redisRetryInterval 内降级到内存模式
+func (f *RedisFallback) Trip(name string, err error) {
+	now := time.Now().UnixNano()
+	prev := f.until.Load()
+	if f.until.CompareAndSwap(prev, now+int64(redisRetryInterval)) && prev <= now {
+		log.Printf("Redis 出错，%s %s 内降级为内存模式: %v", name, redisRetryInterval, err)
+	}
+}
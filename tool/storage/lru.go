@@ -0,0 +1,69 @@
+package storage
+
+import "container/list"
+
+// lru 定长的最近最少使用缓存，容量满后淘汰最久未访问的记录。非并发安全，由调用方加锁
+type lru[V any] struct {
+	size  int
+	ll    *list.List // 头部为最近访问的记录
+	items map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any](size int) *lru[V] {
+	return &lru[V]{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get 读取记录并标记为最近访问
+func (c *lru[V]) get(key string) (V, bool) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// add 写入记录，容量满时淘汰最久未访问的一条
+func (c *lru[V]) add(key string, value V) {
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry[V]).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key: key, value: value})
+	if c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *lru[V]) remove(key string) {
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+// removeIf 删除 drop 返回 true 的记录
+func (c *lru[V]) removeIf(drop func(key string, value V) bool) {
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*lruEntry[V])
+		if drop(entry.key, entry.value) {
+			c.removeElement(e)
+		}
+		e = next
+	}
+}
+
+func (c *lru[V]) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruEntry[V]).key)
+}
+
+func (c *lru[V]) len() int {
+	return c.ll.Len()
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-agent/config"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewMySQL 按配置创建 MySQL 连接池并检查连通性
+func NewMySQL(ctx context.Context, conf config.MySQLConfig) (*sql.DB, error) {
+	dsn := mysql.NewConfig()
+	dsn.User = conf.Username
+	dsn.Passwd = conf.Password
+	dsn.Net = "tcp"
+	dsn.Addr = conf.Host + ":" + conf.Port
+	dsn.DBName = conf.Database
+	dsn.ParseTime = true
+	dsn.Loc = time.Local
+
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("MySQL 连接失败: %w", err)
+	}
+	db.SetMaxOpenConns(10)
+	db.SetConnMaxLifetime(time.Hour)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("MySQL 连接失败: %w", err)
+	}
+	return db, nil
+}
@@ -6,6 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"go-agent/config"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -13,38 +17,85 @@ import (
 )
 
 const (
-	embeddingCachePrefix = "embedding"
-	retrievalCachePrefix = "retrieval"
-	embeddingCacheTTL    = 1 * time.Hour
-	retrievalCacheTTL    = 1 * time.Hour
+	embeddingCachePrefix  = "embedding"
+	retrievalCachePrefix  = "retrieval"
+	generationCachePrefix = "retrieval_gen"
+	embeddingCacheTTL     = 1 * time.Hour
+	retrievalCacheTTL     = 1 * time.Hour
+	semanticCacheSize     = 1000
+	fallbackEmbeddingSize = 10000           // 降级模式下最多缓存的Embedding向量数
+	fallbackRetrievalSize = 1000            // 降级模式下最多缓存的召回结果数
+	fallbackSweepInterval = 1 * time.Minute // 降级模式下清理过期召回结果的最小间隔
 )
 
+var (
+	defaultRetrievalCache *RetrievalCache
+	retrievalCacheOnce    sync.Once
+)
+
+// DefaultRetrievalCache 返回进程内共享的检索缓存，需在 InitRedis 之后调用，否则只使用内存模式
+func DefaultRetrievalCache() *RetrievalCache {
+	retrievalCacheOnce.Do(func() {
+		defaultRetrievalCache = NewRetrievalCache()
+	})
+	return defaultRetrievalCache
+}
+
+// CacheScope 召回缓存的作用域：集合名加上该集合的缓存代数。
+// 集合每次写入后代数加一，旧代数下的缓存不再被读取，等待过期即可，无需逐个删除
+type CacheScope struct {
+	Collection string
+	Generation int64
+}
+
+// fallbackDocs 降级模式下的召回结果，保存序列化后的内容，避免调用方修改文档影响缓存
+type fallbackDocs struct {
+	data      []byte
+	expiresAt time.Time
+}
+
 // RetrievalCache 检索缓存管理器
 type RetrievalCache struct {
 	client      *redis.Client
-	fallbackEmb map[string][]float64          // 降级模式：Embedding缓存
-	fallbackDoc map[string][]*schema.Document // 降级模式：召回结果缓存
-	useFallback bool
+	ttl         time.Duration
+	semantic    *semanticIndex
+	mu          sync.Mutex
+	fallbackEmb *lru[[]float64]    // 降级模式：Embedding缓存
+	fallbackDoc *lru[fallbackDocs] // 降级模式：召回结果缓存
+	fallbackGen map[string]int64   // 降级模式：各集合的缓存代数
+	lastSweep   time.Time          // 降级模式：上次清理过期召回结果的时间
+	pendingGen  map[string]int     // 尚未在 Redis 中加一的集合代数及失效次数，重新使用 Redis 前补做
+	fallback    RedisFallback
 }
 
 // NewRetrievalCache 创建检索缓存管理器
 func NewRetrievalCache() *RetrievalCache {
-	client, err := GetRedisClient()
-	if err != nil {
-		// Redis不可用时使用内存模式
-		return &RetrievalCache{
-			fallbackEmb: make(map[string][]float64),
-			fallbackDoc: make(map[string][]*schema.Document),
-			useFallback: true,
+	r := &RetrievalCache{
+		ttl:         retrievalCacheTTL,
+		semantic:    newSemanticIndex(semanticCacheSize),
+		fallbackEmb: newLRU[[]float64](fallbackEmbeddingSize),
+		fallbackDoc: newLRU[fallbackDocs](fallbackRetrievalSize),
+		fallbackGen: make(map[string]int64),
+		pendingGen:  make(map[string]int),
+	}
+	if config.Cfg != nil {
+		conf := config.Cfg.CacheConf
+		if ttl, err := time.ParseDuration(conf.TTL); err == nil && ttl > 0 {
+			r.ttl = ttl
+		}
+		if size, err := strconv.Atoi(conf.SemanticSize); err == nil && size > 0 {
+			r.semantic = newSemanticIndex(size)
 		}
 	}
 
-	return &RetrievalCache{
-		client:      client,
-		fallbackEmb: make(map[string][]float64),
-		fallbackDoc: make(map[string][]*schema.Document),
-		useFallback: false,
+	client, err := GetRedisClient()
+	if err != nil {
+		// Redis不可用时使用内存模式
+		r.fallback.Disable()
+		return r
 	}
+	r.client = client
+	return r
 }
 
 // hashQuery 对查询字符串计算哈希值
@@ -58,11 +109,10 @@ func (r *RetrievalCache) GetEmbedding(ctx context.Context, query string) ([]floa
 	hash := r.hashQuery(query)
 
 	// 降级模式
-	if r.useFallback {
-		if vec, ok := r.fallbackEmb[hash]; ok {
-			return vec, true
-		}
-		return nil, false
+	if r.fallback.Active() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.fallbackEmb.get(hash)
 	}
 
 	// Redis模式
@@ -73,7 +123,7 @@ func (r *RetrievalCache) GetEmbedding(ctx context.Context, query string) ([]floa
 			return nil, false
 		}
 		// Redis出错时降级到内存模式
-		r.useFallback = true
+		r.fallback.Trip("检索缓存", err)
 		return r.GetEmbedding(ctx, query)
 	}
 
@@ -91,8 +141,10 @@ func (r *RetrievalCache) SetEmbedding(ctx context.Context, query string, embeddi
 	hash := r.hashQuery(query)
 
 	// 降级模式
-	if r.useFallback {
-		r.fallbackEmb[hash] = embedding
+	if r.fallback.Active() {
+		r.mu.Lock()
+		r.fallbackEmb.add(hash, embedding)
+		r.mu.Unlock()
 		return nil
 	}
 
@@ -107,35 +159,79 @@ func (r *RetrievalCache) SetEmbedding(ctx context.Context, query string, embeddi
 	err = r.client.Set(ctx, key, data, embeddingCacheTTL).Err()
 	if err != nil {
 		// Redis出错时降级到内存模式
-		r.useFallback = true
+		r.fallback.Trip("检索缓存", err)
 		return r.SetEmbedding(ctx, query, embedding)
 	}
 
 	return nil
 }
 
-// GetRetrieval 获取缓存的召回结果
-func (r *RetrievalCache) GetRetrieval(ctx context.Context, query string) ([]*schema.Document, bool) {
-	hash := r.hashQuery(query)
+// Scope 读取集合当前的缓存代数。检索开始时取一次并在写缓存时沿用，
+// 检索期间集合被写入时，结果会写到旧代数下，不会被之后的查询读到
+func (r *RetrievalCache) Scope(ctx context.Context, collection string) CacheScope {
+	scope := CacheScope{Collection: collection}
 
 	// 降级模式
-	if r.useFallback {
-		if docs, ok := r.fallbackDoc[hash]; ok {
-			return docs, true
-		}
-		return nil, false
+	if r.fallback.Active() {
+		r.mu.Lock()
+		scope.Generation = r.fallbackGen[collection]
+		r.mu.Unlock()
+		return scope
 	}
 
-	// Redis模式
-	key := fmt.Sprintf("%s:%s", retrievalCachePrefix, hash)
-	data, err := r.client.Get(ctx, key).Bytes()
+	// Redis模式：先补做 Redis 出错期间的失效，否则会读到失效前的缓存
+	if err := r.flushInvalidations(ctx); err != nil {
+		r.fallback.Trip("检索缓存", err)
+		return r.Scope(ctx, collection)
+	}
+	gen, err := r.client.Get(ctx, r.generationKey(collection)).Int64()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, false
+			return scope
 		}
 		// Redis出错时降级到内存模式
-		r.useFallback = true
-		return r.GetRetrieval(ctx, query)
+		r.fallback.Trip("检索缓存", err)
+		return r.Scope(ctx, collection)
+	}
+	scope.Generation = gen
+	return scope
+}
+
+// retrievalKey 召回结果的缓存键，包含集合名与缓存代数
+func (r *RetrievalCache) retrievalKey(scope CacheScope, query string) string {
+	return fmt.Sprintf("%s:%s:%d:%s", retrievalCachePrefix, scope.Collection, scope.Generation, r.hashQuery(query))
+}
+
+// GetRetrieval 获取缓存的召回结果
+func (r *RetrievalCache) GetRetrieval(ctx context.Context, scope CacheScope, query string) ([]*schema.Document, bool) {
+	key := r.retrievalKey(scope, query)
+
+	var data []byte
+	if r.fallback.Active() {
+		// 降级模式
+		r.mu.Lock()
+		entry, ok := r.fallbackDoc.get(key)
+		if ok && time.Now().After(entry.expiresAt) {
+			r.fallbackDoc.remove(key)
+			ok = false
+		}
+		r.mu.Unlock()
+		if !ok {
+			return nil, false
+		}
+		data = entry.data
+	} else {
+		// Redis模式
+		var err error
+		data, err = r.client.Get(ctx, key).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return nil, false
+			}
+			// Redis出错时降级到内存模式
+			r.fallback.Trip("检索缓存", err)
+			return r.GetRetrieval(ctx, scope, query)
+		}
 	}
 
 	// 反序列化
@@ -148,14 +244,8 @@ func (r *RetrievalCache) GetRetrieval(ctx context.Context, query string) ([]*sch
 }
 
 // SetRetrieval 缓存召回结果
-func (r *RetrievalCache) SetRetrieval(ctx context.Context, query string, docs []*schema.Document) error {
-	hash := r.hashQuery(query)
-
-	// 降级模式
-	if r.useFallback {
-		r.fallbackDoc[hash] = docs
-		return nil
-	}
+func (r *RetrievalCache) SetRetrieval(ctx context.Context, scope CacheScope, query string, docs []*schema.Document) error {
+	key := r.retrievalKey(scope, query)
 
 	// 序列化
 	data, err := json.Marshal(docs)
@@ -163,31 +253,117 @@ func (r *RetrievalCache) SetRetrieval(ctx context.Context, query string, docs []
 		return fmt.Errorf("failed to marshal documents: %w", err)
 	}
 
+	// 降级模式
+	if r.fallback.Active() {
+		r.mu.Lock()
+		r.sweepFallbackDocs()
+		r.fallbackDoc.add(key, fallbackDocs{data: data, expiresAt: time.Now().Add(r.ttl)})
+		r.mu.Unlock()
+		return nil
+	}
+
 	// 保存到Redis
-	key := fmt.Sprintf("%s:%s", retrievalCachePrefix, hash)
-	err = r.client.Set(ctx, key, data, retrievalCacheTTL).Err()
+	err = r.client.Set(ctx, key, data, r.ttl).Err()
 	if err != nil {
 		// Redis出错时降级到内存模式
-		r.useFallback = true
-		return r.SetRetrieval(ctx, query, docs)
+		r.fallback.Trip("检索缓存", err)
+		return r.SetRetrieval(ctx, scope, query, docs)
 	}
 
 	return nil
 }
 
-// InvalidateRetrieval 使指定查询的召回缓存失效
-func (r *RetrievalCache) InvalidateRetrieval(ctx context.Context, query string) error {
-	hash := r.hashQuery(query)
+// AddSemantic 记录查询向量，之后相似的查询可以复用该查询的召回结果。
+// 语义索引只保存在本进程内，多副本时各自积累，召回结果本身仍在 Redis 中共享
+func (r *RetrievalCache) AddSemantic(scope CacheScope, query string, vector []float64) {
+	r.semantic.add(scope, query, vector)
+}
 
-	// 降级模式
-	if r.useFallback {
-		delete(r.fallbackDoc, hash)
-		return nil
+// GetSemantic 查找与 vector 余弦相似度不低于 threshold 的已缓存查询，返回其召回结果与命中的查询
+func (r *RetrievalCache) GetSemantic(ctx context.Context, scope CacheScope, vector []float64, threshold float64) ([]*schema.Document, string, bool) {
+	query, ok := r.semantic.match(scope, vector, threshold)
+	if !ok {
+		return nil, "", false
+	}
+	docs, ok := r.GetRetrieval(ctx, scope, query)
+	if !ok {
+		// 召回结果已过期，语义索引中的记录随之作废
+		r.semantic.remove(scope, query)
+		return nil, "", false
 	}
+	return docs, query, true
+}
 
-	// Redis模式
-	key := fmt.Sprintf("%s:%s", retrievalCachePrefix, hash)
-	return r.client.Del(ctx, key).Err()
+// sweepFallbackDocs 清理已过期的召回结果，每 fallbackSweepInterval 最多执行一次，调用方需持有 r.mu
+func (r *RetrievalCache) sweepFallbackDocs() {
+	now := time.Now()
+	if now.Sub(r.lastSweep) < fallbackSweepInterval {
+		return
+	}
+	r.lastSweep = now
+	r.fallbackDoc.removeIf(func(_ string, entry fallbackDocs) bool {
+		return now.After(entry.expiresAt)
+	})
+}
+
+// InvalidateCollection 集合写入后调用，使该集合已有的召回缓存全部失效。
+// 内存中的缓存总是立即失效；Redis 出错时返回错误，Redis 中的代数在重新使用 Redis 前补做加一
+func (r *RetrievalCache) InvalidateCollection(ctx context.Context, collection string) error {
+	r.semantic.forget(collection)
+
+	r.mu.Lock()
+	r.fallbackGen[collection]++
+	prefix := fmt.Sprintf("%s:%s:", retrievalCachePrefix, collection)
+	r.fallbackDoc.removeIf(func(key string, _ fallbackDocs) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+	if r.client != nil {
+		r.pendingGen[collection]++
+	}
+	r.mu.Unlock()
+
+	// 降级模式：等待 Redis 恢复后补做
+	if r.fallback.Active() {
+		if r.client == nil {
+			return nil
+		}
+		return fmt.Errorf("Redis 暂不可用，集合 %s 的召回缓存将在恢复后失效", collection)
+	}
+
+	// Redis模式：代数加一，旧代数下的缓存等待过期
+	if err := r.flushInvalidations(ctx); err != nil {
+		r.fallback.Trip("检索缓存", err)
+		return fmt.Errorf("集合 %s 的召回缓存失效未写入 Redis，将在恢复后重试: %w", collection, err)
+	}
+	return nil
+}
+
+// flushInvalidations 将待补做的集合代数在 Redis 中加一。
+// 只扣除加一前已记录的失效次数，期间新的失效仍留在待办中，由其调用方再次加一
+func (r *RetrievalCache) flushInvalidations(ctx context.Context) error {
+	r.mu.Lock()
+	pending := make(map[string]int, len(r.pendingGen))
+	for collection, n := range r.pendingGen {
+		pending[collection] = n
+	}
+	r.mu.Unlock()
+
+	for collection, n := range pending {
+		if err := r.client.Incr(ctx, r.generationKey(collection)).Err(); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		if r.pendingGen[collection] -= n; r.pendingGen[collection] <= 0 {
+			delete(r.pendingGen, collection)
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// generationKey 集合缓存代数的键
+func (r *RetrievalCache) generationKey(collection string) string {
+	return fmt.Sprintf("%s:%s", generationCachePrefix, collection)
 }
 
 // InvalidateEmbedding 使指定查询的Embedding缓存失效
@@ -195,8 +371,10 @@ func (r *RetrievalCache) InvalidateEmbedding(ctx context.Context, query string)
 	hash := r.hashQuery(query)
 
 	// 降级模式
-	if r.useFallback {
-		delete(r.fallbackEmb, hash)
+	if r.fallback.Active() {
+		r.mu.Lock()
+		r.fallbackEmb.remove(hash)
+		r.mu.Unlock()
 		return nil
 	}
 
@@ -207,23 +385,28 @@ func (r *RetrievalCache) InvalidateEmbedding(ctx context.Context, query string)
 
 // ClearAllCache 清空所有缓存
 func (r *RetrievalCache) ClearAllCache(ctx context.Context) error {
+	r.semantic.forget("")
+
 	// 降级模式
-	if r.useFallback {
-		r.fallbackEmb = make(map[string][]float64)
-		r.fallbackDoc = make(map[string][]*schema.Document)
+	if r.fallback.Active() {
+		r.mu.Lock()
+		r.fallbackEmb = newLRU[[]float64](fallbackEmbeddingSize)
+		r.fallbackDoc = newLRU[fallbackDocs](fallbackRetrievalSize)
+		r.mu.Unlock()
 		return nil
 	}
 
-	// Redis模式：清除所有embedding和retrieval key
+	// Redis模式：清除所有embedding和retrieval key，缓存代数保留，避免与旧代数的键冲突
 	patterns := []string{
 		fmt.Sprintf("%s:*", embeddingCachePrefix),
 		fmt.Sprintf("%s:*", retrievalCachePrefix),
 	}
 
 	for _, pattern := range patterns {
-		keys, err := r.client.Keys(ctx, pattern).Result()
-		if err != nil {
-			continue
+		iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+		var keys []string
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
 		}
 		if len(keys) > 0 {
 			r.client.Del(ctx, keys...)
@@ -236,12 +419,15 @@ func (r *RetrievalCache) ClearAllCache(ctx context.Context) error {
 // GetStats 获取缓存统计信息
 func (r *RetrievalCache) GetStats(ctx context.Context) map[string]interface{} {
 	stats := map[string]interface{}{
-		"mode": "memory",
+		"mode":           "memory",
+		"semantic_count": r.semantic.len(),
 	}
 
-	if r.useFallback {
-		stats["embedding_count"] = len(r.fallbackEmb)
-		stats["retrieval_count"] = len(r.fallbackDoc)
+	if r.fallback.Active() {
+		r.mu.Lock()
+		stats["embedding_count"] = r.fallbackEmb.len()
+		stats["retrieval_count"] = r.fallbackDoc.len()
+		r.mu.Unlock()
 	} else {
 		stats["mode"] = "redis"
 		// 可以添加Redis统计信息
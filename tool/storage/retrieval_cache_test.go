@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/eino/schema"
+)
+
+func newTestRetrievalCache(t *testing.T) (*RetrievalCache, *miniredis.Miniredis) {
+	t.Helper()
+	mr, client := newTestRedis(t)
+	r := NewRetrievalCache()
+	r.client = client
+	r.fallback = RedisFallback{} // 没有全局 Redis 客户端时 NewRetrievalCache 会禁用 Redis
+	return r, mr
+}
+
+func TestRetrievalCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRetrievalCache(t)
+	docs := []*schema.Document{{ID: "d1", Content: "c"}}
+
+	scope := r.Scope(ctx, "docs")
+	if err := r.SetRetrieval(ctx, scope, "q", docs); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := r.GetRetrieval(ctx, r.Scope(ctx, "docs"), "q"); !ok || got[0].ID != "d1" {
+		t.Fatalf("GetRetrieval = %v, %v", got, ok)
+	}
+
+	if err := r.InvalidateCollection(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.GetRetrieval(ctx, r.Scope(ctx, "docs"), "q"); ok {
+		t.Error("失效后仍命中缓存")
+	}
+	if next := r.Scope(ctx, "docs"); next.Generation != scope.Generation+1 {
+		t.Errorf("Generation = %d, want %d", next.Generation, scope.Generation+1)
+	}
+	if other := r.Scope(ctx, "other"); other.Generation != 0 {
+		t.Errorf("其他集合的 Generation = %d", other.Generation)
+	}
+}
+
+func TestRetrievalCacheInvalidateDuringOutage(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRetrievalCache(t)
+
+	before := r.Scope(ctx, "docs")
+	if err := r.SetRetrieval(ctx, before, "q", []*schema.Document{{ID: "old"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redis 出错时失效返回错误，并记录待补做
+	mr.Close()
+	if err := r.InvalidateCollection(ctx, "docs"); err == nil {
+		t.Error("Redis 出错时失效应返回错误")
+	}
+	if !r.fallback.Active() || r.pendingGen["docs"] != 1 {
+		t.Fatalf("Active = %v, pending = %v", r.fallback.Active(), r.pendingGen)
+	}
+
+	// Redis 恢复、降级到期后，先补做代数加一，不会读到失效前的缓存
+	if err := mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	r.fallback.until.Store(0)
+	after := r.Scope(ctx, "docs")
+	if after.Generation != before.Generation+1 || len(r.pendingGen) != 0 {
+		t.Errorf("Generation = %d, pending = %v", after.Generation, r.pendingGen)
+	}
+	if _, ok := r.GetRetrieval(ctx, after, "q"); ok {
+		t.Error("恢复后读到了失效前的缓存")
+	}
+}
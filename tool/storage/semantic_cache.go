@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"math"
+	"sync"
+)
+
+// semanticEntry 语义缓存中的一条查询及其向量
+type semanticEntry struct {
+	scope  CacheScope
+	query  string
+	vector []float64
+	norm   float64
+}
+
+// semanticIndex 进程内的查询向量索引，容量满后覆盖最早的记录，查找时线性扫描
+type semanticIndex struct {
+	mu      sync.RWMutex
+	size    int
+	entries []semanticEntry
+	next    int // 下一个写入位置
+}
+
+func newSemanticIndex(size int) *semanticIndex {
+	return &semanticIndex{size: size}
+}
+
+func vectorNorm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// add 记录查询向量，同一作用域下相同的查询只保留最新一条
+func (s *semanticIndex) add(scope CacheScope, query string, vector []float64) {
+	norm := vectorNorm(vector)
+	if norm == 0 {
+		return
+	}
+	entry := semanticEntry{scope: scope, query: query, vector: vector, norm: norm}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].scope == scope && s.entries[i].query == query {
+			s.entries[i] = entry
+			return
+		}
+	}
+	if len(s.entries) < s.size {
+		s.entries = append(s.entries, entry)
+		return
+	}
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % s.size
+}
+
+// match 返回同一作用域下与 vector 余弦相似度最高且不低于 threshold 的查询
+func (s *semanticIndex) match(scope CacheScope, vector []float64, threshold float64) (string, bool) {
+	norm := vectorNorm(vector)
+	if norm == 0 {
+		return "", false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	best, bestSim := "", threshold
+	found := false
+	for _, e := range s.entries {
+		// 维度不同说明嵌入模型已更换，跳过
+		if e.scope != scope || len(e.vector) != len(vector) {
+			continue
+		}
+		var dot float64
+		for i, x := range vector {
+			dot += x * e.vector[i]
+		}
+		if sim := dot / (norm * e.norm); sim >= bestSim {
+			best, bestSim, found = e.query, sim, true
+		}
+	}
+	return best, found
+}
+
+// remove 删除一条记录
+func (s *semanticIndex) remove(scope CacheScope, query string) {
+	s.filter(func(e semanticEntry) bool {
+		return e.scope == scope && e.query == query
+	})
+}
+
+// forget 删除集合的全部记录，collection 为空时清空索引
+func (s *semanticIndex) forget(collection string) {
+	s.filter(func(e semanticEntry) bool {
+		return collection == "" || e.scope.Collection == collection
+	})
+}
+
+// filter 删除 drop 返回 true 的记录，剩余记录按写入顺序重新排列
+func (s *semanticIndex) filter(drop func(e semanticEntry) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.entries)
+	kept := make([]semanticEntry, 0, n)
+	for i := 0; i < n; i++ {
+		// 从最早写入的位置开始遍历
+		e := s.entries[(s.next+i)%n]
+		if !drop(e) {
+			kept = append(kept, e)
+		}
+	}
+	// 最早的记录位于开头，写满后从 0 开始覆盖
+	s.entries = kept
+	s.next = 0
+}
+
+func (s *semanticIndex) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
@@ -3,95 +3,176 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-agent/config"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 const (
-	sessionPrefix = "session"
-	sessionTTL    = 24 * time.Hour // 会话24小时过期
+	sessionPrefix      = "session"
+	sessionTTL         = 24 * time.Hour   // 会话24小时过期
+	defaultApprovalTTL = 30 * time.Minute // 待审批与待补充状态的默认过期时间
+	sessionSweepTick   = time.Minute      // 内存模式下清理过期会话的间隔
+)
+
+// SessionState 会话的 HITL 状态
+type SessionState string
+
+const (
+	SessionPendingApproval SessionState = "pending_approval" // 等待审批 SQL
+	SessionWaitingRefine   SessionState = "waiting_refine"   // SQL 被拒绝，等待补充信息
+	SessionDone            SessionState = "done"             // 空闲，会话不存在或过期也视为该状态
+)
+
+var (
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrInvalidTransition = errors.New("invalid session state transition")
+	ErrSessionConflict   = errors.New("session modified concurrently")
 )
 
 // SessionContext 会话上下文
 type SessionContext struct {
-	InterruptID   string `json:"interrupt_id"`
-	CheckPointID  string `json:"checkpoint_id"`
-	OriginalQuery string `json:"original_query"`
-	WaitingRefine bool   `json:"waiting_refine"`
+	State         SessionState `json:"state"`
+	InterruptID   string       `json:"interrupt_id,omitempty"`
+	ApprovalID    string       `json:"approval_id,omitempty"` // 审批收件箱中的请求 ID
+	CheckPointID  string       `json:"checkpoint_id,omitempty"`
+	OriginalQuery string       `json:"original_query,omitempty"`
+	RejectReason  string       `json:"reject_reason,omitempty"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+}
+
+// expired 是否已过期，内存模式下依赖该字段判断
+func (sc *SessionContext) expired() bool {
+	return !sc.ExpiresAt.IsZero() && time.Now().After(sc.ExpiresAt)
 }
 
-// SessionStore Session存储管理器
+// SessionStore Session存储管理器。Redis 出错期间写入的会话保存在内存中，之后仍从内存读写
 type SessionStore struct {
 	client      *redis.Client
+	mu          sync.Mutex
 	fallbackMap map[string]*SessionContext // 降级到内存模式
-	useFallback bool
+	fallback    RedisFallback
+	approvalTTL time.Duration
 }
 
 // NewSessionStore 创建Session存储管理器
 func NewSessionStore() *SessionStore {
+	s := &SessionStore{
+		fallbackMap: make(map[string]*SessionContext),
+		approvalTTL: defaultApprovalTTL,
+	}
+	if config.Cfg != nil {
+		if ttl, err := time.ParseDuration(config.Cfg.HITLConf.ApprovalTTL); err == nil && ttl > 0 {
+			s.approvalTTL = ttl
+		}
+	}
+
 	client, err := GetRedisClient()
 	if err != nil {
 		// Redis不可用时使用内存模式
-		return &SessionStore{
-			fallbackMap: make(map[string]*SessionContext),
-			useFallback: true,
-		}
+		s.fallback.Disable()
+		return s
 	}
+	s.client = client
+	return s
+}
 
-	return &SessionStore{
-		client:      client,
-		fallbackMap: make(map[string]*SessionContext),
-		useFallback: false,
-	}
+// StartJanitor 定期删除内存中已过期的会话
+func (s *SessionStore) StartJanitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(sessionSweepTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweepFallback()
+			}
+		}
+	}()
 }
 
-// SaveSession 保存会话上下文
-func (s *SessionStore) SaveSession(ctx context.Context, sessionID string, sc *SessionContext) error {
-	// 降级模式
-	if s.useFallback {
-		s.fallbackMap[sessionID] = sc
-		return nil
+func (s *SessionStore) sweepFallback() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sc := range s.fallbackMap {
+		if sc.expired() {
+			delete(s.fallbackMap, id)
+		}
 	}
+}
 
-	// 序列化为JSON
-	data, err := json.Marshal(sc)
-	if err != nil {
-		return fmt.Errorf("failed to marshal session context: %w", err)
+// fallbackGet 读取内存中的会话，found 表示会话由内存模式管理（包括已过期）
+func (s *SessionStore) fallbackGet(sessionID string) (sc *SessionContext, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.fallbackMap[sessionID]
+	if !ok {
+		return nil, false
 	}
-
-	// 保存到Redis
-	key := s.makeKey(sessionID)
-	err = s.client.Set(ctx, key, data, sessionTTL).Err()
-	if err != nil {
-		// Redis出错时降级到内存模式
-		s.useFallback = true
-		return s.SaveSession(ctx, sessionID, sc)
+	if cur.expired() {
+		delete(s.fallbackMap, sessionID)
+		return nil, true
 	}
+	cp := *cur
+	return &cp, true
+}
 
-	return nil
+// Transition 原子地将会话从 from 中的某个状态迁移，update 负责修改会话内容（包括目标状态）。
+// 会话不存在或已过期时视为 SessionDone，update 返回错误时放弃本次修改
+func (s *SessionStore) Transition(ctx context.Context, sessionID string, from []SessionState, update func(sc *SessionContext) error) (*SessionContext, error) {
+	return s.mutate(ctx, sessionID, func(sc *SessionContext, exists bool) error {
+		if !exists {
+			sc.State = SessionDone
+		}
+		allowed := false
+		for _, st := range from {
+			if sc.State == st {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s", ErrInvalidTransition, sc.State)
+		}
+		return update(sc)
+	})
+}
+
+// SaveSession 保存会话上下文
+func (s *SessionStore) SaveSession(ctx context.Context, sessionID string, sc *SessionContext) error {
+	_, err := s.mutate(ctx, sessionID, func(cur *SessionContext, exists bool) error {
+		*cur = *sc
+		return nil
+	})
+	return err
 }
 
 // GetSession 获取会话上下文
 func (s *SessionStore) GetSession(ctx context.Context, sessionID string) (*SessionContext, error) {
-	// 降级模式
-	if s.useFallback {
-		if sc, ok := s.fallbackMap[sessionID]; ok {
-			return sc, nil
+	// 降级模式，或会话在降级期间写入内存
+	if sc, found := s.fallbackGet(sessionID); found || s.fallback.Active() {
+		if sc == nil {
+			return nil, ErrSessionNotFound
 		}
-		return nil, fmt.Errorf("session not found")
+		return sc, nil
 	}
 
 	// 从Redis获取
 	key := s.makeKey(sessionID)
 	data, err := s.client.Get(ctx, key).Bytes()
 	if err != nil {
-		if err == redis.Nil {
-			return nil, fmt.Errorf("session not found")
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
 		}
 		// Redis出错时降级到内存模式
-		s.useFallback = true
+		s.fallback.Trip("会话状态", err)
 		return s.GetSession(ctx, sessionID)
 	}
 
@@ -106,9 +187,12 @@ func (s *SessionStore) GetSession(ctx context.Context, sessionID string) (*Sessi
 
 // DeleteSession 删除会话上下文
 func (s *SessionStore) DeleteSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	delete(s.fallbackMap, sessionID)
+	s.mu.Unlock()
+
 	// 降级模式
-	if s.useFallback {
-		delete(s.fallbackMap, sessionID)
+	if s.fallback.Active() {
 		return nil
 	}
 
@@ -117,7 +201,7 @@ func (s *SessionStore) DeleteSession(ctx context.Context, sessionID string) erro
 	err := s.client.Del(ctx, key).Err()
 	if err != nil {
 		// Redis出错时降级到内存模式
-		s.useFallback = true
+		s.fallback.Trip("会话状态", err)
 		return s.DeleteSession(ctx, sessionID)
 	}
 
@@ -126,37 +210,100 @@ func (s *SessionStore) DeleteSession(ctx context.Context, sessionID string) erro
 
 // Exists 检查会话是否存在
 func (s *SessionStore) Exists(ctx context.Context, sessionID string) (bool, error) {
-	// 降级模式
-	if s.useFallback {
-		_, ok := s.fallbackMap[sessionID]
-		return ok, nil
+	_, err := s.GetSession(ctx, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UpdateSession 更新会话中的特定字段
+func (s *SessionStore) UpdateSession(ctx context.Context, sessionID string, updateFn func(*SessionContext)) error {
+	_, err := s.mutate(ctx, sessionID, func(sc *SessionContext, exists bool) error {
+		if !exists {
+			return ErrSessionNotFound
+		}
+		updateFn(sc)
+		return nil
+	})
+	return err
+}
+
+// mutate 读取-修改-写入的原子操作：Redis 模式使用 WATCH 乐观锁，内存模式使用互斥锁
+func (s *SessionStore) mutate(ctx context.Context, sessionID string, fn func(sc *SessionContext, exists bool) error) (*SessionContext, error) {
+	// 降级模式，或会话在降级期间写入内存
+	s.mu.Lock()
+	_, inMemory := s.fallbackMap[sessionID]
+	s.mu.Unlock()
+	if inMemory || s.fallback.Active() {
+		return s.mutateFallback(sessionID, fn)
 	}
 
-	// 检查Redis
 	key := s.makeKey(sessionID)
-	n, err := s.client.Exists(ctx, key).Result()
-	if err != nil {
+	var result *SessionContext
+	err := WatchUpdate(ctx, s.client, key, func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		sc := &SessionContext{}
+		if exists {
+			if err := json.Unmarshal(data, sc); err != nil {
+				return fmt.Errorf("failed to unmarshal session context: %w", err)
+			}
+		}
+		if err := fn(sc, exists); err != nil {
+			return err
+		}
+		ttl := s.touch(sc)
+		data, err := json.Marshal(sc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal session context: %w", err)
+		}
+		pipe.Set(ctx, key, data, ttl)
+		result = sc
+		return nil
+	})
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.Is(err, ErrTxConflict):
+		return nil, ErrSessionConflict
+	case errors.Is(err, ErrRedis):
 		// Redis出错时降级到内存模式
-		s.useFallback = true
-		return s.Exists(ctx, sessionID)
+		s.fallback.Trip("会话状态", err)
+		return s.mutateFallback(sessionID, fn)
+	default:
+		return nil, err
 	}
-
-	return n > 0, nil
 }
 
-// UpdateSession 更新会话中的特定字段
-func (s *SessionStore) UpdateSession(ctx context.Context, sessionID string, updateFn func(*SessionContext)) error {
-	// 获取现有会话
-	sc, err := s.GetSession(ctx, sessionID)
-	if err != nil {
-		return err
-	}
+// mutateFallback 内存模式下的读取-修改-写入，使用互斥锁保证原子性
+func (s *SessionStore) mutateFallback(sessionID string, fn func(sc *SessionContext, exists bool) error) (*SessionContext, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// 执行更新函数
-	updateFn(sc)
+	sc := &SessionContext{}
+	cur, exists := s.fallbackMap[sessionID]
+	if exists && !cur.expired() {
+		*sc = *cur
+	} else {
+		exists = false
+	}
+	if err := fn(sc, exists); err != nil {
+		return nil, err
+	}
+	s.touch(sc)
+	s.fallbackMap[sessionID] = sc
+	cp := *sc
+	return &cp, nil
+}
 
-	// 保存更新后的会话
-	return s.SaveSession(ctx, sessionID, sc)
+// touch 刷新更新时间并按状态设置过期时间，返回 TTL
+func (s *SessionStore) touch(sc *SessionContext) time.Duration {
+	ttl := sessionTTL
+	if sc.State == SessionPendingApproval || sc.State == SessionWaitingRefine {
+		ttl = s.approvalTTL
+	}
+	sc.UpdatedAt = time.Now()
+	sc.ExpiresAt = sc.UpdatedAt.Add(ttl)
+	return ttl
 }
 
 // makeKey 生成Redis key: session:{sessionID}
@@ -166,12 +313,22 @@ func (s *SessionStore) makeKey(sessionID string) string {
 
 // ListSessions 列出所有会话ID
 func (s *SessionStore) ListSessions(ctx context.Context) ([]string, error) {
-	// 降级模式
-	if s.useFallback {
-		ids := make([]string, 0, len(s.fallbackMap))
-		for id := range s.fallbackMap {
-			ids = append(ids, id)
+	// 降级期间写入内存的会话
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.fallbackMap))
+	inMemory := make(map[string]bool, len(s.fallbackMap))
+	for id, sc := range s.fallbackMap {
+		inMemory[id] = true
+		if sc.expired() {
+			delete(s.fallbackMap, id)
+			continue
 		}
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	// 降级模式
+	if s.fallback.Active() {
 		return ids, nil
 	}
 
@@ -180,15 +337,15 @@ func (s *SessionStore) ListSessions(ctx context.Context) ([]string, error) {
 	keys, err := s.client.Keys(ctx, pattern).Result()
 	if err != nil {
 		// Redis出错时降级到内存模式
-		s.useFallback = true
-		return s.ListSessions(ctx)
+		s.fallback.Trip("会话状态", err)
+		return ids, nil
 	}
 
 	// 提取session ID
-	ids := make([]string, 0, len(keys))
 	prefix := sessionPrefix + ":"
 	for _, key := range keys {
-		if len(key) > len(prefix) {
+		// 内存中的会话优先，Redis 中的旧版本不再使用
+		if len(key) > len(prefix) && !inMemory[key[len(prefix):]] {
 			ids = append(ids, key[len(prefix):])
 		}
 	}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionStoreTransition(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	s := &SessionStore{client: client, fallbackMap: make(map[string]*SessionContext), approvalTTL: time.Minute}
+
+	// 不存在的会话视为 done
+	_, err := s.Transition(ctx, "s1", []SessionState{SessionDone}, func(sc *SessionContext) error {
+		sc.State = SessionPendingApproval
+		sc.InterruptID = "i1"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("session:s1"); ttl != time.Minute {
+		t.Errorf("待审批会话的 TTL = %v, want %v", ttl, time.Minute)
+	}
+
+	// 待审批时不能开始新的一轮，且不修改会话
+	_, err = s.Transition(ctx, "s1", []SessionState{SessionDone}, func(sc *SessionContext) error {
+		sc.State = SessionDone
+		return nil
+	})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("err = %v, want ErrInvalidTransition", err)
+	}
+
+	sc, err := s.Transition(ctx, "s1", []SessionState{SessionPendingApproval}, func(sc *SessionContext) error {
+		sc.State = SessionDone
+		return nil
+	})
+	if err != nil || sc.State != SessionDone || sc.InterruptID != "i1" {
+		t.Fatalf("Transition = %+v, %v", sc, err)
+	}
+	if ttl := mr.TTL("session:s1"); ttl != sessionTTL {
+		t.Errorf("空闲会话的 TTL = %v, want %v", ttl, sessionTTL)
+	}
+
+	got, err := s.GetSession(ctx, "s1")
+	if err != nil || got.State != SessionDone {
+		t.Errorf("GetSession = %+v, %v", got, err)
+	}
+	if _, err := s.GetSession(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionStoreFallback(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	s := &SessionStore{client: client, fallbackMap: make(map[string]*SessionContext), approvalTTL: time.Minute}
+
+	if err := s.SaveSession(ctx, "before", &SessionContext{State: SessionDone}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redis 出错后写入内存，降级期间仍可读写
+	mr.Close()
+	if err := s.SaveSession(ctx, "during", &SessionContext{State: SessionWaitingRefine}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.fallback.Active() {
+		t.Error("Redis 出错后应降级到内存模式")
+	}
+	got, err := s.GetSession(ctx, "during")
+	if err != nil || got.State != SessionWaitingRefine {
+		t.Errorf("GetSession = %+v, %v", got, err)
+	}
+	ids, err := s.ListSessions(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != "during" {
+		t.Errorf("ListSessions = %v, %v", ids, err)
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxTxRetries 乐观锁冲突时的最大重试次数
+const maxTxRetries = 5
+
+var (
+	// ErrRedis 包装 Redis 自身的错误（连接失败、超时等），调用方据此降级到内存模式
+	ErrRedis = errors.New("redis error")
+	// ErrTxConflict 重试 maxTxRetries 次后仍有其他请求同时修改
+	ErrTxConflict = errors.New("redis key modified concurrently")
+)
+
+// WatchUpdate 使用 WATCH 乐观锁读取-修改-写入 key。update 收到 key 的当前值（exists 为 false 表示不存在），
+// 将写入操作加入 pipe，在同一个 MULTI 事务中执行；其他请求同时修改 key 时重新读取并调用 update。
+// update 返回的错误原样返回且不写入，Redis 自身的错误包装为 ErrRedis，重试耗尽返回 ErrTxConflict
+func WatchUpdate(ctx context.Context, client *redis.Client, key string, update func(data []byte, exists bool, pipe redis.Pipeliner) error) error {
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		exists := true
+		switch {
+		case errors.Is(err, redis.Nil):
+			exists = false
+		case err != nil:
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if err := update(data, exists, pipe); err != nil {
+				return &callbackError{err}
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		err := client.Watch(ctx, txf, key)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, redis.TxFailedErr) {
+			// 其他请求同时修改了该 key，重试
+			continue
+		}
+		var cbErr *callbackError
+		if errors.As(err, &cbErr) {
+			return cbErr.err
+		}
+		return fmt.Errorf("%w: %w", ErrRedis, err)
+	}
+	return ErrTxConflict
+}
+
+// callbackError 包装业务回调返回的错误，用于与 Redis 自身的错误区分
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestWatchUpdate(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	// 第一次调用时其他请求修改了 key，应重新读取后再写入
+	calls := 0
+	err := WatchUpdate(ctx, client, "k", func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		calls++
+		if calls == 1 {
+			if exists {
+				t.Error("key 不应存在")
+			}
+			mr.Set("k", "other")
+		}
+		pipe.Set(ctx, "k", string(data)+"+mine", 0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mr.Get("k"); calls != 2 || got != "other+mine" {
+		t.Errorf("calls = %d, value = %q", calls, got)
+	}
+
+	// 回调返回的错误原样返回且不写入
+	errBusiness := errors.New("business")
+	err = WatchUpdate(ctx, client, "k", func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "k", "changed", 0)
+		return errBusiness
+	})
+	if !errors.Is(err, errBusiness) || errors.Is(err, ErrRedis) {
+		t.Errorf("err = %v, want business error", err)
+	}
+	if got, _ := mr.Get("k"); got != "other+mine" {
+		t.Errorf("回调出错后 value = %q", got)
+	}
+
+	// 每次都有并发修改时重试耗尽
+	err = WatchUpdate(ctx, client, "k", func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		mr.Set("k", "other")
+		pipe.Set(ctx, "k", "mine", 0)
+		return nil
+	})
+	if !errors.Is(err, ErrTxConflict) {
+		t.Errorf("err = %v, want ErrTxConflict", err)
+	}
+
+	// Redis 不可用
+	mr.Close()
+	err = WatchUpdate(ctx, client, "k", func(data []byte, exists bool, pipe redis.Pipeliner) error {
+		return nil
+	})
+	if !errors.Is(err, ErrRedis) {
+		t.Errorf("err = %v, want ErrRedis", err)
+	}
+}
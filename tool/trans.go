@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"go-agent/tool/mcp_client"
 
 	"github.com/cloudwego/eino/schema"
 )
@@ -52,7 +53,7 @@ func MsgToSQLToolCall(ctx context.Context, input *schema.Message) (*schema.Messa
 		{
 			ID: "call_sql_exec",
 			Function: schema.FunctionCall{
-				Name:      "mysql_query",
+				Name:      mcp_client.ToolName(mcp_client.SQLServer, "mysql_query"),
 				Arguments: string(paramsJSON),
 			},
 		},